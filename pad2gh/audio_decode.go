@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// pcmStream decodes an MP3 to stereo float samples in the range -1..1, mono files are duplicated on both channels
+type pcmStream struct {
+	decoder *mp3.Decoder
+	mono    bool
+	buf     []byte
+}
+
+func newPCMStream(r io.Reader) (*pcmStream, error) {
+	// wrapped so the decoder doesn't scan the whole file for its length before the first sample
+	br := bufio.NewReaderSize(r, 64<<10)
+	mono, err := skipID3AndCheckMono(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode MP3: %v", err)
+	}
+	decoder, err := mp3.NewDecoder(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode MP3: %v", err)
+	}
+	return &pcmStream{decoder: decoder, mono: mono}, nil
+}
+
+// skipID3AndCheckMono skips the ID3v2 tag and tells from the header of the first frame if the file is mono,
+// the decoder doesn't say so
+func skipID3AndCheckMono(br *bufio.Reader) (bool, error) {
+	header, _ := br.Peek(10)
+	if length := id3v2Length(header); length > 0 {
+		_, err := br.Discard(int(length))
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+	}
+	b, _ := br.Peek(4096)
+	_, h, ok := findFirstMP3Frame(b)
+	return ok && h.mono, nil
+}
+
+// SampleRate is the sample rate of the decoded audio
+func (s *pcmStream) SampleRate() int {
+	return s.decoder.SampleRate()
+}
+
+// read decodes up to len(left) samples per channel, it returns io.EOF after the last sample
+func (s *pcmStream) read(left, right []float64) (int, error) {
+	if cap(s.buf) < 4*len(left) {
+		s.buf = make([]byte, 4*len(left))
+	}
+	buf := s.buf[:4*len(left)]
+
+	n, err := io.ReadFull(s.decoder, buf)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	samples := n / 4
+	for i := 0; i < samples; i++ {
+		left[i] = float64(int16(binary.LittleEndian.Uint16(buf[4*i:]))) / 32768
+		right[i] = float64(int16(binary.LittleEndian.Uint16(buf[4*i+2:]))) / 32768
+	}
+	if samples == 0 && err == nil {
+		err = io.EOF
+	}
+	return samples, err
+}
+
+// Mono tells if the file has a single channel, which read returns on both
+func (s *pcmStream) Mono() bool {
+	return s.mono
+}
+
+// decodeAudio calls fn with consecutive blocks of decoded samples until the end of the stream
+func decodeAudio(r io.Reader, fn func(sampleRate int, mono bool, left, right []float64)) error {
+	stream, err := newPCMStream(r)
+	if err != nil {
+		return err
+	}
+	left, right := make([]float64, 4096), make([]float64, 4096)
+	for {
+		n, err := stream.read(left, right)
+		if n > 0 {
+			fn(stream.SampleRate(), stream.Mono(), left[:n], right[:n])
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode MP3: %v", err)
+		}
+	}
+}
+
+// openSoundFile opens a sound file from -sound-dir, or downloads it with -file-online
+func openSoundFile(fileName string, config *Config) (io.ReadCloser, error) {
+	if config.SoundDir != "" {
+		localPath := filepath.Join(config.SoundDir, fileName)
+		if _, err := os.Stat(localPath); err == nil {
+			return os.Open(localPath)
+		}
+	}
+	if config.FileOnline {
+		fileURL, err := url.JoinPath(config.FileBaseURL, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct file URL: %v", err)
+		}
+		resp, err := http.Get(fileURL)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", fileURL, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return nil, fmt.Errorf("sound file %s not found", fileName)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// audioMimeTypes are the IANA media types of the audio extensions. Ogg Opus files are audio/ogg (RFC 7845),
+// audio/opus is only registered for RTP.
+var audioMimeTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".opus": "audio/ogg",
+	".ogg":  "audio/ogg",
+}
+
+// isMP3Audio reports whether the audio is the MP3 rendition, older entries use the non-standard audio/mp3
+func isMP3Audio(audio CiRaudio) bool {
+	return audio.MimeType == "audio/mpeg" || audio.MimeType == "audio/mp3" || strings.EqualFold(path.Ext(audio.Url), ".mp3")
+}
+
+// entryMP3 returns the MP3 rendition of the entry, which the tagging and decoding work on, or nil
+func entryMP3(entry *CiREntry) *CiRaudio {
+	for i := range entry.Audio {
+		if isMP3Audio(entry.Audio[i]) {
+			return &entry.Audio[i]
+		}
+	}
+	return nil
+}
+
+// renditionFileName returns the canonical sound file name of a date with another extension
+func renditionFileName(date, ext string) string {
+	return strings.TrimSuffix(soundFileNameForDate(date), ".mp3") + ext
+}
+
+// audioFormatPreference parses -audio-formats into extensions, unknown formats are an error
+func audioFormatPreference(formats string) ([]string, error) {
+	var extensions []string
+	for _, format := range strings.Split(formats, ",") {
+		ext := "." + strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+		if _, ok := audioMimeTypes[ext]; !ok {
+			return nil, fmt.Errorf("unknown audio format %q, use mp3, opus, ogg or m4a", format)
+		}
+		extensions = append(extensions, ext)
+	}
+	if len(extensions) == 0 {
+		return nil, fmt.Errorf("no audio formats given")
+	}
+	return extensions, nil
+}
+
+// soundFileAvailable checks for a sound file where the config says to look for it, like PadMapping.hasSoundFile
+func soundFileAvailable(fileName string, config *Config) bool {
+	if config.FileOnline {
+		fileURL, err := url.JoinPath(config.FileBaseURL, fileName)
+		if err != nil {
+			return false
+		}
+		resp, err := http.Head(fileURL)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close() //nolint:errcheck
+		return resp.StatusCode == http.StatusOK
+	}
+	if config.SoundDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(config.SoundDir, fileName))
+	return err == nil
+}
+
+// addAudioRenditions adds the sibling renditions of the MP3 found for the date and orders all of them by
+// -audio-formats. The MP3 is always kept, even without sound file checks.
+func addAudioRenditions(entry *CiREntry, date string, config *Config) error {
+	if config.SoundDir == "" && !config.FileOnline {
+		return nil
+	}
+	preference, err := audioFormatPreference(config.AudioFormats)
+	if err != nil {
+		return err
+	}
+
+	renditions := map[string]CiRaudio{}
+	for _, audio := range entry.Audio {
+		renditions[strings.ToLower(path.Ext(audio.Url))] = audio
+	}
+	for ext := range audioMimeTypes {
+		if _, exists := renditions[ext]; exists {
+			continue
+		}
+		fileName := renditionFileName(date, ext)
+		if soundFileAvailable(fileName, config) {
+			renditions[ext] = CiRaudio{Url: "$media_base_url/" + fileName, MimeType: audioMimeTypes[ext]}
+		}
+	}
+
+	var audios []CiRaudio
+	for _, ext := range preference {
+		if audio, exists := renditions[ext]; exists {
+			audios = append(audios, audio)
+			delete(renditions, ext)
+		}
+	}
+	// the MP3 stays even if it's not in the preference, the feed needs it
+	if audio, exists := renditions[".mp3"]; exists {
+		audios = append(audios, audio)
+	}
+	entry.Audio = audios
+	return nil
+}
+
+// probeAudio reads the metadata of a sound file of any supported format
+func probeAudio(r io.ReaderAt, size int64, fileName string, fullScan bool) (AudioProbe, error) {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".opus", ".ogg":
+		return probeOgg(r, size)
+	case ".m4a":
+		return probeMP4(r, size)
+	}
+	return probeMP3(r, size, fullScan)
+}
+
+// probeOgg reads the duration of an Ogg Opus or Vorbis file from the granule position of the last page
+func probeOgg(r io.ReaderAt, size int64) (AudioProbe, error) {
+	probe := AudioProbe{Size: size, AudioBytes: size, Exact: true}
+
+	head := make([]byte, 4096)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return probe, fmt.Errorf("failed to read header: %v", err)
+	}
+	head = head[:n]
+	if !bytes.HasPrefix(head, []byte("OggS")) || len(head) < 28 {
+		return probe, fmt.Errorf("no Ogg page found")
+	}
+	// the first packet starts after the page header and its segment table
+	packet := head[27+int(head[26]):]
+
+	var preSkip int64
+	switch {
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 12:
+		// Opus granule positions always count 48 kHz samples
+		probe.SampleRate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		probe.SampleRate = int(binary.LittleEndian.Uint32(packet[12:16]))
+	default:
+		return probe, fmt.Errorf("unsupported Ogg codec")
+	}
+	if probe.SampleRate == 0 {
+		return probe, fmt.Errorf("invalid sample rate")
+	}
+
+	tailSize := int64(64 * 1024)
+	if tailSize > size {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	n, err = r.ReadAt(tail, size-tailSize)
+	if err != nil && err != io.EOF {
+		return probe, fmt.Errorf("failed to read last page: %v", err)
+	}
+	tail = tail[:n]
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) {
+		return probe, fmt.Errorf("no final Ogg page found")
+	}
+	granule := int64(binary.LittleEndian.Uint64(tail[last+6:]))
+
+	probe.Duration = time.Duration(granule-preSkip) * time.Second / time.Duration(probe.SampleRate)
+	probe.Bitrate = averageBitrate(probe.AudioBytes, probe.Duration)
+	return probe, nil
+}
+
+// probeMP4 reads the duration of an MP4/M4A file from the movie header, which may be at the end of the file
+func probeMP4(r io.ReaderAt, size int64) (AudioProbe, error) {
+	probe := AudioProbe{Size: size, AudioBytes: size, Exact: true}
+
+	moovStart, moovEnd, err := findMP4Box(r, 0, size, "moov")
+	if err != nil {
+		return probe, err
+	}
+	mvhdStart, mvhdEnd, err := findMP4Box(r, moovStart, moovEnd, "mvhd")
+	if err != nil {
+		return probe, err
+	}
+
+	mvhd := make([]byte, mvhdEnd-mvhdStart)
+	if len(mvhd) < 20 || len(mvhd) > 1024 {
+		return probe, fmt.Errorf("invalid mvhd box")
+	}
+	if _, err := r.ReadAt(mvhd, mvhdStart); err != nil {
+		return probe, fmt.Errorf("failed to read mvhd box: %v", err)
+	}
+
+	var timescale, duration uint64
+	if mvhd[0] == 1 {
+		if len(mvhd) < 32 {
+			return probe, fmt.Errorf("invalid mvhd box")
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:24]))
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+	if timescale == 0 {
+		return probe, fmt.Errorf("invalid timescale")
+	}
+
+	probe.Duration = time.Duration(duration) * time.Second / time.Duration(timescale)
+	probe.Bitrate = averageBitrate(probe.AudioBytes, probe.Duration)
+	return probe, nil
+}
+
+// findMP4Box returns the content range of the first box of the given type between start and end
+func findMP4Box(r io.ReaderAt, start, end int64, boxType string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, fmt.Errorf("failed to read box header: %v", err)
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, fmt.Errorf("failed to read box header: %v", err)
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || offset+boxSize > end {
+			return 0, 0, fmt.Errorf("invalid %s box", string(header[4:8]))
+		}
+		if string(header[4:8]) == boxType {
+			return offset + headerSize, offset + boxSize, nil
+		}
+		offset += boxSize
+	}
+	return 0, 0, fmt.Errorf("no %s box found", boxType)
+}
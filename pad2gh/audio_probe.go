@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AudioProbe is the technical metadata of a sound file
+type AudioProbe struct {
+	Size       int64         // file size in bytes
+	AudioBytes int64         // bytes of MPEG frames, without tags
+	Duration   time.Duration // exact if all frames were counted or a Xing/VBRI header exists
+	Bitrate    int           // average bitrate in kbit/s
+	SampleRate int           // in Hz
+	Frames     int
+	Exact      bool // false if the duration was estimated from the first frame (CBR assumption)
+}
+
+// mp3FrameHeader is the parsed 4 byte MPEG audio frame header
+type mp3FrameHeader struct {
+	version    int // 1, 2 or 25 for MPEG 1, 2 and 2.5
+	layer      int // 1, 2 or 3
+	bitrate    int // kbit/s
+	sampleRate int // Hz
+	padding    bool
+	mono       bool
+}
+
+var mp3Bitrates = map[[2]int][16]int{
+	{1, 1}:  {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	{1, 2}:  {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+	{1, 3}:  {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{2, 1}:  {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{2, 2}:  {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{2, 3}:  {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{25, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{25, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{25, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var mp3SampleRates = map[int][3]int{
+	1:  {44100, 48000, 32000},
+	2:  {22050, 24000, 16000},
+	25: {11025, 12000, 8000},
+}
+
+// parseMP3FrameHeader parses a frame header, ok is false if the bytes are no valid header
+func parseMP3FrameHeader(b []byte) (mp3FrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3FrameHeader{}, false
+	}
+
+	var h mp3FrameHeader
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		h.version = 25
+	case 2:
+		h.version = 2
+	case 3:
+		h.version = 1
+	default:
+		return mp3FrameHeader{}, false
+	}
+	switch (b[1] >> 1) & 0x03 {
+	case 1:
+		h.layer = 3
+	case 2:
+		h.layer = 2
+	case 3:
+		h.layer = 1
+	default:
+		return mp3FrameHeader{}, false
+	}
+
+	bitrateIndex := b[2] >> 4
+	sampleRateIndex := (b[2] >> 2) & 0x03
+	if bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		// free format streams are not used for podcasts
+		return mp3FrameHeader{}, false
+	}
+	h.bitrate = mp3Bitrates[[2]int{h.version, h.layer}][bitrateIndex]
+	h.sampleRate = mp3SampleRates[h.version][sampleRateIndex]
+	h.padding = (b[2]>>1)&0x01 == 1
+	h.mono = b[3]>>6 == 3
+	return h, true
+}
+
+// samplesPerFrame returns the number of PCM samples per channel in a frame
+func (h mp3FrameHeader) samplesPerFrame() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != 1:
+		return 576
+	}
+	return 1152
+}
+
+// frameLength returns the length of the frame in bytes including the header
+func (h mp3FrameHeader) frameLength() int {
+	padding := 0
+	if h.padding {
+		padding = 1
+	}
+	if h.layer == 1 {
+		return (12*h.bitrate*1000/h.sampleRate + padding) * 4
+	}
+	return h.samplesPerFrame()/8*h.bitrate*1000/h.sampleRate + padding
+}
+
+// sideInfoLength returns the length of the layer III side information following the header
+func (h mp3FrameHeader) sideInfoLength() int {
+	switch {
+	case h.version == 1 && h.mono:
+		return 17
+	case h.version == 1:
+		return 32
+	case h.mono:
+		return 9
+	}
+	return 17
+}
+
+// id3v2Length returns the length of an ID3v2 tag at the beginning of b, 0 if there is none
+func id3v2Length(b []byte) int64 {
+	if len(b) < 10 || !bytes.HasPrefix(b, []byte("ID3")) {
+		return 0
+	}
+	size := int64(b[6]&0x7F)<<21 | int64(b[7]&0x7F)<<14 | int64(b[8]&0x7F)<<7 | int64(b[9]&0x7F)
+	length := 10 + size
+	if b[5]&0x10 != 0 {
+		length += 10 // footer
+	}
+	return length
+}
+
+// findFirstMP3Frame returns the offset of the first frame in b that is followed by a second valid frame
+func findFirstMP3Frame(b []byte) (int, mp3FrameHeader, bool) {
+	for i := 0; i+4 <= len(b); i++ {
+		h, ok := parseMP3FrameHeader(b[i:])
+		if !ok {
+			continue
+		}
+		next := i + h.frameLength()
+		if next+4 <= len(b) {
+			if _, ok := parseMP3FrameHeader(b[next:]); !ok {
+				continue
+			}
+		}
+		return i, h, true
+	}
+	return 0, mp3FrameHeader{}, false
+}
+
+// vbrFrameCount reads the frame count of a Xing/Info or VBRI header in the first frame
+func vbrFrameCount(frame []byte, h mp3FrameHeader) (int, bool) {
+	xingOffset := 4 + h.sideInfoLength()
+	if len(frame) >= xingOffset+12 {
+		tag := string(frame[xingOffset : xingOffset+4])
+		if tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(frame[xingOffset+4:])
+			if flags&0x01 != 0 {
+				return int(binary.BigEndian.Uint32(frame[xingOffset+8:])), true
+			}
+		}
+	}
+	if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+		return int(binary.BigEndian.Uint32(frame[36+14:])), true
+	}
+	return 0, false
+}
+
+// probeHeadSize is the number of bytes read to find the first frame after the ID3 tag
+const probeHeadSize = 64 * 1024
+
+// probeMP3 reads the metadata of an MP3 file. With fullScan every frame header is read, which is exact
+// even for VBR files without Xing header, otherwise only the beginning of the file is read.
+func probeMP3(r io.ReaderAt, size int64, fullScan bool) (AudioProbe, error) {
+	probe := AudioProbe{Size: size}
+
+	head := make([]byte, 10)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return probe, fmt.Errorf("failed to read header: %v", err)
+	}
+	audioStart := id3v2Length(head)
+
+	head = make([]byte, probeHeadSize)
+	n, err := r.ReadAt(head, audioStart)
+	if err != nil && err != io.EOF {
+		return probe, fmt.Errorf("failed to read first frames: %v", err)
+	}
+	head = head[:n]
+
+	offset, first, ok := findFirstMP3Frame(head)
+	if !ok {
+		return probe, fmt.Errorf("no MPEG audio frame found")
+	}
+	audioStart += int64(offset)
+	probe.SampleRate = first.sampleRate
+
+	audioEnd := size
+	tail := make([]byte, 3)
+	if size > 128 {
+		if _, err := r.ReadAt(tail, size-128); err == nil && string(tail) == "TAG" {
+			audioEnd -= 128 // ID3v1
+		}
+	}
+	probe.AudioBytes = audioEnd - audioStart
+
+	frameCount, hasVBRHeader := vbrFrameCount(head[offset:], first)
+	if !fullScan {
+		if hasVBRHeader {
+			probe.Frames = frameCount
+			probe.Exact = true
+			probe.Duration = time.Duration(frameCount) * time.Second * time.Duration(first.samplesPerFrame()) / time.Duration(first.sampleRate)
+			probe.Bitrate = averageBitrate(probe.AudioBytes, probe.Duration)
+		} else {
+			// without a VBR header all frames are assumed to have the bitrate of the first one
+			probe.Bitrate = first.bitrate
+			probe.Duration = time.Duration(probe.AudioBytes*8) * time.Millisecond / time.Duration(first.bitrate)
+			probe.Frames = int(probe.Duration * time.Duration(first.sampleRate) / time.Duration(first.samplesPerFrame()) / time.Second)
+		}
+		return probe, nil
+	}
+
+	samples, frames, err := scanMP3Frames(io.NewSectionReader(r, audioStart, probe.AudioBytes), hasVBRHeader)
+	if err != nil {
+		return probe, err
+	}
+	probe.Frames = frames
+	probe.Exact = true
+	probe.Duration = time.Duration(samples) * time.Second / time.Duration(first.sampleRate)
+	probe.Bitrate = averageBitrate(probe.AudioBytes, probe.Duration)
+	return probe, nil
+}
+
+// scanMP3Frames walks all frame headers and counts the samples, skipping the Xing/VBRI info frame
+func scanMP3Frames(r io.Reader, skipFirst bool) (int64, int, error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var samples int64
+	frames := 0
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		h, ok := parseMP3FrameHeader(header)
+		if !ok {
+			// trailing garbage or an APE tag ends the audio
+			break
+		}
+		if _, err := reader.Discard(h.frameLength() - 4); err != nil {
+			break
+		}
+		if skipFirst {
+			skipFirst = false
+			continue
+		}
+		samples += int64(h.samplesPerFrame())
+		frames++
+	}
+	if frames == 0 {
+		return 0, 0, fmt.Errorf("no MPEG audio frames found")
+	}
+	return samples, frames, nil
+}
+
+func averageBitrate(audioBytes int64, duration time.Duration) int {
+	if duration <= 0 {
+		return 0
+	}
+	return int(float64(audioBytes*8) / duration.Seconds() / 1000)
+}
+
+// probeLocalAudio probes a local file, MP3s by reading every frame header
+func probeLocalAudio(filePath string) (AudioProbe, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return AudioProbe{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return AudioProbe{}, err
+	}
+	return probeAudio(file, info.Size(), filePath, true)
+}
+
+// httpRangeReader reads parts of a remote file with ranged GET requests
+type httpRangeReader struct {
+	url string
+}
+
+func (h httpRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("%s does not support range requests (status code %d)", h.url, resp.StatusCode)
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// probeRemoteAudio probes a file via HTTP HEAD and ranged GETs of its beginning and end
+func probeRemoteAudio(fileURL string) (AudioProbe, error) {
+	resp, err := http.Head(fileURL)
+	if err != nil {
+		return AudioProbe{}, err
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return AudioProbe{}, fmt.Errorf("%s returned status code %d", fileURL, resp.StatusCode)
+	}
+	if resp.ContentLength <= 0 {
+		return AudioProbe{}, fmt.Errorf("%s didn't report a content length", fileURL)
+	}
+	return probeAudio(httpRangeReader{url: fileURL}, resp.ContentLength, fileURL, false)
+}
+
+// formatDuration formats a duration like the chapter marks: HH:MM:SS.mmm
+func formatDuration(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// soundFileNameFromAudioURL returns the file name of an audio URL like $media_base_url/2024_01_15-chaos-im-radio.mp3
+func soundFileNameFromAudioURL(audioURL string) string {
+	return path.Base(strings.TrimPrefix(audioURL, "$media_base_url/"))
+}
+
+// probeSoundFile probes a sound file in the local -sound-dir if it exists there, otherwise online with -file-online
+func probeSoundFile(fileName string, config *Config) (AudioProbe, error) {
+	if config.SoundDir != "" {
+		localPath := filepath.Join(config.SoundDir, fileName)
+		if _, err := os.Stat(localPath); err == nil {
+			return probeLocalAudio(localPath)
+		}
+	}
+	if config.FileOnline {
+		fileURL, err := url.JoinPath(config.FileBaseURL, fileName)
+		if err != nil {
+			return AudioProbe{}, fmt.Errorf("failed to construct file URL: %v", err)
+		}
+		return probeRemoteAudio(fileURL)
+	}
+	return AudioProbe{}, fmt.Errorf("sound file %s not found", fileName)
+}
+
+// probeEntryAudio stores size and duration of every audio file of the entry, failures become processing warnings
+func probeEntryAudio(entry *CiREntry, config *Config) {
+	if config.SoundDir == "" && !config.FileOnline {
+		return
+	}
+	// files moved in by ingest were validated already, their manifest record is trusted
+	manifest := &IngestManifest{}
+	if config.IngestManifestPath != "" {
+		if loaded, err := loadIngestManifest(config.IngestManifestPath); err == nil {
+			manifest = loaded
+		}
+	}
+	for i := range entry.Audio {
+		audio := &entry.Audio[i]
+		fileName := soundFileNameFromAudioURL(audio.Url)
+		if record, ok := manifest.ingested(fileName); ok && record.Size > 0 && record.Duration != "" {
+			audio.Size = record.Size
+			audio.Duration = record.Duration
+			audio.SHA256 = record.SHA256
+			continue
+		}
+		probe, err := probeSoundFile(fileName, config)
+		if err != nil {
+			entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("could not probe sound file %s: %v", fileName, err))
+			continue
+		}
+		audio.Size = probe.Size
+		audio.Duration = formatDuration(probe.Duration)
+		if digest, err := localSoundFileDigest(fileName, config); err == nil {
+			audio.SHA256 = digest
+		}
+		logrus.Debugf("Probed %s: %d bytes, %s, %d kbit/s, %d Hz", fileName, probe.Size, audio.Duration, probe.Bitrate, probe.SampleRate)
+	}
+}
+
+// runProbeCommand fills in size and duration of all entries in the YAML file that don't have them yet
+func runProbeCommand(logger *logrus.Logger, config *Config) error {
+	if config.SoundDir == "" && !config.FileOnline {
+		return fmt.Errorf("probe needs -sound-dir or -file-online")
+	}
+
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	var orderedEntries []EntryWithOrder
+	probed := 0
+	for _, entry := range entries {
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: entry})
+		for i := range entry.Audio {
+			audio := &entry.Audio[i]
+			if audio.Size > 0 && audio.Duration != "" {
+				continue
+			}
+			fileName := soundFileNameFromAudioURL(audio.Url)
+			probe, err := probeSoundFile(fileName, config)
+			if err != nil {
+				logger.Warnf("Could not probe %s of %s: %v", fileName, entry.UUID, err)
+				continue
+			}
+			audio.Size = probe.Size
+			audio.Duration = formatDuration(probe.Duration)
+			probed++
+			logger.Infof("%s: %d bytes, %s, %d kbit/s, %d Hz", fileName, probe.Size, audio.Duration, probe.Bitrate, probe.SampleRate)
+		}
+	}
+
+	logger.Infof("Probed %d sound files", probed)
+	if probed == 0 {
+		return nil
+	}
+	return writeAllYAMLEntries(orderedEntries, config.ContentFilePath)
+}
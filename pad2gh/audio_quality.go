@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// thresholds of the audio quality check
+const (
+	targetLoudness     = -16.0 // LUFS, the usual podcast target
+	loudnessTolerance  = 3.0   // LU
+	maxTruePeak        = -1.0  // dBTP
+	silenceThreshold   = -60.0 // dBFS, quieter 100ms blocks count as silence
+	maxDeadAir         = 5 * time.Second
+	maxSilence         = 10 * time.Second
+	clippedRunLength   = 3 // consecutive full scale samples counted as one clipping event
+	qualityBlockLength = 100 * time.Millisecond
+)
+
+// AudioSpan is a stretch of an episode
+type AudioSpan struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// AudioQuality are the measurements of the quality check
+type AudioQuality struct {
+	Duration          time.Duration
+	Loudness          float64 // integrated loudness in LUFS (EBU R128)
+	TruePeak          float64 // dBTP
+	LeadingSilence    time.Duration
+	TrailingSilence   time.Duration
+	Silences          []AudioSpan // silences of at least maxSilence within the episode
+	ClippingEvents    int
+	FirstClippingTime time.Duration
+}
+
+// biquad is a second order IIR filter in direct form I
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	if math.Abs(y) < 1e-20 {
+		// decaying into denormals makes silence very slow to filter
+		y = 0
+	}
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the two filters of ITU-R BS.1770 for any sample rate
+func kWeighting(sampleRate int) (biquad, biquad) {
+	fs := float64(sampleRate)
+
+	// high shelf modelling the head
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// RLB high pass
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// truePeakPhases is the oversampling factor of the true peak measurement of ITU-R BS.1770, truePeakTaps the length
+// of the interpolation filter of every phase
+const (
+	truePeakPhases = 4
+	truePeakTaps   = 12
+)
+
+// truePeakCoefficients split a 48 tap Blackman windowed sinc lowpass into its four phases, every phase interpolates the
+// signal at another position between two samples
+var truePeakCoefficients = func() [truePeakPhases][truePeakTaps]float64 {
+	var c [truePeakPhases][truePeakTaps]float64
+	length := truePeakPhases * truePeakTaps
+	center := float64(length-1) / 2
+	for n := 0; n < length; n++ {
+		x := (float64(n) - center) / truePeakPhases
+		w := 2 * math.Pi * float64(n) / float64(length-1)
+		window := 0.42 - 0.5*math.Cos(w) + 0.08*math.Cos(2*w)
+		c[n%truePeakPhases][n/truePeakPhases] = window * math.Sin(math.Pi*x) / (math.Pi * x)
+	}
+	// every phase passes a constant signal unchanged
+	for p := range c {
+		var sum float64
+		for _, coefficient := range c[p] {
+			sum += coefficient
+		}
+		for k := range c[p] {
+			c[p][k] /= sum
+		}
+	}
+	return c
+}()
+
+// truePeakGain is the largest sum of the absolute coefficients of a phase, no interpolated value exceeds the largest
+// sample of the history times this
+var truePeakGain = func() float64 {
+	var gain float64
+	for _, coefficients := range truePeakCoefficients {
+		var sum float64
+		for _, coefficient := range coefficients {
+			sum += math.Abs(coefficient)
+		}
+		gain = math.Max(gain, sum)
+	}
+	return gain
+}()
+
+// qualityChannel is the per channel state of the analyzer
+type qualityChannel struct {
+	shelf, highPass biquad
+	history         [truePeakTaps]float64
+	clippedRun      int
+}
+
+// qualityAnalyzer measures loudness, peaks, silence and clipping of a stream of samples
+type qualityAnalyzer struct {
+	sampleRate  int
+	channels    []qualityChannel // one for mono files, which are decoded to both channels
+	samples     int64
+	blockSize   int
+	blockEnergy float64 // K-weighted energy of the current 100ms block
+	blockPower  float64 // unweighted energy of the current 100ms block
+	blockFill   int
+	energies    []float64 // mean square of every 100ms block, for the 400ms gating blocks
+	peak        float64
+
+	silentSince    int // index of the first block of the current silence, -1 while not silent
+	firstSound     int // index of the first non-silent block, -1 until found
+	silences       []AudioSpan
+	clippingEvents int
+	firstClipping  int64
+}
+
+func newQualityAnalyzer(sampleRate int, mono bool) *qualityAnalyzer {
+	channels := 2
+	if mono {
+		channels = 1
+	}
+	a := &qualityAnalyzer{
+		sampleRate:  sampleRate,
+		channels:    make([]qualityChannel, channels),
+		blockSize:   int(int64(sampleRate) * int64(qualityBlockLength) / int64(time.Second)),
+		silentSince: 0,
+		firstSound:  -1,
+	}
+	for i := range a.channels {
+		a.channels[i].shelf, a.channels[i].highPass = kWeighting(sampleRate)
+	}
+	return a
+}
+
+func (a *qualityAnalyzer) sampleTime(sample int64) time.Duration {
+	return time.Duration(sample * int64(time.Second) / int64(a.sampleRate))
+}
+
+func (a *qualityAnalyzer) blockTime(block int) time.Duration {
+	return time.Duration(block) * qualityBlockLength
+}
+
+// add analyzes the next samples of both channels, only the left one of a mono file. The K-weighted energy of the
+// channels is summed, so a mono file isn't measured 3 LU too loud as dual mono.
+func (a *qualityAnalyzer) add(left, right []float64) {
+	for i := range left {
+		samples := [2]float64{left[i], right[i]}
+		for c, x := range samples[:len(a.channels)] {
+			channel := &a.channels[c]
+
+			weighted := channel.highPass.process(channel.shelf.process(x))
+			a.blockEnergy += weighted * weighted
+			a.blockPower += x * x / float64(len(a.channels))
+
+			a.updatePeak(channel, x)
+
+			if math.Abs(x) >= 32767.0/32768 {
+				channel.clippedRun++
+				if channel.clippedRun == clippedRunLength {
+					if a.clippingEvents == 0 {
+						a.firstClipping = a.samples
+					}
+					a.clippingEvents++
+				}
+			} else {
+				channel.clippedRun = 0
+			}
+		}
+
+		a.samples++
+		a.blockFill++
+		if a.blockFill == a.blockSize {
+			a.finishBlock()
+		}
+	}
+}
+
+// updatePeak feeds the sample into the oversampling filter and remembers the highest sample or interpolated value
+func (a *qualityAnalyzer) updatePeak(channel *qualityChannel, x float64) {
+	copy(channel.history[:], channel.history[1:])
+	channel.history[truePeakTaps-1] = x
+
+	var largest float64
+	for _, sample := range channel.history {
+		largest = math.Max(largest, math.Abs(sample))
+	}
+	a.peak = math.Max(a.peak, math.Abs(x))
+	if largest*truePeakGain <= a.peak {
+		return
+	}
+	for _, coefficients := range truePeakCoefficients {
+		var y float64
+		for k, coefficient := range coefficients {
+			y += coefficient * channel.history[k]
+		}
+		a.peak = math.Max(a.peak, math.Abs(y))
+	}
+}
+
+func (a *qualityAnalyzer) finishBlock() {
+	block := len(a.energies)
+	a.energies = append(a.energies, a.blockEnergy/float64(a.blockFill))
+
+	silent := a.blockPower/float64(a.blockFill) < math.Pow(10, silenceThreshold/10)
+	switch {
+	case silent && a.silentSince < 0:
+		a.silentSince = block
+	case !silent && a.silentSince >= 0:
+		if a.firstSound >= 0 && a.blockTime(block-a.silentSince) >= maxSilence {
+			a.silences = append(a.silences, AudioSpan{Start: a.blockTime(a.silentSince), End: a.blockTime(block)})
+		}
+		a.silentSince = -1
+	}
+	if !silent && a.firstSound < 0 {
+		a.firstSound = block
+	}
+
+	a.blockEnergy, a.blockPower, a.blockFill = 0, 0, 0
+}
+
+// integratedLoudness gates the 400ms blocks as described in EBU R128 and returns the loudness in LUFS
+func integratedLoudness(energies []float64) float64 {
+	loudness := func(energy float64) float64 {
+		return -0.691 + 10*math.Log10(energy)
+	}
+
+	var gatingBlocks []float64
+	for i := 3; i < len(energies); i++ {
+		gatingBlocks = append(gatingBlocks, (energies[i-3]+energies[i-2]+energies[i-1]+energies[i])/4)
+	}
+
+	mean := func(threshold float64) (float64, bool) {
+		sum, count := 0.0, 0
+		for _, energy := range gatingBlocks {
+			if energy > 0 && loudness(energy) > threshold {
+				sum += energy
+				count++
+			}
+		}
+		if count == 0 {
+			return 0, false
+		}
+		return sum / float64(count), true
+	}
+
+	absolute, ok := mean(-70)
+	if !ok {
+		return math.Inf(-1)
+	}
+	relative, ok := mean(loudness(absolute) - 10)
+	if !ok {
+		return math.Inf(-1)
+	}
+	return loudness(relative)
+}
+
+// result finishes the analysis, the analyzer must not be used afterwards
+func (a *qualityAnalyzer) result() AudioQuality {
+	if a.blockFill > 0 {
+		a.finishBlock()
+	}
+
+	quality := AudioQuality{
+		Duration:       a.sampleTime(a.samples),
+		Loudness:       integratedLoudness(a.energies),
+		TruePeak:       20 * math.Log10(a.peak),
+		Silences:       a.silences,
+		ClippingEvents: a.clippingEvents,
+	}
+	if a.clippingEvents > 0 {
+		quality.FirstClippingTime = a.sampleTime(a.firstClipping)
+	}
+	if a.firstSound < 0 {
+		quality.LeadingSilence = quality.Duration
+		return quality
+	}
+	quality.LeadingSilence = a.blockTime(a.firstSound)
+	if a.silentSince >= 0 {
+		quality.TrailingSilence = quality.Duration - a.blockTime(a.silentSince)
+	}
+	return quality
+}
+
+// warnings lists the findings worth a diagnostic on the entry
+func (q AudioQuality) warnings() []string {
+	var warnings []string
+	if q.LeadingSilence == q.Duration {
+		return []string{"audio is completely silent"}
+	}
+	if math.Abs(q.Loudness-targetLoudness) > loudnessTolerance {
+		warnings = append(warnings, fmt.Sprintf("integrated loudness is %.1f LUFS, expected %.0f LUFS", q.Loudness, targetLoudness))
+	}
+	if q.TruePeak > maxTruePeak {
+		warnings = append(warnings, fmt.Sprintf("true peak is %.1f dBTP, expected at most %.0f dBTP", q.TruePeak, maxTruePeak))
+	}
+	if q.ClippingEvents > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d clipped passages, the first at %s", q.ClippingEvents, formatDuration(q.FirstClippingTime)))
+	}
+	if q.LeadingSilence >= maxDeadAir {
+		warnings = append(warnings, fmt.Sprintf("%s of silence at the beginning", formatDuration(q.LeadingSilence)))
+	}
+	if q.TrailingSilence >= maxDeadAir {
+		warnings = append(warnings, fmt.Sprintf("%s of silence at the end", formatDuration(q.TrailingSilence)))
+	}
+	for _, silence := range q.Silences {
+		warnings = append(warnings, fmt.Sprintf("silence from %s to %s", formatDuration(silence.Start), formatDuration(silence.End)))
+	}
+	return warnings
+}
+
+// analyzeSoundFile decodes the sound file and measures its quality
+func analyzeSoundFile(fileName string, config *Config) (AudioQuality, error) {
+	file, err := openSoundFile(fileName, config)
+	if err != nil {
+		return AudioQuality{}, err
+	}
+	defer file.Close()
+
+	var analyzer *qualityAnalyzer
+	err = decodeAudio(file, func(sampleRate int, mono bool, left, right []float64) {
+		if analyzer == nil {
+			analyzer = newQualityAnalyzer(sampleRate, mono)
+		}
+		analyzer.add(left, right)
+	})
+	if err != nil {
+		return AudioQuality{}, err
+	}
+	if analyzer == nil {
+		return AudioQuality{}, fmt.Errorf("no audio frames in %s", fileName)
+	}
+	return analyzer.result(), nil
+}
+
+// checkEntryAudio runs the quality check on the audio files of the entry, findings become processing warnings
+func checkEntryAudio(entry *CiREntry, config *Config) {
+	if !config.CheckAudio {
+		return
+	}
+	for _, audio := range entry.Audio {
+		if !isMP3Audio(audio) {
+			// the other renditions are encoded from the same master
+			continue
+		}
+		fileName := soundFileNameFromAudioURL(audio.Url)
+		quality, err := analyzeSoundFile(fileName, config)
+		if err != nil {
+			entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("could not check audio of %s: %v", fileName, err))
+			continue
+		}
+		for _, warning := range quality.warnings() {
+			entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("%s: %s", fileName, warning))
+		}
+	}
+}
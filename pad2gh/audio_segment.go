@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// parameters of the speech/music segmentation
+const (
+	segmentFrameLength  = 20 * time.Millisecond
+	segmentWindowLength = time.Second
+	segmentSmoothing    = 9                // windows in the majority vote
+	speechLSTER         = 0.15             // share of low energy frames above which a window is speech
+	speechHZCRR         = 0.15             // share of high zero crossing rate frames above which a window is speech
+	minMusicSegment     = 45 * time.Second // shorter music is a jingle or a sound bite within the talk
+	minSpeechSegment    = 20 * time.Second // shorter talk between two music segments is a transition
+)
+
+// AudioSegment is a stretch of speech or music
+type AudioSegment struct {
+	Start time.Duration
+	End   time.Duration
+	Music bool
+}
+
+// MusicTrack is a track listed in the mukke section
+type MusicTrack struct {
+	Title string
+	Href  string
+}
+
+// segmentAnalyzer classifies one second windows as speech or music by the share of low energy frames (LSTER)
+// and of frames with a high zero crossing rate (HZCRR): talk has pauses between syllables and alternates voiced and
+// unvoiced sounds, music is much more even
+type segmentAnalyzer struct {
+	frameSize       int
+	framesPerWindow int
+
+	frameEnergy    float64
+	frameCrossings int
+	frameFill      int
+	previous       float64
+
+	energies  []float64
+	crossings []int
+	music     []bool // classification of every window
+}
+
+func newSegmentAnalyzer(sampleRate int) *segmentAnalyzer {
+	return &segmentAnalyzer{
+		frameSize:       int(int64(sampleRate) * int64(segmentFrameLength) / int64(time.Second)),
+		framesPerWindow: int(segmentWindowLength / segmentFrameLength),
+	}
+}
+
+func (a *segmentAnalyzer) add(left, right []float64) {
+	for i := range left {
+		x := (left[i] + right[i]) / 2
+		a.frameEnergy += x * x
+		if (x >= 0) != (a.previous >= 0) {
+			a.frameCrossings++
+		}
+		a.previous = x
+
+		a.frameFill++
+		if a.frameFill < a.frameSize {
+			continue
+		}
+		a.energies = append(a.energies, a.frameEnergy/float64(a.frameFill))
+		a.crossings = append(a.crossings, a.frameCrossings)
+		a.frameEnergy, a.frameCrossings, a.frameFill = 0, 0, 0
+
+		if len(a.energies) == a.framesPerWindow {
+			a.music = append(a.music, classifyWindow(a.energies, a.crossings))
+			a.energies, a.crossings = a.energies[:0], a.crossings[:0]
+		}
+	}
+}
+
+// classifyWindow reports whether the frames of a window sound like music
+func classifyWindow(energies []float64, crossings []int) bool {
+	meanEnergy, meanCrossings := 0.0, 0.0
+	for i := range energies {
+		meanEnergy += energies[i]
+		meanCrossings += float64(crossings[i])
+	}
+	meanEnergy /= float64(len(energies))
+	meanCrossings /= float64(len(crossings))
+
+	lowEnergy, highCrossings := 0, 0
+	for i := range energies {
+		if energies[i] < 0.5*meanEnergy {
+			lowEnergy++
+		}
+		if float64(crossings[i]) > 1.5*meanCrossings {
+			highCrossings++
+		}
+	}
+	lster := float64(lowEnergy) / float64(len(energies))
+	hzcrr := float64(highCrossings) / float64(len(crossings))
+	return lster < speechLSTER && hzcrr < speechHZCRR
+}
+
+// smoothClasses replaces every class by the majority of its neighbourhood
+func smoothClasses(music []bool, width int) []bool {
+	smoothed := make([]bool, len(music))
+	for i := range music {
+		votes, total := 0, 0
+		for j := i - width/2; j <= i+width/2; j++ {
+			if j < 0 || j >= len(music) {
+				continue
+			}
+			total++
+			if music[j] {
+				votes++
+			}
+		}
+		smoothed[i] = 2*votes > total
+	}
+	return smoothed
+}
+
+// classSegments joins consecutive windows of the same class
+func classSegments(music []bool, windowLength time.Duration) []AudioSegment {
+	var segments []AudioSegment
+	for i, m := range music {
+		start := time.Duration(i) * windowLength
+		if len(segments) > 0 && segments[len(segments)-1].Music == m {
+			segments[len(segments)-1].End = start + windowLength
+			continue
+		}
+		segments = append(segments, AudioSegment{Start: start, End: start + windowLength, Music: m})
+	}
+	return segments
+}
+
+// mergeShortSegments flips segments too short to be a song or a conversation and joins the neighbours
+func mergeShortSegments(segments []AudioSegment) []AudioSegment {
+	for i := range segments {
+		length := segments[i].End - segments[i].Start
+		switch {
+		case segments[i].Music && length < minMusicSegment:
+			segments[i].Music = false
+		case !segments[i].Music && length < minSpeechSegment && i > 0 && i < len(segments)-1:
+			segments[i].Music = true
+		}
+	}
+	return mergeAdjacentSegments(segments)
+}
+
+// segments returns the speech and music segments of everything analyzed so far
+func (a *segmentAnalyzer) segments() []AudioSegment {
+	return mergeShortSegments(classSegments(smoothClasses(a.music, segmentSmoothing), segmentWindowLength))
+}
+
+// musicLineRegex matches the music lines added to the long summary by populateEntryFromSections
+var musicLineRegex = regexp.MustCompile(`&#x1f3b6;&nbsp;\[(.*)\]\((.*)\)`)
+
+// musicTracks returns the tracks of the mukke section from the long summary of the entry
+func musicTracks(entry *CiREntry) []MusicTrack {
+	var tracks []MusicTrack
+	for _, match := range musicLineRegex.FindAllStringSubmatch(entry.LongSummaryMD, -1) {
+		tracks = append(tracks, MusicTrack{Title: match[1], Href: match[2]})
+	}
+	return tracks
+}
+
+// suggestChapters pairs the music segments with the tracks in the order they were played, talk between them
+// gets placeholder titles. If the numbers don't match, the longest music segments are used and a note is returned.
+func suggestChapters(segments []AudioSegment, tracks []MusicTrack) ([]CiRChapter, []string) {
+	var notes []string
+
+	var music []int
+	for i, segment := range segments {
+		if segment.Music {
+			music = append(music, i)
+		}
+	}
+	if len(music) > len(tracks) {
+		notes = append(notes, fmt.Sprintf("found %d music segments but only %d tracks in the mukke section, using the longest", len(music), len(tracks)))
+		for len(music) > len(tracks) {
+			shortest := 0
+			for j, i := range music {
+				if segments[i].End-segments[i].Start < segments[music[shortest]].End-segments[music[shortest]].Start {
+					shortest = j
+				}
+			}
+			segments[music[shortest]].Music = false
+			music = append(music[:shortest], music[shortest+1:]...)
+		}
+		segments = mergeAdjacentSegments(segments)
+	} else if len(music) < len(tracks) {
+		notes = append(notes, fmt.Sprintf("found only %d music segments for %d tracks in the mukke section", len(music), len(tracks)))
+	}
+
+	var chapters []CiRChapter
+	track, talk := 0, 0
+	for _, segment := range segments {
+		// whole seconds like the chapters written by hand, the boundaries aren't more precise anyway
+		chapter := CiRChapter{Start: formatChapterStart(segment.Start.Round(time.Second))}
+		switch {
+		case segment.Music && track < len(tracks):
+			chapter.Title = "Musik: " + tracks[track].Title
+			chapter.Href = tracks[track].Href
+			track++
+		case segment.Music:
+			chapter.Title = "Musik"
+		case talk == 0:
+			chapter.Title = "Begrüßung"
+			talk++
+		default:
+			talk++
+			chapter.Title = fmt.Sprintf("Gespräch %d", talk-1)
+		}
+		chapters = append(chapters, chapter)
+	}
+	return chapters, notes
+}
+
+// mergeAdjacentSegments joins neighbours of the same class
+func mergeAdjacentSegments(segments []AudioSegment) []AudioSegment {
+	var merged []AudioSegment
+	for _, segment := range segments {
+		if len(merged) > 0 && merged[len(merged)-1].Music == segment.Music {
+			merged[len(merged)-1].End = segment.End
+			continue
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+// segmentSoundFile decodes the sound file and splits it into speech and music
+func segmentSoundFile(fileName string, config *Config) ([]AudioSegment, error) {
+	file, err := openSoundFile(fileName, config)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var analyzer *segmentAnalyzer
+	err = decodeAudio(file, func(sampleRate int, _ bool, left, right []float64) {
+		if analyzer == nil {
+			analyzer = newSegmentAnalyzer(sampleRate)
+		}
+		analyzer.add(left, right)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if analyzer == nil {
+		return nil, fmt.Errorf("no audio frames in %s", fileName)
+	}
+	return analyzer.segments(), nil
+}
+
+// suggestEntryChapters proposes chapters for entries without a chapters section, the proposal is only shown in the PR.
+// A transcript in -whisper-dir gives titles from the shownotes, otherwise -suggest-chapters only tells music from talk.
+func suggestEntryChapters(entry *CiREntry, config *Config) {
+	if len(entry.Chapters) > 0 || suggestEntryChaptersFromTranscript(entry, config) {
+		return
+	}
+	audio := entryMP3(entry)
+	if !config.SuggestChapters || audio == nil {
+		return
+	}
+	fileName := soundFileNameFromAudioURL(audio.Url)
+	segments, err := segmentSoundFile(fileName, config)
+	if err != nil {
+		entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("could not suggest chapters for %s: %v", fileName, err))
+		return
+	}
+	chapters, notes := suggestChapters(segments, musicTracks(entry))
+	entry.suggestedChapters = chapters
+	for _, note := range notes {
+		entry.processingWarnings = append(entry.processingWarnings, "chapter suggestion: "+note)
+	}
+}
+
+// chaptersYAML renders chapters the way they are written to content.yaml
+func chaptersYAML(chapters []CiRChapter) (string, error) {
+	node := &yaml.Node{}
+	err := node.Encode(struct {
+		Chapters []CiRChapter `yaml:"chapters"`
+	}{chapters})
+	if err != nil {
+		return "", err
+	}
+	setSingleQuoteStyle(node)
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	err = encoder.Encode(node)
+	if err != nil {
+		return "", err
+	}
+	err = encoder.Close()
+	return b.String(), err
+}
+
+// chaptersPadBlock renders chapters as the chapters section of the pad, tracks as their link like the moderators
+// write them, so the import takes the title from the track page
+func chaptersPadBlock(chapters []CiRChapter) string {
+	var b strings.Builder
+	b.WriteString("## Kapitel\n\n")
+	for _, chapter := range chapters {
+		title := chapter.Title
+		if chapter.Href != "" {
+			title = chapter.Href
+		}
+		b.WriteString(chapter.Start + " " + title + "\n")
+	}
+	return b.String()
+}
+
+// runSegmentCommand prints the chapters suggested for the YAML entry given with -entry
+func runSegmentCommand(logger *logrus.Logger, config *Config) error {
+	if config.EntryUUID == "" {
+		return fmt.Errorf("segment needs -entry <uuid>")
+	}
+	if config.SoundDir == "" && !config.FileOnline {
+		return fmt.Errorf("segment needs -sound-dir or -file-online")
+	}
+	entry, err := findEntryByUUID(config.ContentFilePath, config.EntryUUID)
+	if err != nil {
+		return err
+	}
+	audio := entryMP3(entry)
+	if audio == nil {
+		return fmt.Errorf("entry %s has no MP3", entry.UUID)
+	}
+
+	fileName := soundFileNameFromAudioURL(audio.Url)
+	logger.Infof("Decoding %s...", fileName)
+	segments, err := segmentSoundFile(fileName, config)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		kind := "speech"
+		if segment.Music {
+			kind = "music"
+		}
+		logger.Debugf("%s - %s %s", formatDuration(segment.Start), formatDuration(segment.End), kind)
+	}
+
+	chapters, notes := suggestChapters(segments, musicTracks(entry))
+	for _, note := range notes {
+		logger.Warn(note)
+	}
+	if len(entry.Chapters) > 0 {
+		logger.Warnf("%s already has %d chapters, the suggestion is not written to %s", entry.UUID, len(entry.Chapters), config.ContentFilePath)
+	}
+
+	suggestion, err := chaptersYAML(chapters)
+	if err != nil {
+		return fmt.Errorf("failed to encode chapters: %v", err)
+	}
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = out.Write([]byte(suggestion))
+	return err
+}
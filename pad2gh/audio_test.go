@@ -0,0 +1,653 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// buildTestMP3 returns an MPEG 1 layer III stream with 128 kbit/s at 44.1 kHz stereo,
+// optionally preceded by an empty ID3v2 tag and a Xing info frame
+func buildTestMP3(frames int, withID3, withXing bool) []byte {
+	var b bytes.Buffer
+	if withID3 {
+		// ID3v2.3 header with 100 bytes of padding as tag size
+		b.Write([]byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 100})
+		b.Write(make([]byte, 100))
+	}
+	header := []byte{0xFF, 0xFB, 0x90, 0x00}
+	if withXing {
+		xing := make([]byte, 417)
+		copy(xing, header)
+		copy(xing[36:], "Xing")
+		binary.BigEndian.PutUint32(xing[40:], 0x01)
+		binary.BigEndian.PutUint32(xing[44:], uint32(frames))
+		b.Write(xing)
+	}
+	// like an encoder, pad frames to 417.96 bytes on average to keep exactly 128 kbit/s
+	for i := 0; i < frames; i++ {
+		frame := make([]byte, 144*128000*(i+1)/44100-144*128000*i/44100)
+		copy(frame, header)
+		if len(frame) == 418 {
+			frame[2] |= 0x02
+		}
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func TestProbeMP3(t *testing.T) {
+	frames := 1000
+	expectedDuration := time.Duration(frames) * 1152 * time.Second / 44100
+
+	tests := []struct {
+		name     string
+		data     []byte
+		fullScan bool
+	}{
+		{name: "Full scan", data: buildTestMP3(frames, true, false), fullScan: true},
+		{name: "Full scan with Xing frame", data: buildTestMP3(frames, true, true), fullScan: true},
+		{name: "Xing header", data: buildTestMP3(frames, false, true), fullScan: false},
+		{name: "CBR estimate", data: buildTestMP3(frames, true, false), fullScan: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe, err := probeMP3(bytes.NewReader(tt.data), int64(len(tt.data)), tt.fullScan)
+			if err != nil {
+				t.Fatalf("probeMP3() failed: %v", err)
+			}
+			diff := probe.Duration - expectedDuration
+			if diff < -30*time.Millisecond || diff > 30*time.Millisecond {
+				t.Errorf("Expected duration %s, got %s", expectedDuration, probe.Duration)
+			}
+			if probe.SampleRate != 44100 {
+				t.Errorf("Expected sample rate 44100, got %d", probe.SampleRate)
+			}
+			if probe.Bitrate < 127 || probe.Bitrate > 129 {
+				t.Errorf("Expected bitrate 128, got %d", probe.Bitrate)
+			}
+			if probe.Size != int64(len(tt.data)) {
+				t.Errorf("Expected size %d, got %d", len(tt.data), probe.Size)
+			}
+		})
+	}
+}
+
+func TestProbeEntryAudio(t *testing.T) {
+	soundDir := t.TempDir()
+	data := buildTestMP3(100, true, false)
+	err := os.WriteFile(filepath.Join(soundDir, "2024_01_15-chaos-im-radio.mp3"), data, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to create sound file: %v", err)
+	}
+
+	entry := createMockEntry("https://pad.ccc-p.org/Radio_2024-01-15_test1", "2024-01-15")
+	probeEntryAudio(entry, &Config{SoundDir: soundDir})
+
+	if entry.Audio[0].Size != int64(len(data)) {
+		t.Errorf("Expected size %d, got %d", len(data), entry.Audio[0].Size)
+	}
+	if entry.Audio[0].Duration != "00:00:02.612" {
+		t.Errorf("Expected duration 00:00:02.612, got %s", entry.Audio[0].Duration)
+	}
+}
+
+func TestID3TagRoundTrip(t *testing.T) {
+	entry := &CiREntry{
+		UUID:            "nt-2024-01-15",
+		Title:           "CiR am 15.01.2024",
+		PublicationDate: "2024-01-15T00:00:00+00:00",
+		Chapters: []CiRChapter{
+			{Start: "00:00:00", Title: "Begrüßung"},
+			{Start: "00:00:01.500", Title: "Musik: Sad Robot", Href: "https://www.jamendo.com/track/81740/sad-robot"},
+		},
+	}
+	cover := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x00, 0xFF, 0xD9}
+
+	for _, version := range []byte{3, 4} {
+		soundFile := filepath.Join(t.TempDir(), "2024_01_15-chaos-im-radio.mp3")
+		audio := buildTestMP3(100, false, false)
+		err := os.WriteFile(soundFile, audio, 0o644)
+		if err != nil {
+			t.Fatalf("Failed to create sound file: %v", err)
+		}
+
+		tag, err := buildID3Tag(entry, cover, 2612*time.Millisecond, version)
+		if err != nil {
+			t.Fatalf("buildID3Tag() failed: %v", err)
+		}
+		err = writeID3Tag(soundFile, tag)
+		if err != nil {
+			t.Fatalf("writeID3Tag() failed: %v", err)
+		}
+
+		written, err := readID3Tag(soundFile)
+		if err != nil {
+			t.Fatalf("readID3Tag() failed: %v", err)
+		}
+		if differences := compareID3Tags(tag, written); len(differences) > 0 {
+			t.Errorf("ID3v2.%d differs after round trip: %v", version, differences)
+		}
+		if len(written.Pictures) != 1 || !bytes.Equal(written.Pictures[0].Data, cover) {
+			t.Errorf("ID3v2.%d cover not preserved", version)
+		}
+		if written.Chapters[1].End != 2612*time.Millisecond {
+			t.Errorf("ID3v2.%d expected last chapter to end at the duration, got %s", version, written.Chapters[1].End)
+		}
+
+		// the audio frames must be copied unchanged
+		content, _ := os.ReadFile(soundFile)
+		if !bytes.Equal(content[written.tagLength:], audio) {
+			t.Errorf("ID3v2.%d audio changed by tagging", version)
+		}
+
+		// tagging again replaces the tag instead of stacking a second one
+		err = writeID3Tag(soundFile, tag)
+		if err != nil {
+			t.Fatalf("writeID3Tag() second time failed: %v", err)
+		}
+		content, _ = os.ReadFile(soundFile)
+		if !bytes.Equal(content[written.tagLength:], audio) {
+			t.Errorf("ID3v2.%d audio changed by tagging twice", version)
+		}
+	}
+}
+
+func TestCheckEntryTag(t *testing.T) {
+	entry := &CiREntry{
+		Title:           "CiR am 15.01.2024",
+		PublicationDate: "2024-01-15T00:00:00+00:00",
+		Chapters: []CiRChapter{
+			{Start: "00:00:00", Title: "Begrüßung"},
+			{Start: "00:12:30", Title: "News"},
+		},
+	}
+	cover := []byte{0xFF, 0xD8, 0xFF, 0xD9}
+
+	tests := []struct {
+		name     string
+		modify   func(tag *ID3Tag)
+		problems int
+	}{
+		{"matching", func(tag *ID3Tag) {}, 0},
+		{"year only", func(tag *ID3Tag) { delete(tag.Text, "TDAT") }, 0},
+		{"wrong title", func(tag *ID3Tag) { tag.Text["TIT2"] = "CiR am 08.01.2024" }, 1},
+		{"wrong day", func(tag *ID3Tag) { tag.Text["TDAT"] = "1601" }, 1},
+		{"no cover", func(tag *ID3Tag) { tag.Pictures = nil }, 1},
+		{"moved chapter", func(tag *ID3Tag) { tag.Chapters[1].Start += time.Second }, 1},
+		{"missing chapter", func(tag *ID3Tag) { tag.Chapters = tag.Chapters[:1] }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, err := buildID3Tag(entry, cover, time.Hour, 3)
+			if err != nil {
+				t.Fatalf("buildID3Tag() failed: %v", err)
+			}
+			tt.modify(tag)
+			if problems := checkEntryTag(entry, tag); len(problems) != tt.problems {
+				t.Errorf("checkEntryTag() = %v, expected %d problems", problems, tt.problems)
+			}
+		})
+	}
+}
+
+// sineWave returns seconds of a 997 Hz sine with the given amplitude at 48 kHz
+func sineWave(seconds float64, amplitude float64) []float64 {
+	samples := make([]float64, int(seconds*48000))
+	for i := range samples {
+		samples[i] = amplitude * math.Sin(2*math.Pi*997*float64(i)/48000)
+	}
+	return samples
+}
+
+func TestQualityAnalyzer(t *testing.T) {
+	// a sine at a quarter of the sample rate whose samples all lie 3 dB below its peak
+	interSample := make([]float64, 20*48000)
+	for i := range interSample {
+		interSample[i] = 0.1 * math.Sin(math.Pi/2*float64(i)+math.Pi/4)
+	}
+
+	tests := []struct {
+		name     string
+		mono     bool
+		samples  [][]float64
+		check    func(q AudioQuality) bool
+		warnings int
+	}{
+		{
+			name:     "Sine at -16 dBFS is at the target loudness",
+			samples:  [][]float64{sineWave(20, math.Pow(10, -16.0/20))},
+			check:    func(q AudioQuality) bool { return math.Abs(q.Loudness+16) < 0.2 && math.Abs(q.TruePeak+16) < 0.2 },
+			warnings: 0,
+		},
+		{
+			name:     "Mono sine at -13 dBFS is at the target loudness",
+			mono:     true,
+			samples:  [][]float64{sineWave(20, math.Pow(10, -13.0/20))},
+			check:    func(q AudioQuality) bool { return math.Abs(q.Loudness+16) < 0.2 },
+			warnings: 0,
+		},
+		{
+			name:     "Peak between the samples",
+			samples:  [][]float64{interSample},
+			check:    func(q AudioQuality) bool { return math.Abs(q.TruePeak+20) < 0.3 },
+			warnings: 0,
+		},
+		{
+			name:     "Too quiet",
+			samples:  [][]float64{sineWave(20, 0.01)},
+			check:    func(q AudioQuality) bool { return math.Abs(q.Loudness+40) < 0.2 },
+			warnings: 1,
+		},
+		{
+			name:    "Dead air at both ends and a silence in between",
+			samples: [][]float64{make([]float64, 6*48000), sineWave(10, 0.158), make([]float64, 12*48000), sineWave(10, 0.158), make([]float64, 60*48000)},
+			check: func(q AudioQuality) bool {
+				return q.LeadingSilence == 6*time.Second && q.TrailingSilence == 60*time.Second && len(q.Silences) == 1
+			},
+			warnings: 3,
+		},
+		{
+			name:     "Clipping",
+			samples:  [][]float64{sineWave(20, 2)},
+			check:    func(q AudioQuality) bool { return q.ClippingEvents > 1000 && q.TruePeak >= 0 },
+			warnings: 3,
+		},
+		{
+			name:     "Silent",
+			samples:  [][]float64{make([]float64, 48000)},
+			check:    func(q AudioQuality) bool { return q.LeadingSilence == time.Second },
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := newQualityAnalyzer(48000, tt.mono)
+			for _, samples := range tt.samples {
+				clipped := make([]float64, len(samples))
+				for i, x := range samples {
+					clipped[i] = math.Max(-1, math.Min(32767.0/32768, x))
+				}
+				analyzer.add(clipped, clipped)
+			}
+			quality := analyzer.result()
+			if !tt.check(quality) {
+				t.Errorf("unexpected measurements %+v", quality)
+			}
+			if warnings := quality.warnings(); len(warnings) != tt.warnings {
+				t.Errorf("warnings() = %v, expected %d", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestSkipID3AndCheckMono(t *testing.T) {
+	stereo := buildTestMP3(3, true, false)
+	mono := bytes.ReplaceAll(stereo, []byte{0xFF, 0xFB, 0x90, 0x00}, []byte{0xFF, 0xFB, 0x90, 0xC0})
+	for name, tt := range map[string]struct {
+		data []byte
+		mono bool
+	}{"stereo": {stereo, false}, "mono": {mono, true}} {
+		br := bufio.NewReader(bytes.NewReader(tt.data))
+		isMono, err := skipID3AndCheckMono(br)
+		if err != nil || isMono != tt.mono {
+			t.Errorf("%s: skipID3AndCheckMono() = %v, %v", name, isMono, err)
+		}
+		if b, _ := br.Peek(2); !bytes.Equal(b, []byte{0xFF, 0xFB}) {
+			t.Errorf("%s: expected the ID3 tag to be skipped, next bytes are %x", name, b)
+		}
+	}
+}
+
+func TestCheckEntryAudio(t *testing.T) {
+	soundDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(soundDir, "2024_01_15-chaos-im-radio.mp3"), buildTestMP3(100, true, false), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to create sound file: %v", err)
+	}
+
+	entry := &CiREntry{Audio: []CiRaudio{{Url: "https://www.chaos-im-radio.de/files/2024_01_15-chaos-im-radio.mp3"}}}
+	checkEntryAudio(entry, &Config{SoundDir: soundDir, CheckAudio: true})
+	if len(entry.processingWarnings) != 1 || entry.processingWarnings[0] != "2024_01_15-chaos-im-radio.mp3: audio is completely silent" {
+		t.Errorf("expected the empty frames to be reported as silent, got %v", entry.processingWarnings)
+	}
+}
+
+// speechLike returns syllables of a voiced sound separated by short pauses at 8 kHz
+func speechLike(seconds int) []float64 {
+	samples := make([]float64, seconds*8000)
+	for i := range samples {
+		if i%2000 < 1200 {
+			samples[i] = 0.3*math.Sin(2*math.Pi*180*float64(i)/8000) + 0.1*math.Sin(2*math.Pi*540*float64(i)/8000)
+		}
+	}
+	return samples
+}
+
+// musicLike returns a steady chord at 8 kHz
+func musicLike(seconds int) []float64 {
+	samples := make([]float64, seconds*8000)
+	for i := range samples {
+		t := float64(i) / 8000
+		samples[i] = 0.2*math.Sin(2*math.Pi*220*t) + 0.2*math.Sin(2*math.Pi*277*t) + 0.2*math.Sin(2*math.Pi*330*t)
+	}
+	return samples
+}
+
+func TestSegmentAnalyzer(t *testing.T) {
+	analyzer := newSegmentAnalyzer(8000)
+	for _, samples := range [][]float64{speechLike(60), musicLike(120), speechLike(40), musicLike(10), speechLike(40), musicLike(100), speechLike(30)} {
+		analyzer.add(samples, samples)
+	}
+
+	segments := analyzer.segments()
+	expected := []AudioSegment{
+		{Start: 0, End: 60 * time.Second},
+		{Start: 60 * time.Second, End: 180 * time.Second, Music: true},
+		{Start: 180 * time.Second, End: 270 * time.Second},
+		{Start: 270 * time.Second, End: 370 * time.Second, Music: true},
+		{Start: 370 * time.Second, End: 400 * time.Second},
+	}
+	if len(segments) != len(expected) {
+		t.Fatalf("segments() = %+v, expected %d segments", segments, len(expected))
+	}
+	for i, segment := range segments {
+		if segment.Music != expected[i].Music || (segment.Start-expected[i].Start).Abs() > 2*time.Second {
+			t.Errorf("segment %d = %+v, expected %+v", i, segment, expected[i])
+		}
+	}
+
+	entry := &CiREntry{LongSummaryMD: "**Shownotes:**\n\nfoo\n\n**Musik:**\n" +
+		"\n&#x1f3b6;&nbsp;[Sad Robot](https://www.jamendo.com/track/81740/sad-robot)" +
+		"\n&#x1f3b6;&nbsp;[Welcome](https://www.jamendo.com/track/1/welcome)"}
+	chapters, notes := suggestChapters(segments, musicTracks(entry))
+	if len(notes) > 0 {
+		t.Errorf("unexpected notes %v", notes)
+	}
+	titles := []string{"Begrüßung", "Musik: Sad Robot", "Gespräch 1", "Musik: Welcome", "Gespräch 2"}
+	if len(chapters) != len(titles) {
+		t.Fatalf("suggestChapters() = %+v, expected %d chapters", chapters, len(titles))
+	}
+	for i, chapter := range chapters {
+		if chapter.Title != titles[i] {
+			t.Errorf("chapter %d is %q, expected %q", i, chapter.Title, titles[i])
+		}
+	}
+	if chapters[0].Start != "00:00:00" || chapters[1].Href != "https://www.jamendo.com/track/81740/sad-robot" {
+		t.Errorf("unexpected first chapters %+v", chapters[:2])
+	}
+}
+
+func TestSuggestChaptersWithMoreMusicThanTracks(t *testing.T) {
+	segments := []AudioSegment{
+		{Start: 0, End: 60 * time.Second, Music: true},
+		{Start: 60 * time.Second, End: 600 * time.Second},
+		{Start: 600 * time.Second, End: 800 * time.Second, Music: true},
+		{Start: 800 * time.Second, End: 900 * time.Second},
+	}
+	chapters, notes := suggestChapters(segments, []MusicTrack{{Title: "Sad Robot"}})
+	if len(notes) != 1 {
+		t.Errorf("expected a note about the unmatched music, got %v", notes)
+	}
+	if len(chapters) != 3 || chapters[0].Title != "Begrüßung" || chapters[1].Title != "Musik: Sad Robot" || chapters[1].Start != "00:10:00" {
+		t.Errorf("unexpected chapters %+v", chapters)
+	}
+}
+
+func TestWaveformBuilder(t *testing.T) {
+	builder := newWaveformBuilder(48000)
+	samples := sineWave(2.5, 0.5)
+	builder.add(samples, samples)
+
+	waveform, duration := builder.result()
+	if waveform.SamplesPerPixel != 4800 || waveform.Length != 25 || len(waveform.Data) != 50 {
+		t.Fatalf("unexpected waveform size: %d samples per pixel, %d pixels, %d values", waveform.SamplesPerPixel, waveform.Length, len(waveform.Data))
+	}
+	if waveform.Data[0] != -64 || waveform.Data[1] != 64 {
+		t.Errorf("expected the first pixel to span -64..64, got %d..%d", waveform.Data[0], waveform.Data[1])
+	}
+	if duration != 2500*time.Millisecond {
+		t.Errorf("expected duration 2.5s, got %s", duration)
+	}
+
+	data, err := json.Marshal(waveform)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte(`{"version":2,"channels":1,"sample_rate":48000,"samples_per_pixel":4800,"bits":8,"length":25,"data":[-64,64,`)) {
+		t.Errorf("unexpected JSON %s", data[:100])
+	}
+}
+
+func TestWaveformForSoundFile(t *testing.T) {
+	soundDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(soundDir, "2024_01_15-chaos-im-radio.mp3"), buildTestMP3(100, true, false), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to create sound file: %v", err)
+	}
+
+	waveform, duration, err := waveformForSoundFile("2024_01_15-chaos-im-radio.mp3", &Config{SoundDir: soundDir})
+	if err != nil {
+		t.Fatalf("waveformForSoundFile() failed: %v", err)
+	}
+	// the decoder must agree with the frame count of the probe
+	if expected := 100 * 1152 * time.Second / 44100; duration != expected {
+		t.Errorf("expected duration %s, got %s", expected, duration)
+	}
+	if waveform.SampleRate != 44100 || waveform.Length != 27 {
+		t.Errorf("unexpected waveform %d Hz with %d pixels", waveform.SampleRate, waveform.Length)
+	}
+}
+
+// buildTestOgg returns an Ogg Opus file with the given pre-skip and final granule position
+func buildTestOgg(preSkip uint16, granule uint64) []byte {
+	page := func(granule uint64, packet []byte) []byte {
+		b := []byte("OggS\x00\x00")
+		b = binary.LittleEndian.AppendUint64(b, granule)
+		b = append(b, make([]byte, 12)...) // serial, sequence, checksum
+		b = append(b, 1, byte(len(packet)))
+		return append(b, packet...)
+	}
+	head := []byte("OpusHead\x01\x02")
+	head = binary.LittleEndian.AppendUint16(head, preSkip)
+	head = append(head, make([]byte, 7)...)
+
+	var b bytes.Buffer
+	b.Write(page(0, head))
+	b.Write(page(0, []byte("OpusTags")))
+	b.Write(make([]byte, 100000))
+	b.Write(page(granule, make([]byte, 100)))
+	return b.Bytes()
+}
+
+// buildTestMP4 returns an M4A file with the movie header after the media data
+func buildTestMP4(timescale, duration uint32) []byte {
+	box := func(boxType string, content []byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+		return append(append(b, boxType...), content...)
+	}
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+
+	var b bytes.Buffer
+	b.Write(box("ftyp", []byte("M4A \x00\x00\x00\x00")))
+	b.Write(box("mdat", make([]byte, 50000)))
+	b.Write(box("moov", append(box("mvhd", mvhd), box("trak", make([]byte, 20))...)))
+	return b.Bytes()
+}
+
+func TestProbeOtherFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+		duration time.Duration
+	}{
+		{name: "Opus", fileName: "a.opus", data: buildTestOgg(312, 48000*90+312), duration: 90 * time.Second},
+		{name: "M4A", fileName: "a.m4a", data: buildTestMP4(44100, 44100*75+22050), duration: 75500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe, err := probeAudio(bytes.NewReader(tt.data), int64(len(tt.data)), tt.fileName, false)
+			if err != nil {
+				t.Fatalf("probeAudio() failed: %v", err)
+			}
+			if probe.Duration != tt.duration {
+				t.Errorf("expected duration %s, got %s", tt.duration, probe.Duration)
+			}
+			if probe.Size != int64(len(tt.data)) {
+				t.Errorf("expected size %d, got %d", len(tt.data), probe.Size)
+			}
+		})
+	}
+}
+
+func TestAddAudioRenditions(t *testing.T) {
+	soundDir := t.TempDir()
+	files := map[string][]byte{
+		"2024_01_15-chaos-im-radio.mp3":  buildTestMP3(100, true, false),
+		"2024_01_15-chaos-im-radio.opus": buildTestOgg(312, 48000*2+312),
+		"2024_01_15-chaos-im-radio.m4a":  buildTestMP4(1000, 2000),
+		"2024_01_16-chaos-im-radio.ogg":  buildTestOgg(0, 0),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(soundDir, name), data, 0o644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		preference string
+		expected   []string
+	}{
+		{preference: "opus,mp3,m4a,ogg", expected: []string{"audio/ogg", "audio/mpeg", "audio/mp4"}},
+		{preference: "m4a,mp3", expected: []string{"audio/mp4", "audio/mpeg"}},
+		{preference: "opus", expected: []string{"audio/ogg", "audio/mpeg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preference, func(t *testing.T) {
+			entry := &CiREntry{Audio: []CiRaudio{{Url: "$media_base_url/2024_01_15-chaos-im-radio.mp3", MimeType: "audio/mpeg"}}}
+			config := &Config{SoundDir: soundDir, AudioFormats: tt.preference}
+			err := addAudioRenditions(entry, "2024-01-15", config)
+			if err != nil {
+				t.Fatalf("addAudioRenditions() failed: %v", err)
+			}
+			var mimeTypes []string
+			for _, audio := range entry.Audio {
+				mimeTypes = append(mimeTypes, audio.MimeType)
+			}
+			if strings.Join(mimeTypes, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("expected %v, got %v", tt.expected, mimeTypes)
+			}
+
+			probeEntryAudio(entry, config)
+			if len(entry.processingWarnings) > 0 {
+				t.Errorf("unexpected warnings %v", entry.processingWarnings)
+			}
+			if entry.Audio[0].Duration == "" || entryMP3(entry).Duration != "00:00:02.612" {
+				t.Errorf("expected all renditions to be probed, got %+v", entry.Audio)
+			}
+		})
+	}
+
+	_, err := audioFormatPreference("mp3,flac")
+	if err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestTagCommand(t *testing.T) {
+	dir := t.TempDir()
+	contentFile := filepath.Join(dir, "content.yaml")
+	soundFile := filepath.Join(dir, "2024_01_15-chaos-im-radio.mp3")
+	coverFile := filepath.Join(dir, "cover.png")
+
+	entry := &CiREntry{
+		UUID:            "nt-2024-01-15",
+		Title:           "CiR am 15.01.2024",
+		PublicationDate: "2024-01-15T00:00:00+00:00",
+		Audio:           []CiRaudio{{Url: "$media_base_url/2024_01_15-chaos-im-radio.mp3", MimeType: "audio/mpeg", Size: 1, SHA256: "outdated"}},
+	}
+	if err := appendEntryToYAML(entry, contentFile); err != nil {
+		t.Fatalf("Failed to write content file: %v", err)
+	}
+	if err := os.WriteFile(coverFile, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), 0o644); err != nil {
+		t.Fatalf("Failed to write cover: %v", err)
+	}
+	if err := os.WriteFile(soundFile, buildTestMP3(100, false, false), 0o644); err != nil {
+		t.Fatalf("Failed to create sound file: %v", err)
+	}
+	comment := []byte("\x00deu\x00aufgenommen im Studio")
+	existing := &ID3Tag{
+		Version:  3,
+		Text:     map[string]string{"TIT2": "Rohschnitt", "TENC": "Audacity", "TYER": "2023"},
+		Pictures: []ID3Picture{{MimeType: "image/jpeg", PictureType: 4, Data: []byte{0xFF, 0xD8, 0xFF, 0xD9}}},
+		Frames:   []ID3Frame{{ID: "COMM", Body: comment}},
+	}
+	if err := writeID3Tag(soundFile, existing); err != nil {
+		t.Fatalf("writeID3Tag() failed: %v", err)
+	}
+
+	config := &Config{ContentFilePath: contentFile, EntryUUID: entry.UUID, SoundDir: dir, CoverPath: coverFile, ID3Version: 3}
+	if err := runTagCommand(logrus.New(), config); err != nil {
+		t.Fatalf("runTagCommand() failed: %v", err)
+	}
+
+	written, err := readID3Tag(soundFile)
+	if err != nil {
+		t.Fatalf("readID3Tag() failed: %v", err)
+	}
+	if written.Text["TIT2"] != entry.Title || written.Text["TENC"] != "Audacity" || written.Text["TYER"] != "2024" {
+		t.Errorf("Expected the title and year of the entry and the encoder of the file, got %v", written.Text)
+	}
+	if len(written.Frames) != 1 || written.Frames[0].ID != "COMM" || !bytes.Equal(written.Frames[0].Body, comment) {
+		t.Errorf("Expected the comment to be kept, got %+v", written.Frames)
+	}
+	types := map[byte]string{}
+	for _, picture := range written.Pictures {
+		types[picture.PictureType] = picture.MimeType
+	}
+	if len(written.Pictures) != 2 || types[3] != "image/png" || types[4] != "image/jpeg" {
+		t.Errorf("Expected the PNG cover and the kept back cover, got %v", types)
+	}
+
+	digest, size, err := fileDigest(soundFile)
+	if err != nil {
+		t.Fatalf("fileDigest() failed: %v", err)
+	}
+	entries, err := readYAMLEntries(contentFile)
+	if err != nil {
+		t.Fatalf("Failed to read content file: %v", err)
+	}
+	if audio := entries[0].Audio[0]; audio.Size != size || audio.SHA256 != digest {
+		t.Errorf("Expected size %d and digest %s of the tagged file, got %d and %s", size, digest, audio.Size, audio.SHA256)
+	}
+
+	// the table of contents counts the chapters in a single byte
+	entry.Chapters = make([]CiRChapter, maxID3Chapters+1)
+	for i := range entry.Chapters {
+		entry.Chapters[i] = CiRChapter{Start: formatDuration(time.Duration(i) * time.Second), Title: "Kapitel"}
+	}
+	tag, err := buildID3Tag(entry, nil, time.Hour, 4)
+	if err != nil {
+		t.Fatalf("buildID3Tag() failed: %v", err)
+	}
+	if err := writeID3Tag(soundFile, tag); err == nil || !strings.Contains(err.Error(), "chapters") {
+		t.Errorf("Expected an error for %d chapters, got %v", len(entry.Chapters), err)
+	}
+}
@@ -9,6 +9,14 @@ import (
 
 func processBulkMode(logger *logrus.Logger, config *Config) error {
 	logger.Info("Running in bulk mode - processing all pad entries")
+	err := checkFormat("mapping", config.Format, "json", "csv", "md")
+	if err != nil {
+		return err
+	}
+	// stdout carries the entrydate for the GitHub Action unless we only map
+	if config.Format != "" && config.OutputPath == "" && !config.MapOnly {
+		return fmt.Errorf("-format without -map-only requires -out")
+	}
 
 	// Get all pad URLs from the Radio page
 	logger.Info("Fetching all pad URLs from Radio page...")
@@ -34,30 +42,85 @@ func processBulkMode(logger *logrus.Logger, config *Config) error {
 		return fmt.Errorf("failed to create mapping: %v", err)
 	}
 
-	printMappingReport(logger, mappings, config.FileOnline)
+	err = applySoundFileCandidates(logger, mappings, config)
+	if err != nil {
+		return err
+	}
+
+	printMappingReport(logger, mappings, config)
+
+	// the entries are created before the export, so it shows why each pad was skipped
+	var newEntriesToAdd []*CiREntry
+	var entryDate string
+	if config.MapOnly {
+		for i := range mappings {
+			mappings[i].SkipReasons = skipReasons(mappings[i], config)
+		}
+	} else {
+		newEntriesToAdd, entryDate = collectNewEntries(logger, mappings, config)
+	}
+
+	if config.Format != "" {
+		out, err := createOutput(config.OutputPath)
+		if err != nil {
+			return err
+		}
+		err = writeMappingExport(out, mappings, config, config.Format)
+		out.Close()
+		if err != nil {
+			return fmt.Errorf("failed to export mapping: %v", err)
+		}
+	}
 
 	if config.MapOnly {
 		logger.Info("Map-only mode: skipping creation of new entries")
 		return nil
 	}
 
-	// Create entries for pads without YAML entries
+	// Add all new entries to YAML at once
+	if len(newEntriesToAdd) > 0 {
+		logger.Infof("Adding %d new entries to YAML file", len(newEntriesToAdd))
+		// err = insertMultipleEntriesToYAMLInOrder(newEntriesToAdd, config.ContentFilePath)
+		err = appendMultipleEntriesToYAML(newEntriesToAdd, config.ContentFilePath)
+		if err != nil {
+			logger.Errorf("Failed to insert entries to YAML: %v", err)
+			if !config.ContinueOnError {
+				return fmt.Errorf("failed to insert entries to YAML: %v", err)
+			}
+		}
+	}
+
+	logger.Infof("Created %d new entries", len(newEntriesToAdd))
+
+	// For the GitHub Action, we only return the date of the last processed entry (if any) - will be used in the PR title and commit message
+	fmt.Printf("entrydate=%s\n", entryDate)
+
+	if config.CommentsFilePath == "" {
+		return nil
+	}
+
+	return writeCommentsFile(newEntriesToAdd, config.CommentsFilePath)
+}
+
+// collectNewEntries creates the entries for pads without YAML entries (respecting maxNewEntries if > 0) and records
+// in SkipReasons why the other pads are skipped. It returns the entries and the date of the last one at the limit.
+func collectNewEntries(logger *logrus.Logger, mappings []PadMapping, config *Config) ([]*CiREntry, string) {
 	var newEntriesToAdd []*CiREntry
 	var entryDate string
+	stopped := ""
 
-	// First, collect all new entries (respecting maxNewEntries if > 0)
-	for _, mapping := range mappings {
-		if mapping.HasYAMLEntry {
-			continue
-		}
-		if config.FileOnline && !mapping.HasSoundFileOnline {
+	for i := range mappings {
+		mapping := &mappings[i]
+		mapping.SkipReasons = skipReasons(*mapping, config)
+		if len(mapping.SkipReasons) > 0 {
 			continue
 		}
-		if !config.FileOnline && !mapping.HasSoundFileLocal {
+		if stopped != "" {
+			mapping.SkipReasons = []string{stopped}
 			continue
 		}
 		logger.Infof("Processing pad: %s (date: %s)", mapping.PadURL, mapping.Date)
-		entry, entryErr := createEntryFromPad(mapping.PadURL)
+		entry, entryErr := createEntryFromPad(mapping.PadURL, config)
 		if entryErr == nil && len(entry.processingWarnings) > 0 {
 			logger.Warnf("Processing warnings for %s:", mapping.PadURL)
 			for _, warning := range entry.processingWarnings {
@@ -71,8 +134,9 @@ func processBulkMode(logger *logrus.Logger, config *Config) error {
 		}
 		if entryErr != nil {
 			logger.Errorf("Failed to create entry for %s: %v", mapping.PadURL, entryErr)
+			mapping.SkipReasons = []string{entryErr.Error()}
 			if !config.ContinueOnError {
-				break
+				stopped = "not processed after an earlier error"
 			}
 			continue
 		}
@@ -80,80 +144,52 @@ func processBulkMode(logger *logrus.Logger, config *Config) error {
 		newEntriesToAdd = append(newEntriesToAdd, entry)
 
 		// If maxNewEntries is set (>0) and we've reached the limit, stop collecting more
-		if config.MaxNewEntries > 0 && len(newEntriesToAdd) >= config.MaxNewEntries {
+		if config.MaxNewEntries > 0 && len(newEntriesToAdd) >= config.MaxNewEntries && stopped == "" {
 			entryDate = mapping.Date
 			logger.Infof("Reached max-new-entries limit (%d); stopping collection of new entries", config.MaxNewEntries)
-			break
+			stopped = fmt.Sprintf("max-new-entries limit of %d reached", config.MaxNewEntries)
 		}
-
 	}
-
-	// Add all new entries to YAML at once
-	if len(newEntriesToAdd) > 0 {
-		logger.Infof("Adding %d new entries to YAML file", len(newEntriesToAdd))
-		// err = insertMultipleEntriesToYAMLInOrder(newEntriesToAdd, config.ContentFilePath)
-		err = appendMultipleEntriesToYAML(newEntriesToAdd, config.ContentFilePath)
-		if err != nil {
-			logger.Errorf("Failed to insert entries to YAML: %v", err)
-			if !config.ContinueOnError {
-				return fmt.Errorf("failed to insert entries to YAML: %v", err)
-			}
-		}
-	}
-
-	logger.Infof("Created %d new entries", len(newEntriesToAdd))
-
-	// For the GitHub Action, we only return the date of the last processed entry (if any) - will be used in the PR title and commit message
-	fmt.Printf("entrydate=%s\n", entryDate)
-
-	if config.CommentsFilePath == "" {
-		return nil
-	}
-
-	return writeCommentsFile(newEntriesToAdd, config.CommentsFilePath)
+	return newEntriesToAdd, entryDate
 }
 
-func printMappingReport(logger *logrus.Logger, mappings []PadMapping, checkFileOnline bool) {
+func printMappingReport(logger *logrus.Logger, mappings []PadMapping, config *Config) {
 	logger.Info("=== PAD MAPPING REPORT ===")
 
-	totalPads := len(mappings)
-	withYAML := 0
-	withSoundFile := 0
-	complete := 0
-
 	for _, mapping := range mappings {
 		status := ""
 		if mapping.HasYAMLEntry {
 			status = "YAML ENTRY"
-			withYAML++
 		} else {
 			status = "NO YAML ENTRY"
 		}
-		if !checkFileOnline {
+		if !config.FileOnline {
 			if mapping.HasSoundFileLocal {
 				status += " | Local File: " + mapping.SoundFileName
-				withSoundFile++
 			} else {
 				status += " | NO LOCAL SOUND FILE"
 			}
 		} else {
 			if mapping.HasSoundFileOnline {
 				status += " | File Online: " + mapping.SoundFileName
-				withSoundFile++
 			} else {
 				status += " | NO SOUND FILE ONLINE"
 			}
 		}
 
-		if !mapping.HasYAMLEntry || (checkFileOnline && !mapping.HasSoundFileOnline) {
+		if !mapping.HasYAMLEntry || (config.FileOnline && !mapping.HasSoundFileOnline) {
 			logger.Infof("Date: %s | Pad: %s | Status: %s\n", mapping.Date, mapping.PadURL, status)
 		}
+		for _, candidate := range mapping.SoundFileCandidates {
+			logger.Warnf("Date: %s | Possible sound file: %s (%s, confidence %.2f), expected %s", mapping.Date, candidate.FileName, candidate.Reason, candidate.Confidence, mapping.SoundFileName)
+		}
 	}
 
+	totals := computeMappingTotals(mappings, config)
 	logger.Info("=== SUMMARY ===")
-	logger.Infof("Total pads found: %d\n", totalPads)
-	logger.Infof("With YAML entries: %d\n", withYAML)
-	logger.Infof("With sound files: %d\n", withSoundFile)
-	logger.Infof("Complete (both): %d\n", complete)
-	logger.Infof("Missing YAML entries: %d\n", totalPads-withYAML)
+	logger.Infof("Total pads found: %d\n", totals.Pads)
+	logger.Infof("With YAML entries: %d\n", totals.WithYAML)
+	logger.Infof("With sound files: %d\n", totals.WithSoundFile)
+	logger.Infof("Complete (both): %d\n", totals.Complete)
+	logger.Infof("Missing YAML entries: %d\n", totals.MissingYAML)
 }
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chapterFileExtensions are the file name suffixes used when exporting the chapters of all entries
+var chapterFileExtensions = map[string]string{
+	"json":     ".chapters.json",
+	"webvtt":   ".chapters.vtt",
+	"psc":      ".psc.xml",
+	"mp3chaps": ".chapters.txt",
+	"audacity": ".audacity.txt",
+}
+
+// formatChapterStart formats a chapter start like the chapters written by hand, milliseconds only if needed
+func formatChapterStart(d time.Duration) string {
+	return strings.TrimSuffix(formatDuration(d), ".000")
+}
+
+// chapterSeconds converts a duration to seconds with millisecond precision
+func chapterSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+// secondsToDuration converts seconds as written by the JSON and Audacity formats, rounded to milliseconds
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds*1000)) * time.Millisecond
+}
+
+// chapterTimes parses the starts of the chapters and works out their ends: the next chapter's start and the
+// duration for the last one
+func chapterTimes(chapters []CiRChapter, duration time.Duration) ([]time.Duration, []time.Duration, error) {
+	starts := make([]time.Duration, len(chapters))
+	ends := make([]time.Duration, len(chapters))
+	for i, chapter := range chapters {
+		start, err := parseChapterStart(chapter.Start)
+		if err != nil {
+			return nil, nil, err
+		}
+		starts[i] = start
+	}
+	for i := range chapters {
+		switch {
+		case i+1 < len(chapters):
+			ends[i] = starts[i+1]
+		case duration > starts[i]:
+			ends[i] = duration
+		default:
+			// unknown duration, cues need to end after they start
+			ends[i] = starts[i] + time.Second
+		}
+	}
+	return starts, ends, nil
+}
+
+// podcastChapters is the Podcasting 2.0 chapters.json document
+type podcastChapters struct {
+	Version  string           `json:"version"`
+	Title    string           `json:"title,omitempty"`
+	Chapters []podcastChapter `json:"chapters"`
+}
+
+type podcastChapter struct {
+	StartTime float64 `json:"startTime"`
+	Title     string  `json:"title"`
+	URL       string  `json:"url,omitempty"`
+}
+
+// pscChapters is the Podlove Simple Chapters XML document, only used for reading as encoding/xml can't write
+// the usual psc: prefix
+type pscChapters struct {
+	XMLName  xml.Name     `xml:"http://podlove.org/simple-chapters chapters"`
+	Version  string       `xml:"version,attr"`
+	Chapters []pscChapter `xml:"http://podlove.org/simple-chapters chapter"`
+}
+
+type pscChapter struct {
+	Start string `xml:"start,attr"`
+	Title string `xml:"title,attr"`
+	Href  string `xml:"href,attr,omitempty"`
+}
+
+// writeChapters writes the chapters of an entry in one of the formats of chapterFileExtensions
+func writeChapters(w io.Writer, entry *CiREntry, format string) error {
+	var duration time.Duration
+	if audio := entryMP3(entry); audio != nil && audio.Duration != "" {
+		duration, _ = parseChapterStart(audio.Duration)
+	}
+	starts, ends, err := chapterTimes(entry.Chapters, duration)
+	if err != nil {
+		return fmt.Errorf("chapters of %s: %v", entry.UUID, err)
+	}
+
+	switch format {
+	case "json":
+		document := podcastChapters{Version: "1.2.0", Title: entry.Title, Chapters: []podcastChapter{}}
+		for i, chapter := range entry.Chapters {
+			document.Chapters = append(document.Chapters, podcastChapter{StartTime: chapterSeconds(starts[i]), Title: chapter.Title, URL: chapter.Href})
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(document)
+
+	case "webvtt":
+		var b strings.Builder
+		b.WriteString("WEBVTT\n")
+		for i, chapter := range entry.Chapters {
+			// a cue title must not contain an empty line or the arrow
+			title := strings.ReplaceAll(strings.Join(strings.Fields(chapter.Title), " "), "-->", "->")
+			b.WriteString(fmt.Sprintf("\n%d\n%s --> %s\n%s\n", i+1, formatDuration(starts[i]), formatDuration(ends[i]), title))
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+
+	case "psc":
+		attribute := func(b *strings.Builder, name, value string) {
+			b.WriteString(" " + name + `="`)
+			xml.EscapeText(b, []byte(value)) //nolint:errcheck
+			b.WriteString(`"`)
+		}
+		var b strings.Builder
+		b.WriteString(xml.Header)
+		b.WriteString(`<psc:chapters version="1.2" xmlns:psc="http://podlove.org/simple-chapters">` + "\n")
+		for i, chapter := range entry.Chapters {
+			b.WriteString("  <psc:chapter")
+			attribute(&b, "start", formatDuration(starts[i]))
+			attribute(&b, "title", chapter.Title)
+			if chapter.Href != "" {
+				attribute(&b, "href", chapter.Href)
+			}
+			b.WriteString("/>\n")
+		}
+		b.WriteString("</psc:chapters>\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+
+	case "mp3chaps":
+		// the format of util.py: the link follows the title in angle brackets
+		var b strings.Builder
+		for i, chapter := range entry.Chapters {
+			b.WriteString(formatDuration(starts[i]) + " " + chapter.Title)
+			if chapter.Href != "" {
+				b.WriteString(" <" + chapter.Href + ">")
+			}
+			b.WriteString("\n")
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+
+	case "audacity":
+		// point labels at the chapter starts, like convert_to_audacity_chapters in util.py
+		var b strings.Builder
+		for i, chapter := range entry.Chapters {
+			seconds := strconv.FormatFloat(starts[i].Seconds(), 'f', 6, 64)
+			b.WriteString(seconds + "\t" + seconds + "\t" + chapter.Title + "\n")
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	return fmt.Errorf("unknown chapter format %s, use json, webvtt, psc, mp3chaps or audacity", format)
+}
+
+// mp3chapsLinkRegex matches the link at the end of a mp3chaps line
+var mp3chapsLinkRegex = regexp.MustCompile(`\s+<([^ >]+)>$`)
+
+// vttTimestampRegex matches a WebVTT cue timing line
+var vttTimestampRegex = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}\.\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}\.\d{3})`)
+
+// parseChapters reads chapters in one of the formats of chapterFileExtensions
+func parseChapters(r io.Reader, format string) ([]CiRChapter, error) {
+	var chapters []CiRChapter
+
+	switch format {
+	case "json":
+		var document podcastChapters
+		if err := json.NewDecoder(r).Decode(&document); err != nil {
+			return nil, fmt.Errorf("invalid chapters.json: %v", err)
+		}
+		for _, chapter := range document.Chapters {
+			chapters = append(chapters, CiRChapter{Start: formatChapterStart(secondsToDuration(chapter.StartTime)), Title: chapter.Title, Href: chapter.URL})
+		}
+		return chapters, nil
+
+	case "psc":
+		var document pscChapters
+		if err := xml.NewDecoder(r).Decode(&document); err != nil {
+			return nil, fmt.Errorf("invalid Podlove Simple Chapters: %v", err)
+		}
+		for _, chapter := range document.Chapters {
+			start, err := parseChapterStart(chapter.Start)
+			if err != nil {
+				return nil, err
+			}
+			chapters = append(chapters, CiRChapter{Start: formatChapterStart(start), Title: chapter.Title, Href: chapter.Href})
+		}
+		return chapters, nil
+
+	case "reaper-csv":
+		return parseReaperMarkers(r)
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	var cueStart *time.Duration
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		lineNumber++
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+
+		switch format {
+		case "webvtt":
+			if lineNumber == 1 {
+				if !strings.HasPrefix(line, "WEBVTT") {
+					return nil, fmt.Errorf("not a WebVTT file")
+				}
+				continue
+			}
+			if matches := vttTimestampRegex.FindStringSubmatch(line); matches != nil {
+				start, err := parseChapterStart(matches[1])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+				}
+				cueStart = &start
+				continue
+			}
+			// the first payload line of a cue is the title
+			if cueStart != nil && strings.TrimSpace(line) != "" {
+				chapters = append(chapters, CiRChapter{Start: formatChapterStart(*cueStart), Title: strings.TrimSpace(line)})
+				cueStart = nil
+			}
+
+		case "mp3chaps":
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+			if len(parts) < 2 {
+				return nil, fmt.Errorf("line %d: expected a timestamp and a title", lineNumber)
+			}
+			start, err := parseChapterStart(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			chapter := CiRChapter{Start: formatChapterStart(start), Title: strings.TrimSpace(parts[1])}
+			if matches := mp3chapsLinkRegex.FindStringSubmatchIndex(chapter.Title); matches != nil {
+				chapter.Href = chapter.Title[matches[2]:matches[3]]
+				chapter.Title = chapter.Title[:matches[0]]
+			}
+			chapters = append(chapters, chapter)
+
+		case "audacity":
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "\\") {
+				// lines starting with a backslash hold the frequency range of spectral labels
+				continue
+			}
+			parts := strings.SplitN(line, "\t", 3)
+			if len(parts) < 3 {
+				return nil, fmt.Errorf("line %d: expected start, end and label separated by tabs", lineNumber)
+			}
+			seconds, err := strconv.ParseFloat(parts[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid start %q", lineNumber, parts[0])
+			}
+			chapters = append(chapters, CiRChapter{Start: formatChapterStart(secondsToDuration(seconds)), Title: strings.TrimSpace(parts[2])})
+
+		default:
+			return nil, fmt.Errorf("unknown chapter format %s", format)
+		}
+	}
+	return chapters, scanner.Err()
+}
+
+// parseReaperMarkers reads the CSV export of the Reaper region/marker manager, which needs to show the time
+// as minutes:seconds or seconds
+func parseReaperMarkers(r io.Reader) ([]CiRChapter, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Reaper CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty Reaper CSV")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	idColumn, hasID := columns["#"]
+	nameColumn, hasName := columns["name"]
+	startColumn, hasStart := columns["start"]
+	if !hasName || !hasStart {
+		return nil, fmt.Errorf("expected the columns Name and Start in the Reaper CSV")
+	}
+
+	var chapters []CiRChapter
+	for i, record := range records[1:] {
+		if len(record) <= nameColumn || len(record) <= startColumn {
+			continue
+		}
+		// regions have an R id, their start is a chapter start just like a marker
+		if hasID && len(record) > idColumn && !strings.HasPrefix(record[idColumn], "M") && !strings.HasPrefix(record[idColumn], "R") {
+			continue
+		}
+		startText := strings.TrimSpace(record[startColumn])
+		var start time.Duration
+		if strings.Contains(startText, ":") {
+			start, err = parseChapterStart(startText)
+		} else {
+			var seconds float64
+			seconds, err = strconv.ParseFloat(startText, 64)
+			start = secondsToDuration(seconds)
+			if strings.Count(startText, ".") > 1 {
+				err = fmt.Errorf("bars and beats are not supported, export with minutes:seconds")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i+2, err)
+		}
+		chapters = append(chapters, CiRChapter{Start: formatChapterStart(start), Title: strings.TrimSpace(record[nameColumn])})
+	}
+	return chapters, nil
+}
+
+// normalizeChapterTitle drops case and repeated spaces to match titles edited by hand
+func normalizeChapterTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// mergeImportedChapters takes the imported chapters as the new list and keeps the links of the existing chapters
+// with the same title. It also returns the titles of the existing chapters missing in the import.
+func mergeImportedChapters(existing, imported []CiRChapter) ([]CiRChapter, []string) {
+	hrefs := map[string]string{}
+	for _, chapter := range existing {
+		if chapter.Href != "" {
+			hrefs[normalizeChapterTitle(chapter.Title)] = chapter.Href
+		}
+	}
+
+	importedTitles := map[string]bool{}
+	merged := make([]CiRChapter, len(imported))
+	for i, chapter := range imported {
+		title := normalizeChapterTitle(chapter.Title)
+		importedTitles[title] = true
+		if chapter.Href == "" {
+			chapter.Href = hrefs[title]
+		}
+		merged[i] = chapter
+	}
+
+	var dropped []string
+	for _, chapter := range existing {
+		if !importedTitles[normalizeChapterTitle(chapter.Title)] {
+			dropped = append(dropped, chapter.Title)
+		}
+	}
+	return merged, dropped
+}
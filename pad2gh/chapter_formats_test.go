@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func testChapterEntry() *CiREntry {
+	return &CiREntry{
+		UUID:  "nt-2024-01-15",
+		Title: "CiR am 15.01.2024",
+		Audio: []CiRaudio{{Url: "$media_base_url/2024_01_15-chaos-im-radio.mp3", MimeType: "audio/mpeg", Duration: "01:05:00.500"}},
+		Chapters: []CiRChapter{
+			{Start: "00:00:00", Title: "Intro mit Hitze, Schulanfang & SUP"},
+			{Start: "00:05:24", Title: `Musik: Black Bones - "Captain Blood" (CC-BY-NC-SA 3.0 US)`, Href: "https://freemusicarchive.org/music/Black_Bones/Pirates_of_the_Coast/08_-_Black_Bones_-_Captain_Blood"},
+			{Start: "00:28:40.700", Title: "Was tun, bevor bzw. nachdem das eigene Smartphone geklaut wird?"},
+			{Start: "01:02:03", Title: "Outro <3"},
+		},
+	}
+}
+
+func TestChapterFormatsRoundTrip(t *testing.T) {
+	tests := []struct {
+		format    string
+		keepsHref bool
+	}{
+		{format: "json", keepsHref: true},
+		{format: "webvtt"},
+		{format: "psc", keepsHref: true},
+		{format: "mp3chaps", keepsHref: true},
+		{format: "audacity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			entry := testChapterEntry()
+			var b bytes.Buffer
+			err := writeChapters(&b, entry, tt.format)
+			if err != nil {
+				t.Fatalf("writeChapters() failed: %v", err)
+			}
+
+			chapters, err := parseChapters(&b, tt.format)
+			if err != nil {
+				t.Fatalf("parseChapters() failed: %v\n%s", err, b.String())
+			}
+			if len(chapters) != len(entry.Chapters) {
+				t.Fatalf("expected %d chapters, got %+v", len(entry.Chapters), chapters)
+			}
+			for i, chapter := range chapters {
+				expected := entry.Chapters[i]
+				if !tt.keepsHref {
+					expected.Href = ""
+				}
+				if chapter != expected {
+					t.Errorf("chapter %d = %+v, expected %+v", i, chapter, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteChapters(t *testing.T) {
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{format: "json", contains: []string{`"version": "1.2.0"`, `"startTime": 1720.7,`, `"title": "Intro mit Hitze, Schulanfang & SUP"`}},
+		{format: "webvtt", contains: []string{"WEBVTT\n\n1\n00:00:00.000 --> 00:05:24.000\nIntro", "00:28:40.700 --> 01:02:03.000", "01:02:03.000 --> 01:05:00.500"}},
+		{format: "psc", contains: []string{`<psc:chapters version="1.2" xmlns:psc="http://podlove.org/simple-chapters">`, `<psc:chapter start="00:05:24.000" title="Musik: Black Bones - &#34;Captain Blood&#34; (CC-BY-NC-SA 3.0 US)" href="https://freemusicarchive.org/`, `title="Outro &lt;3"/>`}},
+		{format: "mp3chaps", contains: []string{"00:00:00.000 Intro mit Hitze, Schulanfang & SUP\n00:05:24.000 Musik: Black Bones", "(CC-BY-NC-SA 3.0 US) <https://freemusicarchive.org/"}},
+		{format: "audacity", contains: []string{"0.000000\t0.000000\tIntro", "1720.700000\t1720.700000\tWas tun"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			err := writeChapters(&b, testChapterEntry(), tt.format)
+			if err != nil {
+				t.Fatalf("writeChapters() failed: %v", err)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(b.String(), s) {
+					t.Errorf("expected output to contain %q, got:\n%s", s, b.String())
+				}
+			}
+		})
+	}
+}
+
+func TestParseReaperMarkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		expected []CiRChapter
+		wantErr  bool
+	}{
+		{
+			name: "minutes and seconds",
+			csv:  "#,Name,Start,End,Length\nM1,Intro,0:00.000,,\nR1,\"Musik: Black Bones, Captain Blood\",5:24.000,9:32.000,4:08.000\nM2,Outro,1:02:03.250,,\n",
+			expected: []CiRChapter{
+				{Start: "00:00:00", Title: "Intro"},
+				{Start: "00:05:24", Title: "Musik: Black Bones, Captain Blood"},
+				{Start: "01:02:03.250", Title: "Outro"},
+			},
+		},
+		{
+			name: "seconds",
+			csv:  "#,Name,Start\nM1,Intro,0\nM2,Outro,3723.5\n",
+			expected: []CiRChapter{
+				{Start: "00:00:00", Title: "Intro"},
+				{Start: "01:02:03.500", Title: "Outro"},
+			},
+		},
+		{name: "bars and beats", csv: "#,Name,Start\nM1,Intro,1.1.00\n", wantErr: true},
+		{name: "missing columns", csv: "#,Title\nM1,Intro\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chapters, err := parseChapters(strings.NewReader(tt.csv), "reaper-csv")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", chapters)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseChapters() failed: %v", err)
+			}
+			if len(chapters) != len(tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, chapters)
+			}
+			for i := range chapters {
+				if chapters[i] != tt.expected[i] {
+					t.Errorf("chapter %d = %+v, expected %+v", i, chapters[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImportChapters(t *testing.T) {
+	contentFile := filepath.Join(t.TempDir(), "content.yaml")
+	chapterFile := filepath.Join(t.TempDir(), "chapters.txt")
+
+	other := &CiREntry{UUID: "nt-2024-01-08", PublicationDate: "2024-01-08T00:00:00+00:00", Title: "CiR am 08.01.2024"}
+	entry := testChapterEntry()
+	entry.PublicationDate = "2024-01-15T00:00:00+00:00"
+	if err := appendMultipleEntriesToYAML([]*CiREntry{other, entry}, contentFile); err != nil {
+		t.Fatalf("Failed to write content file: %v", err)
+	}
+	audacity := "0.000000\t0.000000\tIntro mit Hitze,  Schulanfang & SUP\n" +
+		"324.250000\t324.250000\tmusik: Black Bones - \"Captain Blood\" (CC-BY-NC-SA 3.0 US)\n" +
+		"1800.000000\t1800.000000\tSmartphone-Diebstahl\n"
+	if err := os.WriteFile(chapterFile, []byte(audacity), 0o644); err != nil {
+		t.Fatalf("Failed to write chapter file: %v", err)
+	}
+
+	config := &Config{ContentFilePath: contentFile, EntryUUID: entry.UUID, Format: "audacity", Args: []string{chapterFile}}
+	if err := runImportChaptersCommand(logrus.New(), config); err != nil {
+		t.Fatalf("runImportChaptersCommand() failed: %v", err)
+	}
+
+	entries, err := readYAMLEntries(contentFile)
+	if err != nil {
+		t.Fatalf("Failed to read content file: %v", err)
+	}
+	if len(entries) != 2 || entries[0].UUID != other.UUID {
+		t.Fatalf("Expected both entries in their order, got %+v", entries)
+	}
+	expected := []CiRChapter{
+		{Start: "00:00:00", Title: "Intro mit Hitze,  Schulanfang & SUP"},
+		{Start: "00:05:24.250", Title: `musik: Black Bones - "Captain Blood" (CC-BY-NC-SA 3.0 US)`, Href: entry.Chapters[1].Href},
+		{Start: "00:30:00", Title: "Smartphone-Diebstahl"},
+	}
+	chapters := entries[1].Chapters
+	if len(chapters) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, chapters)
+	}
+	for i := range chapters {
+		if chapters[i] != expected[i] {
+			t.Errorf("chapter %d = %+v, expected %+v", i, chapters[i], expected[i])
+		}
+	}
+
+	config.EntryUUID = "nt-2024-02-01"
+	if err := runImportChaptersCommand(logrus.New(), config); err == nil {
+		t.Errorf("Expected an error for an unknown entry")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runExportChaptersCommand writes the chapters of the entry given with -entry to -out (or stdout),
+// without -entry the chapters of all entries are written into the directory -out
+func runExportChaptersCommand(logger *logrus.Logger, config *Config) error {
+	format := config.Format
+	if format == "" {
+		format = "json"
+	}
+	extension, ok := chapterFileExtensions[format]
+	if !ok {
+		return fmt.Errorf("unknown chapter format %s, use json, webvtt, psc, mp3chaps or audacity", format)
+	}
+
+	if config.EntryUUID != "" {
+		entry, err := findEntryByUUID(config.ContentFilePath, config.EntryUUID)
+		if err != nil {
+			return err
+		}
+		if len(entry.Chapters) == 0 {
+			return fmt.Errorf("entry %s has no chapters", entry.UUID)
+		}
+		out, err := createOutput(config.OutputPath)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		return writeChapters(out, entry, format)
+	}
+
+	if config.OutputPath == "" {
+		return fmt.Errorf("exporting the chapters of all entries needs -out <directory>")
+	}
+	err := os.MkdirAll(config.OutputPath, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", config.OutputPath, err)
+	}
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	written := 0
+	for _, entry := range entries {
+		if len(entry.Chapters) == 0 {
+			continue
+		}
+		filePath := filepath.Join(config.OutputPath, entry.UUID+extension)
+		file, err := os.Create(filePath)
+		if err != nil {
+			return err
+		}
+		err = writeChapters(file, entry, format)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		written++
+	}
+	logger.Infof("Wrote the chapters of %d entries to %s", written, config.OutputPath)
+	return nil
+}
+
+// runImportChaptersCommand replaces the chapters of the entry given with -entry by the ones of the file given as
+// argument, keeping the links of existing chapters with the same title
+func runImportChaptersCommand(logger *logrus.Logger, config *Config) error {
+	if len(config.Args) != 1 {
+		return fmt.Errorf("usage: pad2gh import chapters -format audacity|reaper-csv|mp3chaps|psc <file> -entry <uuid>")
+	}
+	if config.Format == "" {
+		return fmt.Errorf("import chapters needs -format audacity, reaper-csv, mp3chaps or psc")
+	}
+	if config.EntryUUID == "" {
+		return fmt.Errorf("-entry <uuid> is required")
+	}
+
+	file, err := os.Open(config.Args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	imported, err := parseChapters(file, config.Format)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", config.Args[0], err)
+	}
+	if len(imported) < 2 {
+		return fmt.Errorf("found %d chapters in %s, expected at least two", len(imported), config.Args[0])
+	}
+
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+	var orderedEntries []EntryWithOrder
+	var entry *CiREntry
+	for _, e := range entries {
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: e})
+		if e.UUID == config.EntryUUID {
+			entry = e
+		}
+	}
+	if entry == nil {
+		return fmt.Errorf("no entry %s in %s", config.EntryUUID, config.ContentFilePath)
+	}
+
+	chapters, dropped := mergeImportedChapters(entry.Chapters, imported)
+	for _, title := range dropped {
+		logger.Warnf("Chapter %q is not in %s and was removed", title, config.Args[0])
+	}
+	entry.Chapters = chapters
+	logger.Infof("Imported %d chapters into %s", len(chapters), entry.UUID)
+
+	return writeAllYAMLEntries(orderedEntries, config.ContentFilePath)
+}
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/sirupsen/logrus"
 )
 
 func TestExtractDateFromPadURL(t *testing.T) {
@@ -112,8 +119,8 @@ func TestCreateMockEntry(t *testing.T) {
 		t.Errorf("Expected 1 audio entry, got %d", len(entry.Audio))
 	}
 
-	if entry.Audio[0].MimeType != "audio/mp3" {
-		t.Errorf("Expected mime type 'audio/mp3', got '%s'", entry.Audio[0].MimeType)
+	if entry.Audio[0].MimeType != "audio/mpeg" {
+		t.Errorf("Expected mime type 'audio/mpeg', got '%s'", entry.Audio[0].MimeType)
 	}
 }
 
@@ -189,3 +196,87 @@ func TestCreatePadMapping(t *testing.T) {
 		t.Errorf("Expected second mapping to not have YAML entry")
 	}
 }
+
+func TestWriteMappingExport(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	mappings := []PadMapping{
+		{
+			Date:              "2024-01-15",
+			PadURL:            "https://pad.ccc-p.org/Radio_2024-01-15_test1",
+			HasYAMLEntry:      true,
+			YAMLEntry:         &CiREntry{UUID: "nt-2024-01-15"},
+			HasSoundFileLocal: true,
+			SoundFileName:     "2024_01_15-chaos-im-radio.mp3",
+		},
+		{
+			Date:              "2024-02-12",
+			PadURL:            server.URL + "/Radio_2024-02-12_test2",
+			HasSoundFileLocal: true,
+			SoundFileName:     "2024_02_12-chaos-im-radio.mp3",
+		},
+		{
+			Date:          "2024-03-11",
+			PadURL:        "https://pad.ccc-p.org/Radio_2024-03-11_test3",
+			SoundFileName: "2024_03_11-chaos-im-radio.mp3",
+		},
+		{
+			Date:              "2024-04-08",
+			PadURL:            server.URL + "/Radio_2024-04-08_test4",
+			HasSoundFileLocal: true,
+			SoundFileName:     "2024_04_08-chaos-im-radio.mp3",
+		},
+	}
+	config := &Config{SoundDir: "/srv/radio/files"}
+
+	totals := computeMappingTotals(mappings, config)
+	expected := MappingTotals{Pads: 4, WithYAML: 1, WithSoundFile: 3, Complete: 1, MissingYAML: 3, Importable: 2}
+	if totals != expected {
+		t.Errorf("computeMappingTotals() = %+v, want %+v", totals, expected)
+	}
+
+	// the reasons come from the same checks bulk mode runs, including the pad itself
+	entries, _ := collectNewEntries(logrus.New(), mappings, config)
+	if len(entries) != 0 {
+		t.Fatalf("Expected no entries from the missing pads, got %d", len(entries))
+	}
+
+	var out bytes.Buffer
+	err := writeMappingExport(&out, mappings, config, "json")
+	if err != nil {
+		t.Fatalf("writeMappingExport(json) failed: %v", err)
+	}
+	var export MappingExport
+	err = json.Unmarshal(out.Bytes(), &export)
+	if err != nil {
+		t.Fatalf("Exported JSON is invalid: %v", err)
+	}
+	if export.Mappings[0].YAMLEntryUUID != "nt-2024-01-15" {
+		t.Errorf("Expected matched UUID in export, got %q", export.Mappings[0].YAMLEntryUUID)
+	}
+	expectedReasons := []string{"YAML entry exists", "pad url must be accessible", "no local sound file", "not processed after an earlier error"}
+	for i, reason := range expectedReasons {
+		if reasons := export.Mappings[i].SkipReasons; len(reasons) != 1 || !strings.Contains(reasons[0], reason) {
+			t.Errorf("Expected %q as skip reason of %s, got %v", reason, export.Mappings[i].Date, reasons)
+		}
+	}
+
+	out.Reset()
+	err = writeMappingExport(&out, mappings, config, "csv")
+	if err != nil {
+		t.Fatalf("writeMappingExport(csv) failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 5 {
+		t.Errorf("Expected header and 4 rows in CSV, got %d lines", len(lines))
+	}
+
+	out.Reset()
+	err = writeMappingExport(&out, mappings, config, "md")
+	if err != nil {
+		t.Fatalf("writeMappingExport(md) failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Complete (both): 1") {
+		t.Errorf("Expected totals in markdown export, got:\n%s", out.String())
+	}
+}
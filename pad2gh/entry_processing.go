@@ -2,32 +2,27 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
-func processSingleEntry(logger *logrus.Logger, entry *CiREntry, config *Config) error {
-	logger.Debugf("pad url: %s\n", entry.padURL)
+// processSingleEntry creates the entry of the pad and adds it to the YAML file, or prints it without one
+func processSingleEntry(logger *logrus.Logger, padURL string, config *Config) error {
+	logger.Debugf("pad url: %s\n", padURL)
 
-	contentBySection, err := getMarkdownContentBySection(entry.padURL)
+	entryDate, err := padEntryDate(padURL)
 	if err != nil {
 		return err
 	}
 
-	if len(strings.Split(entry.padURL, "_")) < 2 {
-		return fmt.Errorf("pad url must contain a date in the format YYYY-MM-DD_")
-	}
-	entryDate := strings.Split(entry.padURL, "_")[1]
-	if len(entryDate) < 10 {
-		return fmt.Errorf("pad url must contain a date in the format YYYY-MM-DD_")
-	}
-
 	// for the GitHub Action:
 	fmt.Printf("entrydate=%s\n", entryDate)
 
-	err = populateEntryFromSections(entry, contentBySection, entryDate)
+	entry, err := createEntryFromPad(padURL, config)
 	if err != nil {
 		return err
 	}
@@ -59,30 +54,76 @@ func processSingleEntry(logger *logrus.Logger, entry *CiREntry, config *Config)
 	return writeCommentsFile([]*CiREntry{entry}, config.CommentsFilePath)
 }
 
-func createEntryFromPad(padURL string) (*CiREntry, error) {
-	entry := &CiREntry{padURL: padURL}
+// padEntryDate returns the part of the pad URL after the first "_", which starts with the date of the episode
+func padEntryDate(padURL string) (string, error) {
+	if len(strings.Split(padURL, "_")) < 2 {
+		return "", fmt.Errorf("pad url must contain a date in the format YYYY-MM-DD_")
+	}
+	entryDate := strings.Split(padURL, "_")[1]
+	if len(entryDate) < 10 {
+		return "", fmt.Errorf("pad url must contain a date in the format YYYY-MM-DD_")
+	}
+	return entryDate, nil
+}
 
-	contentBySection, err := getMarkdownContentBySection(padURL)
+// createEntryFromPad runs the whole pipeline from the pad to the entry, it is shared by the single-entry, bulk,
+// watch and serve modes
+func createEntryFromPad(padURL string, config *Config) (*CiREntry, error) {
+	entry, err := readPadEntry(padURL, config)
 	if err != nil {
 		return nil, err
 	}
+	err = addEntryMedia(entry, config)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
 
-	if len(strings.Split(padURL, "_")) < 2 {
-		return nil, fmt.Errorf("pad url must contain a date in the format YYYY-MM-DD_")
+// readPadEntry is the cheap part of the pipeline: it runs the privacy filter, reads the sections and resolves the
+// persons, so the tags can be checked before the sound file and cover are processed
+func readPadEntry(padURL string, config *Config) (*CiREntry, error) {
+	entry := &CiREntry{padURL: padURL}
+
+	contentBySection, padWarnings, err := getMarkdownContentBySection(padURL)
+	if err != nil {
+		return nil, err
 	}
-	entryDate := strings.Split(padURL, "_")[1]
-	if len(entryDate) < 10 {
-		return nil, fmt.Errorf("pad url must contain a date in the format YYYY-MM-DD_")
+	entry.processingWarnings = append(entry.processingWarnings, padWarnings...)
+
+	entryDate, err := padEntryDate(padURL)
+	if err != nil {
+		return nil, err
 	}
 
+	diagnostics, err := sanitizePadSections(contentBySection, config)
+	if err != nil {
+		return nil, err
+	}
+	entry.processingWarnings = append(entry.processingWarnings, diagnostics...)
 	err = populateEntryFromSections(entry, contentBySection, entryDate)
 	if err != nil {
 		return nil, err
 	}
+	resolveEntryPersons(entry, config)
 
 	return entry, nil
 }
 
+// addEntryMedia is the expensive part of the pipeline: it adds the audio renditions, probes, checks and segments the
+// sound file and downloads the cover
+func addEntryMedia(entry *CiREntry, config *Config) error {
+	err := addAudioRenditions(entry, entry.PublicationDate[:10], config)
+	if err != nil {
+		return err
+	}
+	probeEntryAudio(entry, config)
+	checkEntryAudio(entry, config)
+	suggestEntryChapters(entry, config)
+	addEntryCover(entry, config)
+	return nil
+}
+
 func populateEntryFromSections(entry *CiREntry, contentBySection map[string][]string, entryDate string) error {
 	year := entryDate[0:4]
 	month := entryDate[5:7]
@@ -134,7 +175,7 @@ func populateEntryFromSections(entry *CiREntry, contentBySection map[string][]st
 	entry.Summary = strings.Join(shortSummary, "\n")
 	entry.Audio = []CiRaudio{{
 		Url:      fmt.Sprintf("$media_base_url/%s_%s_%s-chaos-im-radio.mp3", year, month, day),
-		MimeType: "audio/mp3",
+		MimeType: audioMimeTypes[".mp3"],
 	}}
 
 	if !entry.tags["no_music"] {
@@ -207,6 +248,15 @@ func populateEntryFromSections(entry *CiREntry, contentBySection map[string][]st
 		logrus.Infof("no chapters Section in Pad %s", entry.padURL)
 	}
 
+	frontMatter, err := parseFrontMatter(contentBySection)
+	if err != nil {
+		entry.processingWarnings = append(entry.processingWarnings, err.Error())
+	}
+	entry.coverURL = padCoverURL(contentBySection, frontMatter)
+	persons, warnings := padPersons(contentBySection, frontMatter)
+	entry.Persons = persons
+	entry.processingWarnings = append(entry.processingWarnings, warnings...)
+
 	return nil
 }
 
@@ -219,3 +269,27 @@ func markdownifyLinks(text string) string {
 	}
 	return strings.Join(words, " ")
 }
+
+// parseChapterStart parses a chapter timestamp in the format HH:MM:SS(.mmm) or MM:SS(.mmm)
+func parseChapterStart(start string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(start), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid chapter timestamp %q", start)
+	}
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid chapter timestamp %q", start)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid chapter timestamp %q", start)
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil || seconds < 0 || seconds >= 60 || minutes < 0 || minutes >= 60 || hours < 0 {
+		return 0, fmt.Errorf("invalid chapter timestamp %q", start)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)).Round(time.Millisecond), nil
+}
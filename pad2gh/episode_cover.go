@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// Apple Podcasts and Podcasting 2.0 clients want square artwork between 1400 and 3000 pixels
+	minCoverSize = 1400
+	maxCoverSize = 3000
+	// larger downloads are rejected before decoding
+	maxCoverBytes = 10 << 20
+	// the small variant matches cover_250.jpg
+	smallCoverSize = 250
+)
+
+// padCoverURL returns the image of the cover section, or of the front matter without one. Markdown images, links and
+// plain URLs are accepted, HedgeDoc size suffixes like "=300x" are dropped.
+func padCoverURL(contentBySection map[string][]string, frontMatter *PadFrontMatter) string {
+	for _, line := range contentBySection["cover"] {
+		_, link := findFirstLink(line)
+		if fields := strings.Fields(link); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return strings.TrimSpace(frontMatter.Image)
+}
+
+// decodeCover checks that the image is a square JPEG or PNG of at least minCoverSize pixels before decoding it
+func decodeCover(data []byte) (image.Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not an image: %v", err)
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, fmt.Errorf("%s is not supported, only JPEG and PNG", format)
+	}
+	if config.Width != config.Height {
+		return nil, fmt.Errorf("%dx%d is not square", config.Width, config.Height)
+	}
+	if config.Width < minCoverSize {
+		return nil, fmt.Errorf("%dx%d is smaller than %dx%d", config.Width, config.Height, minCoverSize, minCoverSize)
+	}
+	cover, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the %s: %v", format, err)
+	}
+	return cover, nil
+}
+
+// downloadCover fetches and validates the cover, images larger than maxCoverBytes are rejected
+func downloadCover(coverURL string) (image.Image, error) {
+	resp, err := http.Get(coverURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s returned status code %d", coverURL, resp.StatusCode)
+	}
+	if resp.ContentLength > maxCoverBytes {
+		return nil, fmt.Errorf("%d bytes are more than the limit of %d", resp.ContentLength, maxCoverBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCoverBytes {
+		return nil, fmt.Errorf("more than the limit of %d bytes", maxCoverBytes)
+	}
+	return decodeCover(data)
+}
+
+// scaleCover scales the square cover to size on a white background, JPEG has no transparency
+func scaleCover(cover image.Image, size int) *image.RGBA {
+	scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(scaled, scaled.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), cover, cover.Bounds(), draw.Over, nil)
+	return scaled
+}
+
+// writeCoverVariants writes the cover as <uuid>.jpg of at most maxCoverSize pixels for the feed and as
+// <uuid>_250.jpg for the player into dir
+func writeCoverVariants(dir string, uuid string, cover image.Image) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	size := cover.Bounds().Dx()
+	if size > maxCoverSize {
+		size = maxCoverSize
+	}
+	fileName := uuid + ".jpg"
+	err = writeJPEG(filepath.Join(dir, fileName), scaleCover(cover, size))
+	if err != nil {
+		return err
+	}
+	return writeJPEG(filepath.Join(dir, squarePreviewFileName(fileName)), scaleCover(cover, smallCoverSize))
+}
+
+// addEntryCover downloads the cover of the pad into -cover-dir and references it as image, problems with the image
+// become processing warnings and the episode keeps the podcast cover
+func addEntryCover(entry *CiREntry, config *Config) {
+	if entry.coverURL == "" || config.CoverDir == "" {
+		return
+	}
+	cover, err := downloadCover(entry.coverURL)
+	if err == nil {
+		err = writeCoverVariants(config.CoverDir, entry.UUID, cover)
+	}
+	if err != nil {
+		entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("could not use the cover %s: %v", entry.coverURL, err))
+		return
+	}
+	entry.Image = siteRelativePath(config.CoverDir, entry.UUID+".jpg")
+}
+
+// entryCoverPath returns the cover of the episode in -cover-dir, or -cover for episodes without their own
+func entryCoverPath(entry *CiREntry, config *Config) string {
+	if entry.Image == "" || config.CoverDir == "" {
+		return config.CoverPath
+	}
+	return filepath.Join(config.CoverDir, path.Base(entry.Image))
+}
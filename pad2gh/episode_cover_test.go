@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPadCoverURL(t *testing.T) {
+	pad := "---\ntitle: CiR\nimage: https://example.org/front.png\ntags:\n  - radio\n---\n# CiR\n\n## Summary\nTest\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pad))
+	}))
+	defer server.Close()
+
+	contentBySection, warnings, err := getMarkdownContentBySection(server.URL + "/cir_2024-01-15")
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("getMarkdownContentBySection() failed: %v %v", err, warnings)
+	}
+	if strings.Join(contentBySection["summary"], "") != "Test" {
+		t.Errorf("Expected the summary after the front matter, got %q", contentBySection["summary"])
+	}
+	frontMatter, err := parseFrontMatter(contentBySection)
+	if err != nil {
+		t.Fatalf("parseFrontMatter() failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		cover    []string
+		expected string
+	}{
+		{"front matter", nil, "https://example.org/front.png"},
+		{"markdown image", []string{"", "![Cover](https://example.org/cover.jpg =300x)"}, "https://example.org/cover.jpg"},
+		{"plain link", []string{"https://example.org/cover.png"}, "https://example.org/cover.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentBySection["cover"] = tt.cover
+			if coverURL := padCoverURL(contentBySection, frontMatter); coverURL != tt.expected {
+				t.Errorf("padCoverURL() = %q, expected %q", coverURL, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnclosedFrontMatter(t *testing.T) {
+	pad := "---\n# CiR\n\n## Summary\nTest\n\n## Mukke\n* https://example.org/track\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pad))
+	}))
+	defer server.Close()
+
+	contentBySection, warnings, err := getMarkdownContentBySection(server.URL + "/cir_2024-01-15")
+	if err != nil {
+		t.Fatalf("getMarkdownContentBySection() failed: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "front matter in line 1 is never closed") {
+		t.Errorf("expected a warning about the unclosed front matter, got %v", warnings)
+	}
+	if _, exists := contentBySection["front matter"]; exists {
+		t.Errorf("expected no front matter, got %q", contentBySection["front matter"])
+	}
+	if strings.Join(contentBySection["summary"], "") != "Test" || len(contentBySection["mukke"]) == 0 {
+		t.Errorf("expected the sections to be read as pad content, got %q", contentBySection)
+	}
+}
+
+func TestAddEntryCover(t *testing.T) {
+	encode := func(width, height int, transparent bool) []byte {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		if !transparent {
+			for i := range img.Pix {
+				img.Pix[i] = 0xff
+			}
+		}
+		img.Set(0, 0, color.NRGBA{0x90, 0xdb, 0x4b, 0xff})
+		var b bytes.Buffer
+		_ = png.Encode(&b, img)
+		return b.Bytes()
+	}
+	images := map[string][]byte{
+		"/cover.png": encode(3200, 3200, true),
+		"/wide.png":  encode(1600, 1400, false),
+		"/small.png": encode(1000, 1000, false),
+		"/page.html": []byte("<html></html>"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, exists := images[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	coverDir := filepath.Join(t.TempDir(), "covers")
+	config := &Config{CoverDir: coverDir, CoverPath: "../cover.jpg"}
+
+	tests := []struct {
+		path    string
+		warning string
+	}{
+		{"/wide.png", "1600x1400 is not square"},
+		{"/small.png", "smaller than 1400x1400"},
+		{"/page.html", "not an image"},
+		{"/missing.png", "status code 404"},
+		{"/cover.png", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			entry := &CiREntry{UUID: "nt-2024-01-15", coverURL: server.URL + tt.path}
+			addEntryCover(entry, config)
+			if tt.warning != "" {
+				if entry.Image != "" || len(entry.processingWarnings) != 1 || !strings.Contains(entry.processingWarnings[0], tt.warning) {
+					t.Errorf("Expected a warning about %q and no image, got %q %v", tt.warning, entry.Image, entry.processingWarnings)
+				}
+				return
+			}
+			if entry.Image != "covers/nt-2024-01-15.jpg" || len(entry.processingWarnings) != 0 {
+				t.Fatalf("Expected the cover to be referenced, got %q %v", entry.Image, entry.processingWarnings)
+			}
+			if coverPath := entryCoverPath(entry, config); coverPath != filepath.Join(coverDir, "nt-2024-01-15.jpg") {
+				t.Errorf("entryCoverPath() = %q", coverPath)
+			}
+		})
+	}
+
+	sizes := map[string]int{"nt-2024-01-15.jpg": maxCoverSize, "nt-2024-01-15_250.jpg": smallCoverSize}
+	for name, size := range sizes {
+		file, err := os.Open(filepath.Join(coverDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s: %v", name, err)
+		}
+		img, err := jpeg.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", name, err)
+		}
+		if img.Bounds().Dx() != size || img.Bounds().Dy() != size {
+			t.Errorf("%s is %v, expected %dx%d", name, img.Bounds().Size(), size, size)
+		}
+		// the transparent PNG is put on white
+		if r, g, b, _ := img.At(size/2, size/2).RGBA(); r>>8 < 0xf0 || g>>8 < 0xf0 || b>>8 < 0xf0 {
+			t.Errorf("%s: expected a white center, got %d %d %d", name, r>>8, g>>8, b>>8)
+		}
+	}
+}
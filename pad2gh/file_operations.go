@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -128,16 +131,15 @@ func appendMultipleEntriesToYAML(entries []*CiREntry, contentFilePath string) er
 func readYAMLEntries(filePath string) ([]*CiREntry, error) {
 	var entries []*CiREntry
 
-	file, err := os.Open(filePath)
+	content, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return entries, nil // Return empty slice if file doesn't exist
 		}
 		return nil, err
 	}
-	defer file.Close()
 
-	decoder := yaml.NewDecoder(file)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
 	for {
 		var entry CiREntry
 		err := decoder.Decode(&entry)
@@ -150,9 +152,65 @@ func readYAMLEntries(filePath string) ([]*CiREntry, error) {
 		entries = append(entries, &entry)
 	}
 
+	// remember the text of every entry, so rewriting the file keeps the entries nothing was changed in as they are
+	documents := splitYAMLDocuments(string(content))
+	if len(documents) == len(entries) {
+		for i, entry := range entries {
+			encoded, err := encodeEntryYAML(entry)
+			if err != nil {
+				return nil, err
+			}
+			entry.rawYAML, entry.readYAML = documents[i], encoded
+		}
+	}
+
 	return entries, nil
 }
 
+// splitYAMLDocuments returns the text of the documents of a YAML stream separated by --- lines
+func splitYAMLDocuments(content string) []string {
+	var documents []string
+	var current strings.Builder
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			documents = append(documents, current.String())
+		}
+		current.Reset()
+	}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.TrimRight(line, " \t\r\n") == "---" {
+			flush()
+			continue
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return documents
+}
+
+// encodeEntryYAML encodes an entry in the style of content.yaml
+func encodeEntryYAML(entry *CiREntry) (string, error) {
+	var node yaml.Node
+	err := node.Encode(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode entry to node: %v", err)
+	}
+	setSingleQuoteStyle(&node)
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&node)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal entry: %v", err)
+	}
+	err = encoder.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to close encoder: %v", err)
+	}
+	return b.String(), nil
+}
+
 // readExistingYAMLEntries reads YAML entries and returns them as a map keyed by date
 func readExistingYAMLEntries(filePath string) (map[string]*CiREntry, error) {
 	entries, err := readYAMLEntries(filePath)
@@ -232,6 +290,26 @@ func insertMultipleEntriesToYAMLInOrder(newEntries []*CiREntry, contentFilePath
 	return writeAllYAMLEntries(orderedEntries, contentFilePath)
 }
 
+// replaceYAMLEntry replaces the entry with the same UUID in the YAML file, the other entries keep their text
+func replaceYAMLEntry(entry *CiREntry, contentFilePath string) error {
+	entries, err := readYAMLEntries(contentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+	var orderedEntries []EntryWithOrder
+	replaced := false
+	for _, existing := range entries {
+		if existing.UUID == entry.UUID {
+			existing, replaced = entry, true
+		}
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: existing})
+	}
+	if !replaced {
+		return fmt.Errorf("no entry %s in %s", entry.UUID, contentFilePath)
+	}
+	return writeAllYAMLEntries(orderedEntries, contentFilePath)
+}
+
 // parseEntryDate extracts and parses the date from a CiREntry
 func parseEntryDate(entry *CiREntry) (time.Time, error) {
 	var dateStr string
@@ -255,37 +333,47 @@ func parseEntryDate(entry *CiREntry) (time.Time, error) {
 	return parsedDate, nil
 }
 
-// writeAllYAMLEntries writes all entries to a YAML file
+// writeAllYAMLEntries writes all entries to a YAML file, entries read from the file which weren't changed keep their
+// original text
 func writeAllYAMLEntries(orderedEntries []EntryWithOrder, contentFilePath string) error {
-	file, err := os.Create(contentFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file %s: %v", contentFilePath, err)
-	}
-	defer file.Close()
-
-	encoder := yaml.NewEncoder(file)
-	encoder.SetIndent(2)
-
+	var b strings.Builder
 	for _, entryWithOrder := range orderedEntries {
-		// First marshal to a node to apply single quote style
-		var node yaml.Node
-		err := node.Encode(entryWithOrder.Entry)
+		entry := entryWithOrder.Entry
+		encoded, err := encodeEntryYAML(entry)
 		if err != nil {
-			_ = encoder.Close()
-			return fmt.Errorf("failed to encode entry to node: %v", err)
+			return err
 		}
-		setSingleQuoteStyle(&node)
-
-		err = encoder.Encode(&node)
-		if err != nil {
-			_ = encoder.Close()
-			return fmt.Errorf("failed to marshal entry: %v", err)
+		b.WriteString("---\n")
+		if entry.rawYAML != "" && encoded == entry.readYAML {
+			b.WriteString(entry.rawYAML)
+		} else {
+			b.WriteString(encoded)
 		}
 	}
 
-	err = encoder.Close()
+	// write next to the file and rename, so a failure never leaves a truncated content.yaml
+	tmpFile, err := os.CreateTemp(filepath.Dir(contentFilePath), ".content_*.yaml")
 	if err != nil {
-		return fmt.Errorf("failed to close encoder: %v", err)
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(contentFilePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	_, err = tmpFile.WriteString(b.String())
+	if err == nil {
+		err = tmpFile.Chmod(mode)
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmpFile.Name(), err)
+	}
+	err = os.Rename(tmpFile.Name(), contentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to rename temp file to content file: %v", err)
 	}
 
 	return nil
@@ -322,13 +410,34 @@ func writeCommentsFile(entries []*CiREntry, commentsFilePath string) error {
 		for _, c := range entry.processingWarnings {
 			commentsFile.WriteString("\n* " + c) //nolint:errcheck
 		}
+
+		if len(entry.suggestedChapters) > 0 {
+			commentsFile.WriteString("\n\n### Suggested chapters\n\nDetected from the sound file or its transcript, please check the times and titles before adding them to the pad:\n\n```markdown\n" + chaptersPadBlock(entry.suggestedChapters) + "```\n") //nolint:errcheck
+		}
 	}
 
 	return nil
 }
 
-func checkSoundFileExistsLocally(soundFileDir, soundFileName string) bool {
-	filePath := filepath.Join(soundFileDir, soundFileName)
-	_, err := os.Stat(filePath)
-	return !os.IsNotExist(err)
+// siteRelativePath is the reference in content.yaml to a file written to dir, the site serves the directory under
+// its own name next to index.html
+func siteRelativePath(dir, fileName string) string {
+	return path.Join(filepath.Base(dir), fileName)
+}
+
+// soundFileNameRegex matches the canonical sound file name YYYY_MM_DD-chaos-im-radio.mp3
+var soundFileNameRegex = regexp.MustCompile(`^(\d{4})_(\d{2})_(\d{2})-chaos-im-radio\.mp3$`)
+
+// soundFileNameForDate returns the canonical sound file name for a date in the format YYYY-MM-DD
+func soundFileNameForDate(date string) string {
+	return strings.ReplaceAll(date, "-", "_") + "-chaos-im-radio.mp3"
+}
+
+// dateFromSoundFileName extracts the date in the format YYYY-MM-DD from a canonical sound file name or path
+func dateFromSoundFileName(name string) (string, bool) {
+	matches := soundFileNameRegex.FindStringSubmatch(path.Base(name))
+	if len(matches) < 4 {
+		return "", false
+	}
+	return fmt.Sprintf("%s-%s-%s", matches[1], matches[2], matches[3]), true
 }
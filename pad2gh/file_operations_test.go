@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -172,3 +174,63 @@ func TestWriteCommentsFileNoErrors(t *testing.T) {
 		t.Error("Should not have errors section when there are no errors")
 	}
 }
+
+func TestRewriteContentYAMLKeepsEverything(t *testing.T) {
+	decodeAll := func(filePath string) []interface{} {
+		file, err := os.Open(filePath)
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", filePath, err)
+		}
+		defer file.Close()
+		var documents []interface{}
+		decoder := yaml.NewDecoder(file)
+		for {
+			var document interface{}
+			if err := decoder.Decode(&document); err != nil {
+				break
+			}
+			documents = append(documents, document)
+		}
+		return documents
+	}
+
+	entries, err := readYAMLEntries("../content.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read content.yaml: %v", err)
+	}
+	var orderedEntries []EntryWithOrder
+	for _, entry := range entries {
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: entry})
+	}
+	rewritten := filepath.Join(t.TempDir(), "content.yaml")
+	if err := writeAllYAMLEntries(orderedEntries, rewritten); err != nil {
+		t.Fatalf("writeAllYAMLEntries() failed: %v", err)
+	}
+
+	original, written := decodeAll("../content.yaml"), decodeAll(rewritten)
+	if len(original) == 0 || len(original) != len(written) {
+		t.Fatalf("expected %d entries, got %d", len(original), len(written))
+	}
+	for i := range original {
+		if !reflect.DeepEqual(original[i], written[i]) {
+			t.Errorf("entry %d changed:\n%v\n%v", i, original[i], written[i])
+		}
+	}
+
+	// the text of the entries which weren't changed is kept
+	content, _ := os.ReadFile("../content.yaml")
+	rewrittenContent, _ := os.ReadFile(rewritten)
+	if string(content) != string(rewrittenContent) {
+		t.Errorf("rewriting content.yaml without changes changed the text")
+	}
+	entries[0].Waveform = "waveforms/test.json"
+	if err := writeAllYAMLEntries(orderedEntries, rewritten); err != nil {
+		t.Fatalf("writeAllYAMLEntries() failed: %v", err)
+	}
+	rewrittenContent, _ = os.ReadFile(rewritten)
+	documents, changed := splitYAMLDocuments(string(content)), splitYAMLDocuments(string(rewrittenContent))
+	if len(changed) != len(documents) || !strings.Contains(changed[0], "waveform: 'waveforms/test.json'") ||
+		strings.Join(changed[1:], "") != strings.Join(documents[1:], "") {
+		t.Errorf("expected only the first entry to change")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// minFixConfidence is the confidence a candidate needs to be renamed with -fix-names, a date off by one day or
+// additional text like _raw or _teil2 is never renamed automatically
+const minFixConfidence = 0.7
+
+// SoundFileCandidate is a file that is probably the sound file of a pad, but not named canonically
+type SoundFileCandidate struct {
+	FileName   string  `json:"fileName"`
+	Confidence float64 `json:"confidence"` // 0..1
+	Reason     string  `json:"reason"`
+}
+
+// normalizeSoundFileName drops case and separators so only the relevant characters are compared
+func normalizeSoundFileName(name string) string {
+	return strings.NewReplacer("-", "", "_", "", ".", "", " ", "").Replace(strings.ToLower(name))
+}
+
+// scoreSoundFileName rates how close a file name is to the canonical name for the same date
+func scoreSoundFileName(name, expected string) (float64, string) {
+	switch {
+	case name == expected:
+		return 1, "canonical name"
+	case strings.EqualFold(name, expected):
+		return 0.95, "wrong case"
+	case normalizeSoundFileName(name) == normalizeSoundFileName(expected):
+		return 0.9, "wrong separators"
+	case normalizeSoundFileName(name) == normalizeSoundFileName(strings.TrimSuffix(expected, "-chaos-im-radio.mp3")+".mp3"):
+		return 0.7, "missing -chaos-im-radio suffix"
+	case strings.Contains(normalizeSoundFileName(name), "chaosimradio"):
+		// may be a raw recording or one of several parts, so it is only reported
+		return 0.6, "additional text in name"
+	}
+	return 0.5, "same date, different name"
+}
+
+// findSoundFileCandidates returns the near-misses of the canonical sound file name of a date,
+// the most likely candidate first
+func findSoundFileCandidates(date string, fileNames []string) []SoundFileCandidate {
+	padDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil
+	}
+	expected := soundFileNameForDate(date)
+
+	var candidates []SoundFileCandidate
+	for _, name := range fileNames {
+		if name == expected || !strings.EqualFold(path.Ext(name), ".mp3") {
+			continue
+		}
+		fileDateStr, ok := looseDateFromFileName(name)
+		if !ok {
+			continue
+		}
+		fileDate, err := time.Parse("2006-01-02", fileDateStr)
+		if err != nil {
+			continue
+		}
+		dayOffset := fileDate.Sub(padDate).Hours() / 24
+		if dayOffset < -1 || dayOffset > 1 {
+			continue
+		}
+
+		confidence, reason := scoreSoundFileName(name, soundFileNameForDate(fileDateStr))
+		if dayOffset != 0 {
+			confidence *= 0.5
+			reason += ", date off by one day"
+		}
+		candidates = append(candidates, SoundFileCandidate{FileName: name, Confidence: confidence, Reason: reason})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+	return candidates
+}
+
+// matchSoundFileCandidates looks for near-misses of every mapping without the canonical sound file
+func matchSoundFileCandidates(mappings []PadMapping, fileNames []string, config *Config) {
+	for i := range mappings {
+		if mappings[i].hasSoundFile(config) {
+			continue
+		}
+		mappings[i].SoundFileCandidates = findSoundFileCandidates(mappings[i].Date, fileNames)
+	}
+}
+
+// fixableCandidate returns the candidate that can be renamed without asking: confident and unambiguous
+func fixableCandidate(candidates []SoundFileCandidate) (SoundFileCandidate, bool) {
+	if len(candidates) == 0 || candidates[0].Confidence < minFixConfidence {
+		return SoundFileCandidate{}, false
+	}
+	if len(candidates) > 1 && candidates[1].Confidence == candidates[0].Confidence {
+		return SoundFileCandidate{}, false
+	}
+	return candidates[0], true
+}
+
+// fixSoundFileNames renames confident candidates in the local sound directory to the canonical name
+func fixSoundFileNames(logger *logrus.Logger, mappings []PadMapping, config *Config) error {
+	if config.SoundDir == "" || config.FileOnline {
+		return fmt.Errorf("-fix-names only works on a local -sound-dir")
+	}
+
+	for i := range mappings {
+		mapping := &mappings[i]
+		if mapping.HasSoundFileLocal {
+			continue
+		}
+		candidate, ok := fixableCandidate(mapping.SoundFileCandidates)
+		if !ok {
+			continue
+		}
+
+		target := filepath.Join(config.SoundDir, mapping.SoundFileName)
+		if _, err := os.Stat(target); err == nil {
+			logger.Warnf("Not renaming %s, %s exists already", candidate.FileName, mapping.SoundFileName)
+			continue
+		}
+		err := os.Rename(filepath.Join(config.SoundDir, candidate.FileName), target)
+		if err != nil {
+			return fmt.Errorf("failed to rename %s: %v", candidate.FileName, err)
+		}
+		logger.Infof("Renamed %s to %s (%s)", candidate.FileName, mapping.SoundFileName, candidate.Reason)
+
+		mapping.HasSoundFileLocal = true
+		mapping.SoundFileCandidates = nil
+		if info, err := os.Stat(target); err == nil {
+			mapping.SoundFileSize = info.Size()
+		}
+	}
+	return nil
+}
+
+// applySoundFileCandidates lists the sound file directory and attaches the near-misses to the mappings,
+// with -fix-names confident candidates are renamed right away
+func applySoundFileCandidates(logger *logrus.Logger, mappings []PadMapping, config *Config) error {
+	if config.SoundDir == "" && !config.FileOnline {
+		return nil
+	}
+	fileNames, err := listSoundFiles(config)
+	if err != nil {
+		// the listing is a bonus, the mapping itself works without it
+		logger.Warnf("Cannot look for misnamed sound files: %v", err)
+		return nil
+	}
+	matchSoundFileCandidates(mappings, fileNames, config)
+
+	if config.FixNames {
+		return fixSoundFileNames(logger, mappings, config)
+	}
+	return nil
+}
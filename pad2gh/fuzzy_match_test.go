@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFindSoundFileCandidates(t *testing.T) {
+	fileNames := []string{
+		"2024_01_15-chaos-im-radio.MP3",
+		"2024-01-15-chaos-im-radio.mp3",
+		"2024_01_15.mp3",
+		"2024_01_15-chaos-im-radio_raw.mp3",
+		"2024_01_16-chaos-im-radio.mp3",
+		"2024_01_15-chaos-im-radio.opus",
+		"2024_02_12-chaos-im-radio.mp3",
+	}
+
+	candidates := findSoundFileCandidates("2024-01-15", fileNames)
+
+	expected := []struct {
+		fileName   string
+		confidence float64
+	}{
+		{"2024_01_15-chaos-im-radio.MP3", 0.95},
+		{"2024-01-15-chaos-im-radio.mp3", 0.9},
+		{"2024_01_15.mp3", 0.7},
+		{"2024_01_15-chaos-im-radio_raw.mp3", 0.6},
+		{"2024_01_16-chaos-im-radio.mp3", 0.5},
+	}
+	if len(candidates) != len(expected) {
+		t.Fatalf("Expected %d candidates, got %v", len(expected), candidates)
+	}
+	for i, e := range expected {
+		if candidates[i].FileName != e.fileName || candidates[i].Confidence != e.confidence {
+			t.Errorf("Candidate %d = %s (%.2f), want %s (%.2f)", i, candidates[i].FileName, candidates[i].Confidence, e.fileName, e.confidence)
+		}
+	}
+
+	// additional text may be a raw recording or a part, it is never renamed
+	if candidate, ok := fixableCandidate(candidates[3:4]); ok {
+		t.Errorf("Expected %s not to be renamed", candidate.FileName)
+	}
+}
+
+func TestFixSoundFileNames(t *testing.T) {
+	soundDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(soundDir, "2024-01-15-chaos-im-radio.mp3"), []byte("ID3"), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to create sound file: %v", err)
+	}
+
+	config := &Config{SoundDir: soundDir, FixNames: true}
+	mappings := []PadMapping{{Date: "2024-01-15", SoundFileName: "2024_01_15-chaos-im-radio.mp3"}}
+
+	err = applySoundFileCandidates(logrus.StandardLogger(), mappings, config)
+	if err != nil {
+		t.Fatalf("applySoundFileCandidates() failed: %v", err)
+	}
+	if !mappings[0].HasSoundFileLocal {
+		t.Errorf("Expected mapping to have a local sound file after renaming")
+	}
+	if _, err := os.Stat(filepath.Join(soundDir, "2024_01_15-chaos-im-radio.mp3")); err != nil {
+		t.Errorf("Expected sound file to be renamed to the canonical name")
+	}
+}
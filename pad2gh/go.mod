@@ -3,8 +3,13 @@ module yaspp/pad2gh
 go 1.19
 
 require (
+	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/image v0.18.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+require (
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	golang.org/x/text v0.16.0 // indirect
+)
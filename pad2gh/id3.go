@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// id3Padding is the padding appended to written tags, so later edits don't need to move the audio
+const id3Padding = 1024
+
+// ID3Chapter is a CHAP frame
+type ID3Chapter struct {
+	ElementID string
+	Start     time.Duration
+	End       time.Duration
+	Title     string
+	URL       string
+}
+
+// ID3Picture is an APIC frame
+type ID3Picture struct {
+	MimeType    string
+	PictureType byte // 3 = cover (front)
+	Description string
+	Data        []byte
+}
+
+// ID3Frame is a frame pad2gh doesn't interpret, it is written back unchanged
+type ID3Frame struct {
+	ID   string
+	Body []byte
+}
+
+// maxID3Chapters is the most chapters the entry count of a CTOC frame can hold
+const maxID3Chapters = 255
+
+// ID3Tag is the subset of an ID3v2 tag pad2gh writes and verifies
+type ID3Tag struct {
+	Version   byte              // 3 or 4
+	Text      map[string]string // text frames like TIT2, TALB, TPE1, TDRC
+	Pictures  []ID3Picture
+	Chapters  []ID3Chapter
+	Frames    []ID3Frame // all other frames, like COMM, TXXX or PRIV
+	TOC       []string   // element IDs of the chapters in the order of the CTOC frame
+	TOCTitle  string
+	tagLength int64 // length of the tag including header and padding as found in the file
+}
+
+// syncsafe encodes a 28 bit integer with 7 bits per byte
+func syncsafe(n int) []byte {
+	return []byte{byte(n>>21) & 0x7F, byte(n>>14) & 0x7F, byte(n>>7) & 0x7F, byte(n) & 0x7F}
+}
+
+func unsyncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// encodeID3Text encodes a string with its encoding byte: UTF-8 for ID3v2.4, UTF-16 with BOM for ID3v2.3
+func encodeID3Text(version byte, s string, terminate bool) []byte {
+	if version >= 4 {
+		b := append([]byte{0x03}, s...)
+		if terminate {
+			b = append(b, 0)
+		}
+		return b
+	}
+
+	b := []byte{0x01, 0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	if terminate {
+		b = append(b, 0, 0)
+	}
+	return b
+}
+
+// id3Frame encodes a frame with header, the size is syncsafe for ID3v2.4 only
+func id3Frame(version byte, id string, body []byte) []byte {
+	frame := []byte(id)
+	if version >= 4 {
+		frame = append(frame, syncsafe(len(body))...)
+	} else {
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(body)))
+	}
+	frame = append(frame, 0, 0) // flags
+	return append(frame, body...)
+}
+
+// encode renders the complete tag including header and padding
+func (t *ID3Tag) encode() []byte {
+	var frames bytes.Buffer
+
+	for _, id := range sortedKeys(t.Text) {
+		frames.Write(id3Frame(t.Version, id, encodeID3Text(t.Version, t.Text[id], false)))
+	}
+
+	for _, frame := range t.Frames {
+		frames.Write(id3Frame(t.Version, frame.ID, frame.Body))
+	}
+
+	for _, picture := range t.Pictures {
+		body := []byte{encodeID3Text(t.Version, "", false)[0]}
+		body = append(body, picture.MimeType...)
+		body = append(body, 0, picture.PictureType)
+		body = append(body, encodeID3Text(t.Version, picture.Description, true)[1:]...)
+		body = append(body, picture.Data...)
+		frames.Write(id3Frame(t.Version, "APIC", body))
+	}
+
+	if len(t.Chapters) > 0 {
+		body := append([]byte("toc"), 0)
+		// writeID3Tag refuses more than maxID3Chapters, the count is a single byte
+		body = append(body, 0x03, byte(len(t.Chapters))) // top level and ordered
+		for _, chapter := range t.Chapters {
+			body = append(body, chapter.ElementID...)
+			body = append(body, 0)
+		}
+		if t.TOCTitle != "" {
+			body = append(body, id3Frame(t.Version, "TIT2", encodeID3Text(t.Version, t.TOCTitle, false))...)
+		}
+		frames.Write(id3Frame(t.Version, "CTOC", body))
+	}
+
+	for _, chapter := range t.Chapters {
+		body := append([]byte(chapter.ElementID), 0)
+		body = binary.BigEndian.AppendUint32(body, uint32(chapter.Start.Milliseconds()))
+		body = binary.BigEndian.AppendUint32(body, uint32(chapter.End.Milliseconds()))
+		body = append(body, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF) // byte offsets unused
+		body = append(body, id3Frame(t.Version, "TIT2", encodeID3Text(t.Version, chapter.Title, false))...)
+		if chapter.URL != "" {
+			wxxx := append(encodeID3Text(t.Version, "", true), chapter.URL...)
+			body = append(body, id3Frame(t.Version, "WXXX", wxxx)...)
+		}
+		frames.Write(id3Frame(t.Version, "CHAP", body))
+	}
+
+	header := []byte{'I', 'D', '3', t.Version, 0, 0}
+	header = append(header, syncsafe(frames.Len()+id3Padding)...)
+	return append(append(header, frames.Bytes()...), make([]byte, id3Padding)...)
+}
+
+// decodeID3Text decodes a string with a leading encoding byte, returning the text up to the
+// terminator and the rest of the data
+func decodeID3Text(b []byte, terminated bool) (string, []byte) {
+	if len(b) == 0 {
+		return "", nil
+	}
+	encoding, b := b[0], b[1:]
+	return decodeID3String(encoding, b, terminated)
+}
+
+func decodeID3String(encoding byte, b []byte, terminated bool) (string, []byte) {
+	wide := encoding == 0x01 || encoding == 0x02
+
+	end, rest := len(b), []byte(nil)
+	if terminated {
+		for i := 0; i < len(b); i++ {
+			if !wide && b[i] == 0 {
+				end, rest = i, b[i+1:]
+				break
+			}
+			if wide && i%2 == 0 && i+1 < len(b) && b[i] == 0 && b[i+1] == 0 {
+				end, rest = i, b[i+2:]
+				break
+			}
+		}
+	}
+	text := b[:end]
+
+	switch encoding {
+	case 0x00:
+		runes := make([]rune, len(text))
+		for i, c := range text {
+			runes[i] = rune(c)
+		}
+		return strings.TrimRight(string(runes), "\x00"), rest
+	case 0x03:
+		return strings.TrimRight(string(text), "\x00"), rest
+	}
+
+	bigEndian := encoding == 0x02
+	if len(text) >= 2 {
+		if text[0] == 0xFF && text[1] == 0xFE {
+			text, bigEndian = text[2:], false
+		} else if text[0] == 0xFE && text[1] == 0xFF {
+			text, bigEndian = text[2:], true
+		}
+	}
+	units := make([]uint16, 0, len(text)/2)
+	for i := 0; i+1 < len(text); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(text[i])<<8|uint16(text[i+1]))
+		} else {
+			units = append(units, uint16(text[i+1])<<8|uint16(text[i]))
+		}
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00"), rest
+}
+
+// parseID3Frames walks the frames in b and calls fn for each of them
+func parseID3Frames(version byte, b []byte, fn func(id string, body []byte)) {
+	for len(b) >= 10 && b[0] != 0 {
+		id := string(b[0:4])
+		var size int
+		if version >= 4 {
+			size = unsyncsafe(b[4:8])
+		} else {
+			size = int(binary.BigEndian.Uint32(b[4:8]))
+		}
+		if size < 0 || 10+size > len(b) {
+			return
+		}
+		fn(id, b[10:10+size])
+		b = b[10+size:]
+	}
+}
+
+// parseID3Tag parses the ID3v2.3/2.4 tag at the beginning of data
+func parseID3Tag(data []byte) (*ID3Tag, error) {
+	if len(data) < 10 || string(data[0:3]) != "ID3" {
+		return nil, fmt.Errorf("no ID3v2 tag found")
+	}
+	tag := &ID3Tag{Version: data[3], Text: map[string]string{}}
+	if tag.Version != 3 && tag.Version != 4 {
+		return nil, fmt.Errorf("unsupported ID3v2.%d tag", tag.Version)
+	}
+	if data[5]&0x80 != 0 {
+		return nil, fmt.Errorf("unsynchronised ID3 tags are not supported")
+	}
+	tag.tagLength = id3v2Length(data)
+	if int64(len(data)) < tag.tagLength {
+		return nil, fmt.Errorf("ID3 tag is truncated")
+	}
+	frames := data[10:tag.tagLength]
+	if data[5]&0x40 != 0 && len(frames) >= 4 {
+		// skip the extended header
+		size := int(binary.BigEndian.Uint32(frames))
+		if tag.Version >= 4 {
+			size = unsyncsafe(frames)
+		} else {
+			size += 4
+		}
+		if size > len(frames) {
+			return nil, fmt.Errorf("invalid extended header")
+		}
+		frames = frames[size:]
+	}
+
+	chapters := map[string]ID3Chapter{}
+	var chapterOrder []string
+	parseID3Frames(tag.Version, frames, func(id string, body []byte) {
+		switch {
+		case id == "APIC" && len(body) > 1:
+			encoding := body[0]
+			mimeEnd := bytes.IndexByte(body[1:], 0)
+			if mimeEnd < 0 || 1+mimeEnd+2 > len(body) {
+				return
+			}
+			picture := ID3Picture{MimeType: string(body[1 : 1+mimeEnd]), PictureType: body[1+mimeEnd+1]}
+			picture.Description, picture.Data = decodeID3String(encoding, body[1+mimeEnd+2:], true)
+			tag.Pictures = append(tag.Pictures, picture)
+		case id == "CHAP":
+			elementEnd := bytes.IndexByte(body, 0)
+			if elementEnd < 0 || elementEnd+17 > len(body) {
+				return
+			}
+			chapter := ID3Chapter{
+				ElementID: string(body[:elementEnd]),
+				Start:     time.Duration(binary.BigEndian.Uint32(body[elementEnd+1:])) * time.Millisecond,
+				End:       time.Duration(binary.BigEndian.Uint32(body[elementEnd+5:])) * time.Millisecond,
+			}
+			parseID3Frames(tag.Version, body[elementEnd+17:], func(subID string, subBody []byte) {
+				switch subID {
+				case "TIT2":
+					chapter.Title, _ = decodeID3Text(subBody, false)
+				case "WXXX":
+					if len(subBody) > 0 {
+						_, rest := decodeID3Text(subBody, true)
+						chapter.URL = strings.TrimRight(string(rest), "\x00")
+					}
+				}
+			})
+			chapters[chapter.ElementID] = chapter
+			chapterOrder = append(chapterOrder, chapter.ElementID)
+		case id == "CTOC":
+			elementEnd := bytes.IndexByte(body, 0)
+			if elementEnd < 0 || elementEnd+3 > len(body) {
+				return
+			}
+			count := int(body[elementEnd+2])
+			rest := body[elementEnd+3:]
+			for i := 0; i < count; i++ {
+				childEnd := bytes.IndexByte(rest, 0)
+				if childEnd < 0 {
+					return
+				}
+				tag.TOC = append(tag.TOC, string(rest[:childEnd]))
+				rest = rest[childEnd+1:]
+			}
+			parseID3Frames(tag.Version, rest, func(subID string, subBody []byte) {
+				if subID == "TIT2" {
+					tag.TOCTitle, _ = decodeID3Text(subBody, false)
+				}
+			})
+		case strings.HasPrefix(id, "T") && id != "TXXX":
+			tag.Text[id], _ = decodeID3Text(body, false)
+		default:
+			tag.Frames = append(tag.Frames, ID3Frame{ID: id, Body: body})
+		}
+	})
+
+	// chapters are ordered by the table of contents, falling back to the order in the file
+	order := tag.TOC
+	if len(order) == 0 {
+		order = chapterOrder
+	}
+	for _, elementID := range order {
+		if chapter, exists := chapters[elementID]; exists {
+			tag.Chapters = append(tag.Chapters, chapter)
+		}
+	}
+	return tag, nil
+}
+
+// readID3Tag reads the ID3v2 tag of a file
+func readID3Tag(filePath string) (*ID3Tag, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readID3TagAt(file)
+}
+
+// readID3TagAt reads the ID3v2 tag at the beginning of r, which may also be an httpRangeReader
+func readID3TagAt(r io.ReaderAt) (*ID3Tag, error) {
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	length := id3v2Length(header)
+	if length == 0 {
+		return nil, fmt.Errorf("no ID3v2 tag found")
+	}
+	data := make([]byte, length)
+	copy(data, header)
+	if _, err := r.ReadAt(data[10:], 10); err != nil {
+		return nil, fmt.Errorf("failed to read ID3 tag: %v", err)
+	}
+	return parseID3Tag(data)
+}
+
+// writeID3Tag replaces the ID3v2 tag of a file, the audio frames are copied unchanged
+func writeID3Tag(filePath string, tag *ID3Tag) error {
+	if len(tag.Chapters) > maxID3Chapters {
+		return fmt.Errorf("%d chapters are more than an ID3 table of contents can hold (%d)", len(tag.Chapters), maxID3Chapters)
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, 10)
+	n, _ := io.ReadFull(file, header)
+	audioStart := id3v2Length(header[:n])
+	if _, err := file.Seek(audioStart, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), ".pad2gh-tag-*.mp3")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(tag.encode()); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write tag: %v", err)
+	}
+	if _, err := io.Copy(tmpFile, file); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to copy audio: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if info, err := file.Stat(); err == nil {
+		_ = os.Chmod(tmpFile.Name(), info.Mode())
+	}
+
+	return os.Rename(tmpFile.Name(), filePath)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// limits of the ingest validation
+const (
+	ingestSettleTime   = time.Minute // files changed more recently are probably still being uploaded
+	minEpisodeDuration = 10 * time.Minute
+	maxEpisodeDuration = 4 * time.Hour
+)
+
+// IngestRecord is the result of ingesting one file of the drop folder
+type IngestRecord struct {
+	Source        string    `json:"source"`
+	SourceSize    int64     `json:"sourceSize"`
+	SourceModTime time.Time `json:"sourceModTime"`
+	FileName      string    `json:"fileName,omitempty"` // canonical name in -sound-dir, empty if rejected
+	Date          string    `json:"date,omitempty"`
+	Size          int64     `json:"size,omitempty"`
+	SHA256        string    `json:"sha256,omitempty"`
+	Duration      string    `json:"duration,omitempty"`
+	Loudness      *float64  `json:"loudness,omitempty"` // LUFS, only measured for MP3s
+	Warnings      []string  `json:"warnings,omitempty"`
+	Rejected      []string  `json:"rejected,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// IngestManifest records every file the ingest command has handled
+type IngestManifest struct {
+	Files []IngestRecord `json:"files"`
+}
+
+func loadIngestManifest(manifestPath string) (*IngestManifest, error) {
+	manifest := &IngestManifest{Files: []IngestRecord{}}
+
+	content, err := os.ReadFile(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	err = json.Unmarshal(content, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", manifestPath, err)
+	}
+	return manifest, nil
+}
+
+// ingested returns the record of a sound file moved into -sound-dir
+func (m *IngestManifest) ingested(fileName string) (IngestRecord, bool) {
+	for i := len(m.Files) - 1; i >= 0; i-- {
+		if m.Files[i].FileName == fileName {
+			return m.Files[i], true
+		}
+	}
+	return IngestRecord{}, false
+}
+
+// seen reports whether an unchanged file of the drop folder was rejected before, so it isn't validated again
+func (m *IngestManifest) seen(source string, info os.FileInfo) bool {
+	for _, record := range m.Files {
+		if record.Source == source && record.SourceSize == info.Size() && record.SourceModTime.Equal(info.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// dateFromTag returns the recording date of an ID3 tag, if it carries a complete one
+func dateFromTag(tag *ID3Tag) (string, bool) {
+	if tdrc := tag.Text["TDRC"]; len(tdrc) >= 10 {
+		if _, err := time.Parse("2006-01-02", tdrc[:10]); err == nil {
+			return tdrc[:10], true
+		}
+	}
+	tyer, tdat := tag.Text["TYER"], tag.Text["TDAT"]
+	if len(tyer) == 4 && len(tdat) == 4 {
+		date := fmt.Sprintf("%s-%s-%s", tyer, tdat[2:], tdat[:2])
+		if _, err := time.Parse("2006-01-02", date); err == nil {
+			return date, true
+		}
+	}
+	return "", false
+}
+
+// validateDropFile checks format, duration and loudness of a file and works out its episode date
+func validateDropFile(filePath string) IngestRecord {
+	record := IngestRecord{Source: filepath.Base(filePath)}
+
+	probe, err := probeLocalAudio(filePath)
+	if err != nil {
+		record.Rejected = append(record.Rejected, fmt.Sprintf("not a valid audio file: %v", err))
+		return record
+	}
+	record.Size = probe.Size
+	record.Duration = formatDuration(probe.Duration)
+	record.SHA256, _, err = fileDigest(filePath)
+	if err != nil {
+		record.Rejected = append(record.Rejected, fmt.Sprintf("cannot be read: %v", err))
+	}
+	if probe.Duration < minEpisodeDuration || probe.Duration > maxEpisodeDuration {
+		record.Rejected = append(record.Rejected, fmt.Sprintf("duration %s is not between %s and %s", record.Duration, minEpisodeDuration, maxEpisodeDuration))
+	}
+
+	isMP3 := strings.EqualFold(filepath.Ext(filePath), ".mp3")
+	if isMP3 {
+		config := &Config{SoundDir: filepath.Dir(filePath)}
+		quality, err := analyzeSoundFile(filepath.Base(filePath), config)
+		if err != nil {
+			record.Rejected = append(record.Rejected, fmt.Sprintf("cannot be decoded: %v", err))
+		} else {
+			loudness := quality.Loudness
+			switch {
+			case quality.LeadingSilence == quality.Duration:
+				record.Rejected = append(record.Rejected, "audio is completely silent")
+			case math.Abs(loudness-targetLoudness) > loudnessTolerance:
+				record.Loudness = &loudness
+				record.Rejected = append(record.Rejected, fmt.Sprintf("integrated loudness is %.1f LUFS, expected %.0f LUFS", loudness, targetLoudness))
+			default:
+				record.Loudness = &loudness
+				record.Warnings = quality.warnings()
+			}
+		}
+	} else {
+		record.Warnings = append(record.Warnings, "loudness is only checked for MP3 files")
+	}
+
+	nameDate, ok := looseDateFromFileName(filePath)
+	date := nameDate
+	if ok {
+		// the loose format also matches numbers like 20241345
+		_, err := time.Parse("2006-01-02", date)
+		ok = err == nil
+	}
+	if !ok && isMP3 {
+		if tag, err := readID3Tag(filePath); err == nil {
+			date, ok = dateFromTag(tag)
+		}
+	}
+	if !ok {
+		if nameDate != "" {
+			record.Rejected = append(record.Rejected, fmt.Sprintf("%s in the file name is not a valid date and the tags have none", nameDate))
+		} else {
+			record.Rejected = append(record.Rejected, "no date in the file name or tags")
+		}
+		return record
+	}
+	record.Date = date
+	return record
+}
+
+// moveFileAtomically moves a file without ever replacing an existing target or leaving a partial file under the
+// target name, falling back to copying across file systems
+func moveFileAtomically(source, target string) error {
+	err := os.Link(source, target)
+	if err == nil {
+		return os.Remove(source)
+	}
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s exists already", filepath.Base(target))
+	}
+
+	// hard links don't work across file systems, so the copy gets its final name only once it's complete
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmpFile, err := os.CreateTemp(filepath.Dir(target), ".pad2gh-ingest-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := io.Copy(tmpFile, in); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to copy %s: %v", filepath.Base(source), err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to copy %s: %v", filepath.Base(source), err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to copy %s: %v", filepath.Base(source), err)
+	}
+	err = os.Link(tmpFile.Name(), target)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s exists already", filepath.Base(target))
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
+
+// ingestDropFolder validates the settled audio files of the drop folder and moves the valid ones into -sound-dir
+// under their canonical name. Rejected files stay where they are and are only checked again once they change.
+func ingestDropFolder(logger *logrus.Logger, dropDir string, config *Config, manifest *IngestManifest, now time.Time) (int, error) {
+	dirEntries, err := os.ReadDir(dropDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read drop folder: %v", err)
+	}
+	sort.Slice(dirEntries, func(i, j int) bool {
+		return dirEntries[i].Name() < dirEntries[j].Name()
+	})
+
+	ingested := 0
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !isAudioFile(dirEntry.Name()) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) < ingestSettleTime {
+			logger.Debugf("Skipping %s, it was changed less than %s ago", dirEntry.Name(), ingestSettleTime)
+			continue
+		}
+		if manifest.seen(dirEntry.Name(), info) {
+			continue
+		}
+
+		sourcePath := filepath.Join(dropDir, dirEntry.Name())
+		record := validateDropFile(sourcePath)
+		record.SourceSize, record.SourceModTime, record.Time = info.Size(), info.ModTime(), now
+
+		if len(record.Rejected) == 0 {
+			fileName := renditionFileName(record.Date, strings.ToLower(filepath.Ext(dirEntry.Name())))
+			err = moveFileAtomically(sourcePath, filepath.Join(config.SoundDir, fileName))
+			if err != nil {
+				record.Rejected = append(record.Rejected, fmt.Sprintf("cannot be moved: %v", err))
+			} else {
+				record.FileName = fileName
+			}
+		}
+
+		if len(record.Rejected) > 0 {
+			logger.Warnf("Rejected %s: %s", record.Source, strings.Join(record.Rejected, ", "))
+		} else {
+			ingested++
+			logger.Infof("Ingested %s as %s (%s)", record.Source, record.FileName, record.Duration)
+			for _, warning := range record.Warnings {
+				logger.Warnf("  - %s", warning)
+			}
+		}
+		manifest.Files = append(manifest.Files, record)
+
+		// saved after every file, so the manifest never misses a file that was moved
+		err = writeJSONAtomically(config.IngestManifestPath, manifest)
+		if err != nil {
+			return ingested, err
+		}
+	}
+	return ingested, nil
+}
+
+// runIngestCommand ingests the drop folder given as argument once, or every -interval with -poll
+func runIngestCommand(logger *logrus.Logger, config *Config) error {
+	if len(config.Args) != 1 {
+		return fmt.Errorf("usage: pad2gh ingest -sound-dir <media dir> <drop folder>")
+	}
+	if config.SoundDir == "" {
+		return fmt.Errorf("ingest needs -sound-dir to move the files to")
+	}
+	dropDir := config.Args[0]
+
+	manifest, err := loadIngestManifest(config.IngestManifestPath)
+	if err != nil {
+		return err
+	}
+
+	if !config.Poll {
+		ingested, err := ingestDropFolder(logger, dropDir, config, manifest, time.Now())
+		logger.Infof("Ingested %d files from %s", ingested, dropDir)
+		return err
+	}
+
+	logger.Infof("Watching %s every %s", dropDir, config.WatchInterval)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(config.WatchInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := ingestDropFolder(logger, dropDir, config, manifest, time.Now())
+		if err != nil {
+			logger.Errorf("Ingest failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Shutting down ingest")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestIngestDropFolder(t *testing.T) {
+	dropDir, soundDir := t.TempDir(), t.TempDir()
+	config := &Config{SoundDir: soundDir, IngestManifestPath: filepath.Join(t.TempDir(), "ingest.json")}
+	now := time.Now()
+
+	files := map[string][]byte{
+		"2024-01-15 Sendung.opus":   buildTestOgg(312, 48000*15*60+312),
+		"2024.01.15 nochmal.opus":   buildTestOgg(312, 48000*15*60+312),
+		"aufnahme.m4a":              buildTestMP4(1000, 1000*20*60),
+		"2024_01_22.mp3":            buildTestMP3(100, true, false),
+		"2024-01-29 noch offen.m4a": buildTestMP4(1000, 1000*20*60),
+		"2024-13-45 Sendung.opus":   buildTestOgg(312, 48000*15*60+312),
+		"notizen.txt":               []byte("keine Audiodatei"),
+	}
+	for name, data := range files {
+		filePath := filepath.Join(dropDir, name)
+		if err := os.WriteFile(filePath, data, 0o644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		modTime := now.Add(-time.Hour)
+		if strings.Contains(name, "offen") {
+			modTime = now.Add(-10 * time.Second)
+		}
+		if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set time of %s: %v", name, err)
+		}
+	}
+
+	manifest, _ := loadIngestManifest(config.IngestManifestPath)
+	ingested, err := ingestDropFolder(logrus.New(), dropDir, config, manifest, now)
+	if err != nil {
+		t.Fatalf("ingestDropFolder() failed: %v", err)
+	}
+	if ingested != 1 {
+		t.Errorf("Expected 1 ingested file, got %d", ingested)
+	}
+	if _, err := os.Stat(filepath.Join(soundDir, "2024_01_15-chaos-im-radio.opus")); err != nil {
+		t.Errorf("Expected the opus file under its canonical name: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dropDir, "2024-01-15 Sendung.opus")); !os.IsNotExist(err) {
+		t.Errorf("Expected the ingested file to be moved out of the drop folder")
+	}
+
+	rejected := map[string]string{}
+	for _, record := range manifest.Files {
+		if len(record.Rejected) > 0 {
+			rejected[record.Source] = strings.Join(record.Rejected, ", ")
+		}
+	}
+	expectedRejections := map[string]string{
+		"2024.01.15 nochmal.opus": "cannot be moved: 2024_01_15-chaos-im-radio.opus exists already",
+		"aufnahme.m4a":            "no date in the file name or tags",
+		"2024-13-45 Sendung.opus": "2024-13-45 in the file name is not a valid date and the tags have none",
+		"2024_01_22.mp3":          "duration 00:00:02.612 is not between 10m0s and 4h0m0s, audio is completely silent",
+	}
+	for source, reason := range expectedRejections {
+		if rejected[source] != reason {
+			t.Errorf("Expected %s to be rejected with %q, got %q", source, reason, rejected[source])
+		}
+	}
+	if len(manifest.Files) != 5 {
+		t.Errorf("Expected 5 records, the unsettled and the text file are skipped, got %d", len(manifest.Files))
+	}
+
+	// the manifest on disk lets the next run skip the rejected files and the import trust the ingested one
+	manifest, err = loadIngestManifest(config.IngestManifestPath)
+	if err != nil {
+		t.Fatalf("loadIngestManifest() failed: %v", err)
+	}
+	ingested, err = ingestDropFolder(logrus.New(), dropDir, config, manifest, now)
+	if err != nil || ingested != 0 || len(manifest.Files) != 5 {
+		t.Errorf("Expected the second run to do nothing, got %d ingested, %d records, error %v", ingested, len(manifest.Files), err)
+	}
+
+	entry := &CiREntry{Audio: []CiRaudio{{Url: "$media_base_url/2024_01_15-chaos-im-radio.opus"}}}
+	probeEntryAudio(entry, config)
+	if entry.Audio[0].Duration != "00:15:00.000" || entry.Audio[0].Size != int64(len(files["2024-01-15 Sendung.opus"])) {
+		t.Errorf("Expected size and duration from the manifest, got %+v", entry.Audio[0])
+	}
+}
+
+func TestDateFromTag(t *testing.T) {
+	tests := []struct {
+		text     map[string]string
+		expected string
+	}{
+		{map[string]string{"TDRC": "2024-01-15"}, "2024-01-15"},
+		{map[string]string{"TDRC": "2024-01-15T20:00"}, "2024-01-15"},
+		{map[string]string{"TYER": "2024", "TDAT": "1501"}, "2024-01-15"},
+		{map[string]string{"TYER": "2024"}, ""},
+		{map[string]string{"TDRC": "2024"}, ""},
+	}
+	for _, tt := range tests {
+		date, _ := dateFromTag(&ID3Tag{Text: tt.text})
+		if date != tt.expected {
+			t.Errorf("dateFromTag(%v) = %q, expected %q", tt.text, date, tt.expected)
+		}
+	}
+}
@@ -6,38 +6,102 @@ import (
 	"flag"
 	"log"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 // Config holds all command line options for the application
 type Config struct {
-	ContentFilePath  string
-	CommentsFilePath string
-	PadURL           string
-	Verbose          bool
-	BulkMode         bool
-	MapOnly          bool
-	SoundDir         string
-	FileOnline       bool
-	ContinueOnError  bool
-	StrictMode       bool
-	MaxNewEntries    int
-	PadBaseURL       string
-	FileBaseURL      string
+	ContentFilePath     string
+	CommentsFilePath    string
+	PadURL              string
+	Verbose             bool
+	BulkMode            bool
+	MapOnly             bool
+	SoundDir            string
+	FileOnline          bool
+	ContinueOnError     bool
+	StrictMode          bool
+	MaxNewEntries       int
+	PadBaseURL          string
+	FileBaseURL         string
+	WatchInterval       time.Duration
+	StateFilePath       string
+	OnImportCommand     string
+	ListenAddr          string
+	WebhookSecret       string
+	Format              string
+	OutputPath          string
+	WebDAV              bool
+	FixNames            bool
+	EntryUUID           string
+	CoverPath           string
+	CoverDir            string
+	PeopleFilePath      string
+	PrivacyRulesPath    string
+	ID3Version          int
+	CheckAudio          bool
+	SuggestChapters     bool
+	WaveformDir         string
+	TranscriptDir       string
+	TranscriptRulesPath string
+	WhisperDir          string
+	PreviewDir          string
+	AudioFormats        string
+	IngestManifestPath  string
+	Poll                bool
+	RecordDigests       bool
+	Args                []string
+}
+
+// commands maps the subcommand names to their implementation. Running pad2gh
+// without a subcommand keeps the original single-entry and bulk behaviour.
+// Commands working on one part of the entries are two words, like "export chapters".
+var commands = map[string]func(logger *logrus.Logger, config *Config) error{
+	"watch":        runWatchMode,
+	"serve":        runServeMode,
+	"report":       runReportCommand,
+	"reconcile":    runReconcileCommand,
+	"probe":        runProbeCommand,
+	"tag":          runTagCommand,
+	"verify-tags":  runVerifyTagsCommand,
+	"segment":      runSegmentCommand,
+	"waveform":     runWaveformCommand,
+	"ingest":       runIngestCommand,
+	"verify-media": runVerifyMediaCommand,
+	"transcripts":  runTranscriptsCommand,
+	"index":        runIndexCommand,
+	"preview":      runPreviewCommand,
+	"persons":      runPersonsCommand,
+
+	"export chapters":   runExportChaptersCommand,
+	"import chapters":   runImportChaptersCommand,
+	"clean transcripts": runCleanTranscriptsCommand,
 }
 
 func main() {
 	logger := logrus.StandardLogger()
 
+	command, args := splitCommand(os.Args[1:])
+
 	// Parse command line flags into config struct
-	config := parseFlags()
+	config := parseFlags(command, args)
 
 	if config.Verbose {
 		logger.SetLevel(logrus.DebugLevel)
 	}
 
+	if command != "" {
+		err := commands[command](logger, config)
+		if err != nil {
+			logger.Fatalf("Error in %s: %v", command, err)
+		}
+		return
+	}
+
 	if config.BulkMode {
 		err := processBulkMode(logger, config)
 		if err != nil {
@@ -47,45 +111,242 @@ func main() {
 	}
 
 	// Original single-entry processing mode
-	var entry CiREntry
+	padURL := config.PadURL
 	var err error
-	if config.PadURL == "" {
+	if padURL == "" {
 		u, _ := url.JoinPath(config.PadBaseURL, "Radio")
-		entry.padURL, err = getFirstLink(u, config.PadBaseURL)
+		padURL, err = getFirstLink(u, config.PadBaseURL)
 		if err != nil {
 			log.Fatal(err)
 		}
-	} else {
-		if !strings.HasPrefix(config.PadURL, config.PadBaseURL) {
-			log.Fatal("pad url must start with " + config.PadBaseURL)
-		}
-		entry.padURL = config.PadURL
+	} else if !strings.HasPrefix(padURL, config.PadBaseURL) {
+		log.Fatal("pad url must start with " + config.PadBaseURL)
 	}
 
-	err = processSingleEntry(logger, &entry, config)
+	err = processSingleEntry(logger, padURL, config)
 	if err != nil {
 		logger.Fatalf("Error processing single entry: %v", err)
 	}
 }
 
-// parseFlags parses command line flags and returns a Config struct
-func parseFlags() *Config {
+// splitCommand separates a leading subcommand from the remaining arguments.
+// An empty command is returned if the first argument is not a known subcommand.
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 1 {
+		if _, ok := commands[args[0]+" "+args[1]]; ok {
+			return args[0] + " " + args[1], args[2:]
+		}
+	}
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			return args[0], args[1:]
+		}
+	}
+	return "", args
+}
+
+// flagDefinitions register the flags on the FlagSet of a command, commandFlags says which command gets which
+var flagDefinitions = map[string]func(flags *flag.FlagSet, config *Config){
+	"o": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.ContentFilePath, "o", "../content.yaml", "specify the yaml file with the entries")
+	},
+	"c": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.CommentsFilePath, "c", "../pr-comments.md", "specify the md file to write PR comments to")
+	},
+	"l": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.PadURL, "l", "", "specify the link to the pad entry you want to parse")
+	},
+	"v": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.Verbose, "v", false, "verbose output")
+	},
+	"bulk": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.BulkMode, "bulk", false, "process all pad entries found on the Radio page")
+	},
+	"map-only": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.MapOnly, "map-only", false, "only create mapping report, don't add new entries (with -bulk)")
+	},
+	"sound-dir": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.SoundDir, "sound-dir", "", "specify the local directory to check for sound files")
+	},
+	"file-online": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.FileOnline, "file-online", false, "check sound files via HTTP instead of checking local directory")
+	},
+	"continue-on-error": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.ContinueOnError, "continue-on-error", false, "continue processing entries even if one fails (with -bulk)")
+	},
+	"strict": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.StrictMode, "strict", false, "only create entries if there are no errors in the pad for this episode")
+	},
+	"max-new-entries": func(flags *flag.FlagSet, config *Config) {
+		flags.IntVar(&config.MaxNewEntries, "max-new-entries", 0, "limit number of new entries to create (with -bulk, 0 = unlimited)")
+	},
+	"pad-base-url": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.PadBaseURL, "pad-base-url", "https://pad.ccc-p.org/", "base URL for pad entries")
+	},
+	"file-base-url": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.FileBaseURL, "file-base-url", "https://radio.ccc-p.org/files/", "base URL for sound files")
+	},
+	"interval": func(flags *flag.FlagSet, config *Config) {
+		flags.DurationVar(&config.WatchInterval, "interval", 15*time.Minute, "time between two polls")
+	},
+	"state": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.StateFilePath, "state", "../.pad2gh-state.json", "specify the json file to remember already imported episodes in")
+	},
+	"on-import": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.OnImportCommand, "on-import", "", "shell command to run after an episode was imported, e.g. to create a PR")
+	},
+	"listen": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.ListenAddr, "listen", ":8080", "address to listen on for webhook calls")
+	},
+	"webhook-secret": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.WebhookSecret, "webhook-secret", "", "shared secret to verify webhook calls, defaults to $PAD2GH_WEBHOOK_SECRET")
+	},
+	"webdav": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.WebDAV, "webdav", false, "list sound files via WebDAV instead of the HTTP autoindex (with -file-online)")
+	},
+	"fix-names": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.FixNames, "fix-names", false, "rename misnamed local sound files to the canonical name YYYY_MM_DD-chaos-im-radio.mp3")
+	},
+	"entry": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.EntryUUID, "entry", "", "uuid of the YAML entry to work on")
+	},
+	"cover": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.CoverPath, "cover", "../cover.jpg", "specify the podcast cover for episodes without their own")
+	},
+	"id3-version": func(flags *flag.FlagSet, config *Config) {
+		flags.IntVar(&config.ID3Version, "id3-version", 3, "ID3v2 version to write, 3 or 4")
+	},
+	"manifest": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.IngestManifestPath, "manifest", "../.pad2gh-ingest.json", "json file recording every ingested or rejected file")
+	},
+	"record-digests": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.RecordDigests, "record-digests", false, "add the SHA-256 digest of the file in -sound-dir to entries without one")
+	},
+	"poll": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.Poll, "poll", false, "keep scanning the drop folder every -interval")
+	},
+	"audio-formats": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.AudioFormats, "audio-formats", "opus,mp3,m4a,ogg", "order of the audio renditions of an entry, the player takes the first one the browser can play")
+	},
+	"cover-dir": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.CoverDir, "cover-dir", "../covers", "directory of the site output with the episode covers from the pads")
+	},
+	"people": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.PeopleFilePath, "people", "../people.yaml", "yaml file resolving the nicknames of the pads to names, links and avatars")
+	},
+	"privacy-rules": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.PrivacyRulesPath, "privacy-rules", "../privacy-rules.yaml", "yaml file with the public pads, internal markers and patterns to remove from the pad before publishing")
+	},
+	"preview-dir": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.PreviewDir, "preview-dir", "../previews", "directory of the site output to write the social preview images to")
+	},
+	"waveform-dir": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.WaveformDir, "waveform-dir", "../waveforms", "directory of the site output to write the waveform peaks to")
+	},
+	"transcript-dir": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.TranscriptDir, "transcript-dir", "../transcripts", "directory of the site output with the normalized transcripts")
+	},
+	"transcript-rules": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.TranscriptRulesPath, "transcript-rules", "../transcript-rules.yaml", "yaml file with the redaction list, glossary and hallucinated lines for transcripts")
+	},
+	"whisper-dir": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.WhisperDir, "whisper-dir", "", "directory with the output of transcribe-folder.sh to suggest chapters from the shownotes for pads without a chapters section")
+	},
+	"suggest-chapters": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.SuggestChapters, "suggest-chapters", false, "propose chapters from the speech and music in the sound file for pads without a chapters section")
+	},
+	"check-audio": func(flags *flag.FlagSet, config *Config) {
+		flags.BoolVar(&config.CheckAudio, "check-audio", false, "decode the sound file and report loudness, peaks, silence and clipping as warnings")
+	},
+	"out": func(flags *flag.FlagSet, config *Config) {
+		flags.StringVar(&config.OutputPath, "out", "", "specify the file to write the output to (default: stdout)")
+	},
+}
+
+var (
+	// soundFileFlags tell where the sound files are
+	soundFileFlags = []string{"sound-dir", "file-online", "file-base-url"}
+	// entryFlags are used by the pipeline creating an entry from a pad
+	entryFlags = flagNames([]string{"o", "c", "strict", "pad-base-url", "audio-formats", "manifest", "check-audio", "suggest-chapters",
+		"whisper-dir", "transcript-rules", "cover-dir", "people", "privacy-rules"}, soundFileFlags)
+)
+
+// commandFlags lists the flags of every command, "" is the single-entry and bulk mode without a subcommand
+var commandFlags = map[string][]string{
+	"":                  flagNames(entryFlags, []string{"l", "bulk", "map-only", "continue-on-error", "max-new-entries", "fix-names", "webdav", "out"}),
+	"watch":             flagNames(entryFlags, []string{"interval", "state", "on-import"}),
+	"serve":             flagNames(entryFlags, []string{"state", "on-import", "listen", "webhook-secret"}),
+	"report":            flagNames(soundFileFlags, []string{"o", "pad-base-url", "strict", "webdav", "fix-names", "out"}),
+	"reconcile":         flagNames(soundFileFlags, []string{"o", "pad-base-url", "webdav", "out"}),
+	"probe":             flagNames(soundFileFlags, []string{"o"}),
+	"tag":               {"o", "entry", "sound-dir", "cover", "cover-dir", "id3-version"},
+	"verify-tags":       flagNames(soundFileFlags, []string{"o", "entry", "out"}),
+	"segment":           flagNames(soundFileFlags, []string{"o", "entry", "out"}),
+	"waveform":          flagNames(soundFileFlags, []string{"o", "entry", "waveform-dir"}),
+	"ingest":            {"sound-dir", "manifest", "poll", "interval"},
+	"verify-media":      flagNames(soundFileFlags, []string{"o", "entry", "record-digests", "out"}),
+	"transcripts":       {"o", "entry", "transcript-dir", "transcript-rules", "out"},
+	"index":             {"o", "transcript-dir", "out"},
+	"preview":           {"o", "entry", "cover", "cover-dir", "preview-dir"},
+	"persons":           {"o", "people", "out"},
+	"export chapters":   {"o", "entry", "out"},
+	"import chapters":   {"o", "entry"},
+	"clean transcripts": {"o", "entry", "transcript-dir", "transcript-rules"},
+}
+
+// commandFormats are the values of -format of the commands which have it
+var commandFormats = map[string][]string{
+	"":                {"json", "csv", "md"},
+	"report":          {"html", "md"},
+	"reconcile":       {"md", "json"},
+	"verify-tags":     {"md", "json"},
+	"verify-media":    {"md", "json"},
+	"transcripts":     {"md", "json"},
+	"persons":         {"md", "json"},
+	"export chapters": {"json", "webvtt", "psc", "mp3chaps", "audacity"},
+	"import chapters": {"audacity", "reaper-csv", "mp3chaps", "psc"},
+}
+
+// flagNames joins groups of flag names
+func flagNames(groups ...[]string) []string {
+	var names []string
+	for _, group := range groups {
+		names = append(names, group...)
+	}
+	return names
+}
+
+// newFlagSet creates the FlagSet of a command with only the flags it uses, so -h shows what applies to it
+func newFlagSet(command string, config *Config, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	name := "pad2gh"
+	if command != "" {
+		name += " " + command
+	}
+	flags := flag.NewFlagSet(name, errorHandling)
+	flagDefinitions["v"](flags, config)
+	for _, flagName := range commandFlags[command] {
+		flagDefinitions[flagName](flags, config)
+	}
+	if formats, exists := commandFormats[command]; exists {
+		usage := "output format: " + strings.Join(formats, ", ")
+		if command == "" {
+			usage += " for the mapping of -bulk (requires -out without -map-only)"
+		}
+		flags.StringVar(&config.Format, "format", "", usage)
+	}
+	return flags
+}
+
+// parseFlags parses the command line flags of the command and returns a Config struct
+func parseFlags(command string, args []string) *Config {
 	config := &Config{}
+	flags := newFlagSet(command, config, flag.ExitOnError)
 
-	flag.StringVar(&config.ContentFilePath, "o", "../content.yaml", "specify the yaml file to write to")
-	flag.StringVar(&config.CommentsFilePath, "c", "../pr-comments.md", "specify the md file to write PR comments to")
-	flag.StringVar(&config.PadURL, "l", "", "specify the link to the pad entry you want to parse")
-	flag.BoolVar(&config.Verbose, "v", false, "verbose output")
-	flag.BoolVar(&config.BulkMode, "bulk", false, "process all pad entries found on the Radio page")
-	flag.BoolVar(&config.MapOnly, "map-only", false, "only create mapping report, don't add new entries")
-	flag.StringVar(&config.SoundDir, "sound-dir", "", "specify the local directory to check for sound files")
-	flag.BoolVar(&config.FileOnline, "file-online", false, "check sound files via HTTP instead of checking local directory")
-	flag.BoolVar(&config.ContinueOnError, "continue-on-error", false, "continue processing entries even if one fails (bulk mode only)")
-	flag.BoolVar(&config.StrictMode, "strict", false, "only create entries if there are no errors in the pad for this episode")
-	flag.IntVar(&config.MaxNewEntries, "max-new-entries", 0, "limit number of new entries to create in bulk mode (0 = unlimited)")
-	flag.StringVar(&config.PadBaseURL, "pad-base-url", "https://pad.ccc-p.org/", "base URL for pad entries")
-	flag.StringVar(&config.FileBaseURL, "file-base-url", "https://radio.ccc-p.org/files/", "base URL for sound files")
-
-	flag.Parse()
+	// flags may follow the positional arguments, like in "import chapters -format psc chapters.xml -entry <uuid>"
+	_ = flags.Parse(args)
+	for flags.NArg() > 0 {
+		config.Args = append(config.Args, flags.Arg(0))
+		_ = flags.Parse(flags.Args()[1:])
+	}
 	return config
 }
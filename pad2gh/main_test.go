@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCommandFlags(t *testing.T) {
+	for command := range commands {
+		if _, exists := commandFlags[command]; !exists {
+			t.Errorf("%s has no entry in commandFlags", command)
+		}
+	}
+	for command, names := range commandFlags {
+		for _, name := range names {
+			if _, exists := flagDefinitions[name]; !exists {
+				t.Errorf("%s uses the undefined flag -%s", command, name)
+			}
+		}
+	}
+
+	tests := []struct {
+		command string
+		args    []string
+		valid   bool
+	}{
+		{"", []string{"-bulk", "-map-only", "-format", "csv", "-out", "mapping.csv"}, true},
+		{"report", []string{"-format", "md", "-sound-dir", "/srv/radio/files"}, true},
+		{"report", []string{"-interval", "1m"}, false},
+		{"import chapters", []string{"-format", "psc", "chapters.xml", "-entry", "nt-2024-01-15"}, true},
+		{"import chapters", []string{"-out", "chapters.json"}, false},
+		{"ingest", []string{"-poll", "-interval", "5m", "/srv/radio/drop"}, true},
+		{"ingest", []string{"-format", "json"}, false},
+		{"tag", []string{"-entry", "nt-2024-01-15", "-id3-version", "4", "edit.mp3"}, true},
+		{"preview", []string{"-id3-version", "4"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command+" "+strings.Join(tt.args, " "), func(t *testing.T) {
+			flags := newFlagSet(tt.command, &Config{}, flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			err := flags.Parse(tt.args)
+			if (err == nil) != tt.valid {
+				t.Errorf("Parse() error = %v, expected valid: %v", err, tt.valid)
+			}
+		})
+	}
+
+	config := &Config{}
+	flags := newFlagSet("export chapters", config, flag.ContinueOnError)
+	if usage := flags.Lookup("format").Usage; usage != "output format: json, webvtt, psc, mp3chaps, audacity" {
+		t.Errorf("export chapters -format usage = %q", usage)
+	}
+	if config.ContentFilePath != "../content.yaml" || config.PadBaseURL != "" {
+		t.Errorf("expected only the defaults of the export chapters flags, got %+v", config)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MappingTotals are the counters of the mapping report
+type MappingTotals struct {
+	Pads          int `json:"pads"`
+	WithYAML      int `json:"withYAML"`
+	WithSoundFile int `json:"withSoundFile"`
+	Complete      int `json:"complete"`
+	MissingYAML   int `json:"missingYAML"`
+	Importable    int `json:"importable"`
+}
+
+// MappingRecord is the exported form of a PadMapping
+type MappingRecord struct {
+	Date              string               `json:"date"`
+	PadURL            string               `json:"padURL"`
+	YAMLEntryUUID     string               `json:"yamlEntryUUID,omitempty"`
+	ExpectedSoundFile string               `json:"expectedSoundFile"`
+	FoundSoundFiles   []string             `json:"foundSoundFiles"`
+	Candidates        []SoundFileCandidate `json:"soundFileCandidates,omitempty"`
+	SoundFileLocal    bool                 `json:"soundFileLocal"`
+	SoundFileOnline   bool                 `json:"soundFileOnline"`
+	SoundFileSize     int64                `json:"soundFileSize,omitempty"`
+	SkipReasons       []string             `json:"skipReasons,omitempty"`
+}
+
+// MappingExport is the document written by -format json
+type MappingExport struct {
+	Mappings []MappingRecord `json:"mappings"`
+	Totals   MappingTotals   `json:"totals"`
+}
+
+// skipReasons lists why bulk mode would not create an entry for the mapping before looking at the pad
+func skipReasons(mapping PadMapping, config *Config) []string {
+	var reasons []string
+	if mapping.HasYAMLEntry {
+		reasons = append(reasons, "YAML entry exists")
+	}
+	if !mapping.hasSoundFile(config) {
+		if config.FileOnline {
+			reasons = append(reasons, "sound file not online")
+		} else {
+			reasons = append(reasons, "no local sound file")
+		}
+	}
+	return reasons
+}
+
+// computeMappingTotals counts the mappings, sound files are counted where the config says to look for them
+func computeMappingTotals(mappings []PadMapping, config *Config) MappingTotals {
+	totals := MappingTotals{Pads: len(mappings)}
+	for _, mapping := range mappings {
+		hasSoundFile := mapping.hasSoundFile(config)
+		if mapping.HasYAMLEntry {
+			totals.WithYAML++
+		}
+		if hasSoundFile {
+			totals.WithSoundFile++
+		}
+		if mapping.HasYAMLEntry && hasSoundFile {
+			totals.Complete++
+		}
+		if !mapping.HasYAMLEntry && hasSoundFile {
+			totals.Importable++
+		}
+	}
+	totals.MissingYAML = totals.Pads - totals.WithYAML
+	return totals
+}
+
+func newMappingExport(mappings []PadMapping, config *Config) MappingExport {
+	export := MappingExport{Mappings: []MappingRecord{}, Totals: computeMappingTotals(mappings, config)}
+	for _, mapping := range mappings {
+		record := MappingRecord{
+			Date:              mapping.Date,
+			PadURL:            mapping.PadURL,
+			ExpectedSoundFile: mapping.SoundFileName,
+			FoundSoundFiles:   []string{},
+			SoundFileLocal:    mapping.HasSoundFileLocal,
+			SoundFileOnline:   mapping.HasSoundFileOnline,
+			SkipReasons:       mapping.SkipReasons,
+			Candidates:        mapping.SoundFileCandidates,
+		}
+		if mapping.YAMLEntry != nil {
+			record.YAMLEntryUUID = mapping.YAMLEntry.UUID
+		}
+		if mapping.HasSoundFileLocal || mapping.HasSoundFileOnline {
+			record.FoundSoundFiles = append(record.FoundSoundFiles, mapping.SoundFileName)
+			if mapping.SoundFileSize > 0 {
+				record.SoundFileSize = mapping.SoundFileSize
+			}
+		}
+		export.Mappings = append(export.Mappings, record)
+	}
+	return export
+}
+
+// writeMappingExport writes the mapping in the given format: json, csv or md
+func writeMappingExport(w io.Writer, mappings []PadMapping, config *Config, format string) error {
+	export := newMappingExport(mappings, config)
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	case "csv":
+		return writeMappingCSV(w, export)
+	case "md":
+		return writeMappingMarkdown(w, export)
+	}
+	return fmt.Errorf("unknown mapping format %s, use json, csv or md", format)
+}
+
+func writeMappingCSV(w io.Writer, export MappingExport) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"date", "pad_url", "yaml_entry_uuid", "expected_sound_file", "found_sound_files", "sound_file_local", "sound_file_online", "sound_file_size", "skip_reasons"})
+	if err != nil {
+		return err
+	}
+	for _, record := range export.Mappings {
+		err = writer.Write([]string{
+			record.Date,
+			record.PadURL,
+			record.YAMLEntryUUID,
+			record.ExpectedSoundFile,
+			strings.Join(record.FoundSoundFiles, ";"),
+			strconv.FormatBool(record.SoundFileLocal),
+			strconv.FormatBool(record.SoundFileOnline),
+			strconv.FormatInt(record.SoundFileSize, 10),
+			strings.Join(record.SkipReasons, ";"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeMappingMarkdown(w io.Writer, export MappingExport) error {
+	var b strings.Builder
+	b.WriteString("| Date | Pad | YAML entry | Expected sound file | Found | Skip reasons |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, record := range export.Mappings {
+		b.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s |\n",
+			record.Date, record.PadURL, record.YAMLEntryUUID, record.ExpectedSoundFile,
+			strings.Join(record.FoundSoundFiles, ", "), markdownCell(strings.Join(record.SkipReasons, ", "))))
+	}
+
+	totals := export.Totals
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("* Total pads found: %d\n", totals.Pads))
+	b.WriteString(fmt.Sprintf("* With YAML entries: %d\n", totals.WithYAML))
+	b.WriteString(fmt.Sprintf("* With sound files: %d\n", totals.WithSoundFile))
+	b.WriteString(fmt.Sprintf("* Complete (both): %d\n", totals.Complete))
+	b.WriteString(fmt.Sprintf("* Missing YAML entries: %d\n", totals.MissingYAML))
+	b.WriteString(fmt.Sprintf("* Ready to import: %d\n", totals.Importable))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MediaCheckResult is a copy of a published sound file that doesn't match content.yaml
+type MediaCheckResult struct {
+	UUID     string `json:"uuid"`
+	File     string `json:"file"`
+	Location string `json:"location"` // local directory or mirror base URL
+	Problem  string `json:"problem"`
+}
+
+// sha256Digest returns the hex encoded SHA-256 digest and the number of bytes read
+func sha256Digest(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", size, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// fileDigest returns the SHA-256 digest and size of a local file
+func fileDigest(filePath string) (string, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	return sha256Digest(file)
+}
+
+// localSoundFileDigest hashes the sound file in -sound-dir, remote files are not downloaded for this
+func localSoundFileDigest(fileName string, config *Config) (string, error) {
+	if config.SoundDir == "" {
+		return "", fmt.Errorf("no -sound-dir")
+	}
+	digest, _, err := fileDigest(filepath.Join(config.SoundDir, fileName))
+	return digest, err
+}
+
+// checkMediaCopy compares one copy of a sound file with size and digest of its audio entry
+func checkMediaCopy(audio CiRaudio, open func() (io.ReadCloser, int64, error)) string {
+	r, size, err := open()
+	if err != nil {
+		return err.Error()
+	}
+	defer r.Close()
+
+	// a different size is enough to know, without reading the whole file
+	if size >= 0 && audio.Size > 0 && size != audio.Size {
+		return fmt.Sprintf("size is %d bytes, expected %d", size, audio.Size)
+	}
+	digest, read, err := sha256Digest(r)
+	if err != nil {
+		return fmt.Sprintf("failed to read: %v", err)
+	}
+	if audio.Size > 0 && read != audio.Size {
+		return fmt.Sprintf("size is %d bytes, expected %d", read, audio.Size)
+	}
+	if digest != audio.SHA256 {
+		return fmt.Sprintf("SHA-256 is %s, expected %s", digest, audio.SHA256)
+	}
+	return ""
+}
+
+func openLocalCopy(filePath string) func() (io.ReadCloser, int64, error) {
+	return func() (io.ReadCloser, int64, error) {
+		file, err := os.Open(filePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, 0, fmt.Errorf("missing")
+			}
+			return nil, 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, info.Size(), nil
+	}
+}
+
+func openMirrorCopy(fileURL string) func() (io.ReadCloser, int64, error) {
+	return func() (io.ReadCloser, int64, error) {
+		resp, err := http.Get(fileURL)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, 0, fmt.Errorf("missing")
+			}
+			return nil, 0, fmt.Errorf("status code %d", resp.StatusCode)
+		}
+		return resp.Body, resp.ContentLength, nil
+	}
+}
+
+func writeMediaCheckMarkdown(w io.Writer, results []MediaCheckResult) error {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("## Sound files not matching content.yaml (%d)\n\n", len(results)))
+	if len(results) > 0 {
+		b.WriteString("| Entry | File | Location | Problem |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+	}
+	for _, result := range results {
+		b.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", result.UUID, result.File, result.Location, markdownCell(result.Problem)))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// runVerifyMediaCommand checks the copies in -sound-dir, at -file-base-url (with -file-online) and at the mirror
+// base URLs given as arguments against the digests in content.yaml. With -record-digests, entries without a digest
+// get the one of their file in -sound-dir.
+func runVerifyMediaCommand(logger *logrus.Logger, config *Config) error {
+	err := checkFormat("verify-media", config.Format, "md", "json")
+	if err != nil {
+		return err
+	}
+	var mirrors []string
+	if config.FileOnline {
+		mirrors = append(mirrors, config.FileBaseURL)
+	}
+	mirrors = append(mirrors, config.Args...)
+	if config.SoundDir == "" && len(mirrors) == 0 {
+		return fmt.Errorf("verify-media needs -sound-dir, -file-online or mirror URLs")
+	}
+	if config.RecordDigests && config.SoundDir == "" {
+		return fmt.Errorf("-record-digests needs -sound-dir")
+	}
+
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	var orderedEntries []EntryWithOrder
+	results := []MediaCheckResult{}
+	checked, recorded := 0, 0
+	for _, entry := range entries {
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: entry})
+		if config.EntryUUID != "" && entry.UUID != config.EntryUUID {
+			continue
+		}
+		for i := range entry.Audio {
+			audio := &entry.Audio[i]
+			fileName := soundFileNameFromAudioURL(audio.Url)
+
+			if audio.SHA256 == "" {
+				if !config.RecordDigests {
+					logger.Debugf("%s of %s has no digest yet", fileName, entry.UUID)
+					continue
+				}
+				digest, size, err := fileDigest(filepath.Join(config.SoundDir, fileName))
+				if err != nil {
+					logger.Warnf("Cannot record the digest of %s: %v", fileName, err)
+					continue
+				}
+				audio.SHA256, audio.Size = digest, size
+				recorded++
+				logger.Infof("Recorded digest of %s", fileName)
+				continue
+			}
+
+			if config.SoundDir != "" {
+				checked++
+				if problem := checkMediaCopy(*audio, openLocalCopy(filepath.Join(config.SoundDir, fileName))); problem != "" {
+					results = append(results, MediaCheckResult{UUID: entry.UUID, File: fileName, Location: config.SoundDir, Problem: problem})
+				}
+			}
+			for _, mirror := range mirrors {
+				fileURL, err := url.JoinPath(mirror, fileName)
+				if err != nil {
+					return fmt.Errorf("failed to construct file URL: %v", err)
+				}
+				checked++
+				if problem := checkMediaCopy(*audio, openMirrorCopy(fileURL)); problem != "" {
+					results = append(results, MediaCheckResult{UUID: entry.UUID, File: fileName, Location: mirror, Problem: problem})
+				}
+			}
+		}
+	}
+	logger.Infof("Checked %d copies, %d not matching", checked, len(results))
+
+	if recorded > 0 {
+		err = writeAllYAMLEntries(orderedEntries, config.ContentFilePath)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Recorded %d digests in %s", recorded, config.ContentFilePath)
+	}
+
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if config.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(results)
+	} else {
+		err = writeMediaCheckMarkdown(out, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(results) > 0 {
+		return fmt.Errorf("%d copies of sound files don't match %s", len(results), config.ContentFilePath)
+	}
+	return nil
+}
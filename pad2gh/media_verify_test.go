@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestVerifyMedia(t *testing.T) {
+	soundDir := t.TempDir()
+	contentFile := filepath.Join(t.TempDir(), "content.yaml")
+	outputFile := filepath.Join(t.TempDir(), "media.json")
+
+	files := map[string][]byte{
+		"2024_01_15-chaos-im-radio.mp3": []byte(strings.Repeat("first episode ", 1000)),
+		"2024_01_22-chaos-im-radio.mp3": []byte(strings.Repeat("second episode ", 1000)),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(soundDir, name), data, 0o644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	entries := []*CiREntry{
+		{UUID: "nt-2024-01-15", PublicationDate: "2024-01-15T00:00:00+00:00", Audio: []CiRaudio{{Url: "$media_base_url/2024_01_15-chaos-im-radio.mp3", MimeType: "audio/mpeg"}}},
+		{UUID: "nt-2024-01-22", PublicationDate: "2024-01-22T00:00:00+00:00", Audio: []CiRaudio{{Url: "$media_base_url/2024_01_22-chaos-im-radio.mp3", MimeType: "audio/mpeg"}}},
+	}
+	if err := appendMultipleEntriesToYAML(entries, contentFile); err != nil {
+		t.Fatalf("Failed to write content file: %v", err)
+	}
+
+	config := &Config{ContentFilePath: contentFile, SoundDir: soundDir, OutputPath: outputFile, Format: "json", RecordDigests: true}
+	if err := runVerifyMediaCommand(logrus.New(), config); err != nil {
+		t.Fatalf("Recording digests failed: %v", err)
+	}
+	recorded, _ := readYAMLEntries(contentFile)
+	if recorded[0].Audio[0].SHA256 == "" || recorded[0].Audio[0].Size != int64(len(files["2024_01_15-chaos-im-radio.mp3"])) {
+		t.Fatalf("Expected digest and size to be recorded, got %+v", recorded[0].Audio[0])
+	}
+
+	// a mirror with a truncated and a missing file, and a local file re-encoded to the same size
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "2024_01_15-chaos-im-radio.mp3") {
+			w.Write(files["2024_01_15-chaos-im-radio.mp3"][:1000]) //nolint:errcheck
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer mirror.Close()
+	reencoded := []byte(strings.Repeat("SECOND episode ", 1000))
+	if err := os.WriteFile(filepath.Join(soundDir, "2024_01_22-chaos-im-radio.mp3"), reencoded, 0o644); err != nil {
+		t.Fatalf("Failed to replace file: %v", err)
+	}
+
+	config = &Config{ContentFilePath: contentFile, SoundDir: soundDir, OutputPath: outputFile, Format: "json", Args: []string{mirror.URL}}
+	if err := runVerifyMediaCommand(logrus.New(), config); err == nil {
+		t.Errorf("Expected an error for the mismatching copies")
+	}
+	output, _ := os.ReadFile(outputFile)
+	var results []MediaCheckResult
+	if err := json.Unmarshal(output, &results); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	problems := map[string]string{}
+	for _, result := range results {
+		problems[result.File+" "+result.Location] = result.Problem
+	}
+	if len(results) != 3 ||
+		problems["2024_01_15-chaos-im-radio.mp3 "+mirror.URL] != "size is 1000 bytes, expected 14000" ||
+		problems["2024_01_22-chaos-im-radio.mp3 "+mirror.URL] != "missing" ||
+		!strings.HasPrefix(problems["2024_01_22-chaos-im-radio.mp3 "+soundDir], "SHA-256 is ") {
+		t.Errorf("Unexpected results %+v", results)
+	}
+}
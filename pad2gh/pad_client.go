@@ -6,9 +6,13 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 func getPadContent(padURL string) (io.ReadCloser, error) {
@@ -142,12 +146,14 @@ func createPadMapping(padURLs []string, existingEntries map[string]*CiREntry, co
 		// Generate expected sound file name
 		parts := strings.Split(date, "-")
 		if len(parts) == 3 {
-			year, month, day := parts[0], parts[1], parts[2]
-			mapping.SoundFileName = fmt.Sprintf("%s_%s_%s-chaos-im-radio.mp3", year, month, day)
+			mapping.SoundFileName = soundFileNameForDate(date)
 
 			// Check local sound file if directory is provided
 			if config.SoundDir != "" {
-				mapping.HasSoundFileLocal = checkSoundFileExistsLocally(config.SoundDir, mapping.SoundFileName)
+				if info, err := os.Stat(filepath.Join(config.SoundDir, mapping.SoundFileName)); err == nil {
+					mapping.HasSoundFileLocal = true
+					mapping.SoundFileSize = info.Size()
+				}
 			}
 
 			if config.FileOnline {
@@ -159,6 +165,9 @@ func createPadMapping(padURLs []string, existingEntries map[string]*CiREntry, co
 				if err == nil {
 					if resp.StatusCode == http.StatusOK {
 						mapping.HasSoundFileOnline = true
+						if !mapping.HasSoundFileLocal {
+							mapping.SoundFileSize = resp.ContentLength
+						}
 					}
 					resp.Body.Close() //nolint:errcheck
 				}
@@ -176,6 +185,14 @@ func createPadMapping(padURLs []string, existingEntries map[string]*CiREntry, co
 	return mappings, nil
 }
 
+// hasSoundFile reports whether the sound file was found where the config says to look for it
+func (m PadMapping) hasSoundFile(config *Config) bool {
+	if config.FileOnline {
+		return m.HasSoundFileOnline
+	}
+	return m.HasSoundFileLocal
+}
+
 func findFirstLink(line string) (string, string) {
 	// Check for markdown links first [title](url)
 	re := regexp.MustCompile(`\[([^\]]*)\]\(([^)]+)\)`)
@@ -195,10 +212,25 @@ func findFirstLink(line string) (string, string) {
 	return "", ""
 }
 
-func getMarkdownContentBySection(padURL string) (map[string][]string, error) {
+// parseFrontMatter decodes the front matter of the pad, pads without one give empty fields
+func parseFrontMatter(contentBySection map[string][]string) (*PadFrontMatter, error) {
+	frontMatter := &PadFrontMatter{}
+	lines, exists := contentBySection["front matter"]
+	if !exists {
+		return frontMatter, nil
+	}
+	err := yaml.Unmarshal([]byte(strings.Join(lines, "\n")), frontMatter)
+	if err != nil {
+		return &PadFrontMatter{}, fmt.Errorf("invalid front matter: %v", err)
+	}
+	return frontMatter, nil
+}
+
+// getMarkdownContentBySection reads the pad into its sections, the warnings name problems with the structure of the pad
+func getMarkdownContentBySection(padURL string) (map[string][]string, []string, error) {
 	padContent, err := getPadContent(padURL)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer padContent.Close() //nolint:errcheck
 
@@ -208,9 +240,7 @@ func getMarkdownContentBySection(padURL string) (map[string][]string, error) {
 	currentSectionContent := []string{}
 	contentBySection := make(map[string][]string)
 	previousLineEmpty := false
-	for scanner.Scan() {
-		line := scanner.Text()
-
+	addLine := func(line string) {
 		if strings.HasPrefix(line, "###### tags:") {
 			// example line: ###### tags: `cccp` `radio` `nerdtalk` `shownotes_complete`
 			// split by comma and store as tags: []string in contentBySection
@@ -224,7 +254,7 @@ func getMarkdownContentBySection(padURL string) (map[string][]string, error) {
 				}
 			}
 			contentBySection["tags"] = cleanedTags
-			continue
+			return
 		}
 		if strings.HasPrefix(line, "## ") {
 			contentBySection[currentSection] = currentSectionContent
@@ -232,12 +262,12 @@ func getMarkdownContentBySection(padURL string) (map[string][]string, error) {
 			currentSection = strings.TrimPrefix(line, "##")
 			currentSection = strings.ToLower(currentSection)
 			currentSection = strings.Trim(currentSection, " ")
-			continue
+			return
 		}
 		line = strings.TrimSpace(line)
 		if line == "" {
 			if previousLineEmpty {
-				continue
+				return
 			}
 			previousLineEmpty = true
 		} else {
@@ -245,9 +275,41 @@ func getMarkdownContentBySection(padURL string) (map[string][]string, error) {
 		}
 		currentSectionContent = append(currentSectionContent, line)
 	}
-	contentBySection[currentSection] = currentSectionContent
+
+	// HedgeDoc reads YAML front matter between two --- lines at the very top of the pad, the lines are kept
+	// as they are since the indentation matters
+	frontMatter := []string{}
+	inFrontMatter := false
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if lineNumber == 1 && strings.TrimSpace(line) == "---" {
+			inFrontMatter = true
+			continue
+		}
+		if inFrontMatter {
+			if strings.TrimSpace(line) == "---" {
+				inFrontMatter = false
+				contentBySection["front matter"] = frontMatter
+			} else {
+				frontMatter = append(frontMatter, line)
+			}
+			continue
+		}
+		addLine(line)
+	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return contentBySection, nil
+
+	var warnings []string
+	if inFrontMatter {
+		// without the closing --- the first line was a horizontal rule, not the start of front matter
+		warnings = append(warnings, "the front matter in line 1 is never closed with ---, reading it as pad content")
+		addLine("---")
+		for _, line := range frontMatter {
+			addLine(line)
+		}
+	}
+	contentBySection[currentSection] = currentSectionContent
+	return contentBySection, warnings, nil
 }
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// personRoles maps the words used in the pads to the roles of the Podcast Taxonomy used by podcast:person
+var personRoles = map[string]string{
+	"moderation":     "host",
+	"moderator":      "host",
+	"moderatorin":    "host",
+	"host":           "host",
+	"co-moderation":  "co-host",
+	"co-host":        "co-host",
+	"gast":           "guest",
+	"gäste":          "guest",
+	"guest":          "guest",
+	"technik":        "audio engineer",
+	"tontechnik":     "audio engineer",
+	"audio engineer": "audio engineer",
+	"schnitt":        "audio editor",
+	"audio editor":   "audio editor",
+	"produktion":     "producer",
+	"producer":       "producer",
+}
+
+// personGroups are the taxonomy groups of the roles, podcast:person defaults to cast
+var personGroups = map[string]string{
+	"audio engineer": "audio post-production",
+	"audio editor":   "audio post-production",
+	"producer":       "creative direction",
+}
+
+// PeopleRegistryEntry is a person of the people registry, keyed by nickname
+type PeopleRegistryEntry struct {
+	Name    string   `yaml:"name"`
+	Href    string   `yaml:"href"`
+	Img     string   `yaml:"img"`
+	Aliases []string `yaml:"aliases"`
+}
+
+// PeopleRegistry resolves the nicknames used in the pads to the people behind them
+type PeopleRegistry map[string]PeopleRegistryEntry
+
+// loadPeopleRegistry reads the registry, it only lists the people who want to be named and is optional
+func loadPeopleRegistry(filePath string) (PeopleRegistry, error) {
+	registry := PeopleRegistry{}
+	content, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		logrus.Debugf("No people registry at %s", filePath)
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	err = yaml.Unmarshal(content, &registry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid people registry %s: %v", filePath, err)
+	}
+	return registry, nil
+}
+
+// lookup finds a person by nickname, name or alias, ignoring case
+func (registry PeopleRegistry) lookup(name string) (PeopleRegistryEntry, bool) {
+	key := strings.ToLower(name)
+	if person, exists := registry[key]; exists {
+		return person, true
+	}
+	for nick, person := range registry {
+		if strings.ToLower(nick) == key || strings.ToLower(person.Name) == key {
+			return person, true
+		}
+		for _, alias := range person.Aliases {
+			if strings.ToLower(alias) == key {
+				return person, true
+			}
+		}
+	}
+	return PeopleRegistryEntry{}, false
+}
+
+// personRole returns the taxonomy role of a word of the pad, "" if it isn't one
+func personRole(word string) string {
+	return personRoles[strings.ToLower(strings.TrimSpace(word))]
+}
+
+// splitPersonNames splits "Erika, Max und @nick" into the names
+func splitPersonNames(text string) []string {
+	var names []string
+	text = strings.NewReplacer(" und ", ",", " & ", ",", " and ", ",").Replace(text)
+	for _, name := range strings.Split(text, ",") {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parsePersonLine reads a line of the Mitwirkende or Moderation section. The role is given as "Technik: Erika, Max",
+// as "Erika (Technik)" or taken from the section, names may be linked as [Erika](https://chaos.social/@erika).
+func parsePersonLine(line string, sectionRole string) ([]CiRPerson, []string) {
+	var warnings []string
+	line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "*-+"))
+	if line == "" {
+		return nil, nil
+	}
+
+	role := sectionRole
+	if prefix, rest, found := strings.Cut(line, ":"); found && !strings.Contains(prefix, "](") && !strings.HasPrefix(rest, "//") {
+		if r := personRole(prefix); r != "" {
+			role = r
+		} else {
+			warnings = append(warnings, fmt.Sprintf("unknown role %q, using %s", strings.TrimSpace(prefix), sectionRole))
+		}
+		line = rest
+	}
+
+	var persons []CiRPerson
+	for _, name := range splitPersonNames(line) {
+		person := CiRPerson{Role: role}
+		if open := strings.LastIndex(name, "("); open > 0 && strings.HasSuffix(name, ")") {
+			if r := personRole(name[open+1 : len(name)-1]); r != "" {
+				person.Role = r
+				name = strings.TrimSpace(name[:open])
+			}
+		}
+		if title, link := findFirstLink(name); link != "" && title != "" {
+			person.Name, person.Href = title, link
+		} else {
+			person.Name = strings.TrimPrefix(name, "@")
+		}
+		persons = append(persons, person)
+	}
+	return persons, warnings
+}
+
+// padPersons reads the persons of the Moderation and Mitwirkende sections, or of the front matter without them
+func padPersons(contentBySection map[string][]string, frontMatter *PadFrontMatter) ([]CiRPerson, []string) {
+	var persons []CiRPerson
+	var warnings []string
+	sections := []struct{ name, role string }{{"moderation", "host"}, {"mitwirkende", "guest"}}
+	for _, section := range sections {
+		for _, line := range contentBySection[section.name] {
+			linePersons, lineWarnings := parsePersonLine(line, section.role)
+			persons = append(persons, linePersons...)
+			warnings = append(warnings, lineWarnings...)
+		}
+	}
+	if len(persons) > 0 {
+		return persons, warnings
+	}
+
+	for _, person := range frontMatter.Persons {
+		role := "host"
+		if person.Role != "" {
+			role = personRole(person.Role)
+		}
+		if role == "" {
+			warnings = append(warnings, fmt.Sprintf("unknown role %q of %s, using guest", person.Role, person.Name))
+			role = "guest"
+		}
+		person.Name = strings.TrimPrefix(strings.TrimSpace(person.Name), "@")
+		person.Role = role
+		if person.Name != "" {
+			persons = append(persons, person)
+		}
+	}
+	return persons, warnings
+}
+
+// resolvePersons replaces nicknames with the names of the people registry, fills in link and avatar, sets the
+// taxonomy group and drops duplicates. Names missing from the registry are returned.
+func resolvePersons(persons []CiRPerson, registry PeopleRegistry) ([]CiRPerson, []string) {
+	var resolved []CiRPerson
+	var unknown []string
+	seen := map[string]bool{}
+	for _, person := range persons {
+		if entry, exists := registry.lookup(person.Name); exists {
+			person.Name = entry.Name
+			if person.Href == "" {
+				person.Href = entry.Href
+			}
+			if person.Img == "" {
+				person.Img = entry.Img
+			}
+		} else {
+			unknown = append(unknown, person.Name)
+		}
+		person.Group = personGroups[person.Role]
+		key := strings.ToLower(person.Name) + "\x00" + person.Role
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		resolved = append(resolved, person)
+	}
+	return resolved, unknown
+}
+
+// resolveEntryPersons resolves the persons of the pad with the registry in -people
+func resolveEntryPersons(entry *CiREntry, config *Config) {
+	if len(entry.Persons) == 0 {
+		return
+	}
+	registry := PeopleRegistry{}
+	if config.PeopleFilePath != "" {
+		loaded, err := loadPeopleRegistry(config.PeopleFilePath)
+		if err != nil {
+			entry.processingWarnings = append(entry.processingWarnings, err.Error())
+		} else {
+			registry = loaded
+		}
+	}
+	persons, unknown := resolvePersons(entry.Persons, registry)
+	entry.Persons = persons
+	if len(registry) == 0 {
+		return
+	}
+	for _, name := range unknown {
+		entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("%s is not in the people registry", name))
+	}
+}
+
+// PersonStats counts the episodes someone took part in
+type PersonStats struct {
+	Name     string         `json:"name"`
+	Episodes int            `json:"episodes"`
+	Roles    map[string]int `json:"roles"`
+	First    string         `json:"first"`
+	Last     string         `json:"last"`
+}
+
+// personStats counts the episodes of every person, the one in most episodes first
+func personStats(entries []*CiREntry) []PersonStats {
+	byName := map[string]*PersonStats{}
+	for _, entry := range entries {
+		date := ""
+		if len(entry.PublicationDate) >= 10 {
+			date = entry.PublicationDate[:10]
+		}
+		counted := map[string]bool{}
+		for _, person := range entry.Persons {
+			stats, exists := byName[person.Name]
+			if !exists {
+				stats = &PersonStats{Name: person.Name, Roles: map[string]int{}, First: date, Last: date}
+				byName[person.Name] = stats
+			}
+			stats.Roles[person.Role]++
+			if counted[person.Name] {
+				continue
+			}
+			counted[person.Name] = true
+			stats.Episodes++
+			if date != "" && (stats.First == "" || date < stats.First) {
+				stats.First = date
+			}
+			if date > stats.Last {
+				stats.Last = date
+			}
+		}
+	}
+
+	stats := []PersonStats{}
+	for _, s := range byName {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Episodes != stats[j].Episodes {
+			return stats[i].Episodes > stats[j].Episodes
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// formatRoles lists the roles of a person like "host (12), guest (1)"
+func formatRoles(roles map[string]int) string {
+	names := make([]string, 0, len(roles))
+	for role := range roles {
+		names = append(names, role)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if roles[names[i]] != roles[names[j]] {
+			return roles[names[i]] > roles[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for i, role := range names {
+		names[i] = fmt.Sprintf("%s (%d)", role, roles[role])
+	}
+	return strings.Join(names, ", ")
+}
+
+func writePersonStatsMarkdown(w io.Writer, stats []PersonStats) error {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("## Persons (%d)\n\n", len(stats)))
+	if len(stats) > 0 {
+		b.WriteString("| Name | Episodes | Roles | First | Last |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+	}
+	for _, s := range stats {
+		b.WriteString(fmt.Sprintf("| %s | %d | %s | %s | %s |\n", s.Name, s.Episodes, formatRoles(s.Roles), s.First, s.Last))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// runPersonsCommand reports how many episodes everyone took part in and in which roles
+func runPersonsCommand(logger *logrus.Logger, config *Config) error {
+	err := checkFormat("persons", config.Format, "md", "json")
+	if err != nil {
+		return err
+	}
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+	stats := personStats(entries)
+	logger.Infof("Found %d persons in %d entries", len(stats), len(entries))
+
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if config.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+	return writePersonStatsMarkdown(out, stats)
+}
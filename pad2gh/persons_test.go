@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPadPersons(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentBySection map[string][]string
+		frontMatter      PadFrontMatter
+		expected         []CiRPerson
+		warnings         int
+	}{
+		{
+			name:             "moderation section",
+			contentBySection: map[string][]string{"moderation": {"- @erika und Max", ""}},
+			expected:         []CiRPerson{{Name: "erika", Role: "host"}, {Name: "Max", Role: "host"}},
+		},
+		{
+			name: "mitwirkende with roles",
+			contentBySection: map[string][]string{"mitwirkende": {
+				"* [Jo](https://chaos.social/@jo) (Technik)",
+				"Schnitt: Kim, Alex",
+				"Sam",
+				"Kaffee: Robin",
+			}},
+			expected: []CiRPerson{
+				{Name: "Jo", Role: "audio engineer", Href: "https://chaos.social/@jo"},
+				{Name: "Kim", Role: "audio editor"},
+				{Name: "Alex", Role: "audio editor"},
+				{Name: "Sam", Role: "guest"},
+				{Name: "Robin", Role: "guest"},
+			},
+			warnings: 1,
+		},
+		{
+			name:        "front matter",
+			frontMatter: PadFrontMatter{Persons: []CiRPerson{{Name: "erika"}, {Name: "Sam", Role: "Gast", Img: "https://example.org/sam.png"}}},
+			expected:    []CiRPerson{{Name: "erika", Role: "host"}, {Name: "Sam", Role: "guest", Img: "https://example.org/sam.png"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persons, warnings := padPersons(tt.contentBySection, &tt.frontMatter)
+			if !reflect.DeepEqual(persons, tt.expected) {
+				t.Errorf("padPersons() = %+v, expected %+v", persons, tt.expected)
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("expected %d warnings, got %v", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestResolvePersons(t *testing.T) {
+	registry := PeopleRegistry{
+		"erika": {Name: "Erika Mustermann", Href: "https://chaos.social/@erika", Img: "https://example.org/erika.png"},
+		"jo":    {Name: "Jo Beispiel", Aliases: []string{"Johanna"}},
+	}
+	persons := []CiRPerson{
+		{Name: "Erika", Role: "host"},
+		{Name: "johanna", Role: "audio engineer", Href: "https://example.org/jo"},
+		{Name: "Erika Mustermann", Role: "host"},
+		{Name: "Sam", Role: "guest"},
+	}
+
+	resolved, unknown := resolvePersons(persons, registry)
+	expected := []CiRPerson{
+		{Name: "Erika Mustermann", Role: "host", Href: "https://chaos.social/@erika", Img: "https://example.org/erika.png"},
+		{Name: "Jo Beispiel", Role: "audio engineer", Group: "audio post-production", Href: "https://example.org/jo"},
+		{Name: "Sam", Role: "guest"},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("resolvePersons() = %+v, expected %+v", resolved, expected)
+	}
+	if !reflect.DeepEqual(unknown, []string{"Sam"}) {
+		t.Errorf("expected Sam to be unknown, got %v", unknown)
+	}
+}
+
+func TestPersonStats(t *testing.T) {
+	entries := []*CiREntry{
+		{UUID: "nt-2024-01-08", PublicationDate: "2024-01-08T00:00:00+00:00", Persons: []CiRPerson{{Name: "Erika", Role: "host"}, {Name: "Sam", Role: "guest"}}},
+		{UUID: "nt-2024-01-15", PublicationDate: "2024-01-15T00:00:00+00:00", Persons: []CiRPerson{{Name: "Erika", Role: "host"}, {Name: "Erika", Role: "audio engineer"}}},
+		{UUID: "nt-2024-01-22", PublicationDate: "2024-01-22T00:00:00+00:00"},
+	}
+
+	stats := personStats(entries)
+	if len(stats) != 2 || stats[0].Name != "Erika" || stats[0].Episodes != 2 || stats[0].First != "2024-01-08" || stats[0].Last != "2024-01-15" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	var b bytes.Buffer
+	if err := writePersonStatsMarkdown(&b, stats); err != nil {
+		t.Fatalf("writePersonStatsMarkdown() failed: %v", err)
+	}
+	if !strings.Contains(b.String(), "| Erika | 2 | host (2), audio engineer (1) | 2024-01-08 | 2024-01-15 |") {
+		t.Errorf("unexpected report:\n%s", b.String())
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // covers may also be PNG
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// Open Graph cards are shown at 1.91:1
+	previewWidth  = 1200
+	previewHeight = 630
+	// the square variant matches cover_250.jpg
+	squarePreviewSize = 250
+	previewPadding    = 48
+	previewChapters   = 4
+	previewQuality    = 90
+)
+
+// the colors of podlove_player_theme in config.py
+var (
+	previewBackground = color.RGBA{0x15, 0x15, 0x15, 0xff}
+	previewAccent     = color.RGBA{0x90, 0xdb, 0x4b, 0xff}
+	previewText       = color.RGBA{0xf0, 0xf0, 0xf0, 0xff}
+	previewMuted      = color.RGBA{0xa0, 0xa0, 0xa0, 0xff}
+)
+
+// previewFaces are the embedded Go fonts in the sizes of the card
+type previewFaces struct {
+	podcast, title, date, chapter, squareDate font.Face
+}
+
+func loadPreviewFaces() (*previewFaces, error) {
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	face := func(f *opentype.Font, size float64) font.Face {
+		// NewFace only fails for invalid options
+		face, _ := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		return face
+	}
+	return &previewFaces{
+		podcast:    face(bold, 30),
+		title:      face(bold, 56),
+		date:       face(regular, 34),
+		chapter:    face(regular, 28),
+		squareDate: face(bold, 26),
+	}, nil
+}
+
+// wrapText breaks text into lines no wider than width, words longer than a line are cut with an ellipsis
+func wrapText(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = fitText(face, word, width)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fitText shortens text with an ellipsis until it is no wider than width
+func fitText(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Ceil() <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		shortened := strings.TrimSpace(string(runes)) + "…"
+		if font.MeasureString(face, shortened).Ceil() <= width {
+			return shortened
+		}
+	}
+	return ""
+}
+
+// drawText draws text with its baseline at y
+func drawText(dst draw.Image, face font.Face, c color.Color, x, y int, text string) {
+	drawer := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	drawer.DrawString(text)
+}
+
+// squareCover crops the center square of the cover and scales it to size
+func squareCover(cover image.Image, size int) *image.RGBA {
+	bounds := cover.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+	square := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(square, square.Bounds(), cover, crop, draw.Src, nil)
+	return square
+}
+
+var germanMonths = []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September",
+	"Oktober", "November", "Dezember"}
+
+// previewDate formats the publication date the German way, long unless short is set
+func previewDate(entry *CiREntry, short bool) string {
+	date, err := parseEntryDate(entry)
+	if err != nil {
+		return ""
+	}
+	if short {
+		return date.Format("02.01.2006")
+	}
+	return fmt.Sprintf("%d. %s %d", date.Day(), germanMonths[date.Month()-1], date.Year())
+}
+
+// previewChapterTitles returns the first chapter titles worth showing, the tracks are left out
+func previewChapterTitles(entry *CiREntry) []string {
+	var titles []string
+	for _, chapter := range entry.Chapters {
+		if strings.HasPrefix(chapter.Title, "Musik") {
+			continue
+		}
+		titles = append(titles, chapter.Title)
+		if len(titles) == previewChapters {
+			break
+		}
+	}
+	return titles
+}
+
+// renderPreview draws the Open Graph card: the cover on the left, podcast, episode title, date and the first
+// chapters on the right
+func renderPreview(entry *CiREntry, cover image.Image, podcastTitle string, faces *previewFaces) *image.RGBA {
+	card := image.NewRGBA(image.Rect(0, 0, previewWidth, previewHeight))
+	draw.Draw(card, card.Bounds(), image.NewUniform(previewBackground), image.Point{}, draw.Src)
+	draw.Draw(card, image.Rect(0, 0, previewHeight, previewHeight), squareCover(cover, previewHeight), image.Point{}, draw.Src)
+	// a stripe in the accent color separates the cover from the text
+	draw.Draw(card, image.Rect(previewHeight, 0, previewHeight+8, previewHeight), image.NewUniform(previewAccent), image.Point{}, draw.Src)
+
+	x := previewHeight + 8 + previewPadding
+	width := previewWidth - x - previewPadding
+	y := previewPadding + faces.podcast.Metrics().Ascent.Ceil()
+	drawText(card, faces.podcast, previewAccent, x, y, fitText(faces.podcast, podcastTitle, width))
+
+	titleHeight := faces.title.Metrics().Height.Ceil()
+	y += titleHeight + 8
+	titleLines := wrapText(faces.title, entry.Title, width)
+	if len(titleLines) > 3 {
+		titleLines = append(titleLines[:2], fitText(faces.title, strings.Join(titleLines[2:], " "), width))
+	}
+	for _, line := range titleLines {
+		drawText(card, faces.title, previewText, x, y, line)
+		y += titleHeight
+	}
+
+	drawText(card, faces.date, previewMuted, x, y, previewDate(entry, false))
+	y += faces.date.Metrics().Height.Ceil() + 24
+
+	chapterHeight := faces.chapter.Metrics().Height.Ceil() + 8
+	for _, title := range previewChapterTitles(entry) {
+		if y > previewHeight-previewPadding {
+			break
+		}
+		drawText(card, faces.chapter, previewText, x, y, fitText(faces.chapter, "• "+title, width))
+		y += chapterHeight
+	}
+	return card
+}
+
+// renderSquarePreview draws the small square variant: the cover with the date on a bar in the accent color
+func renderSquarePreview(entry *CiREntry, cover image.Image, faces *previewFaces) *image.RGBA {
+	square := squareCover(cover, squarePreviewSize)
+	barHeight := faces.squareDate.Metrics().Height.Ceil() + 12
+	draw.Draw(square, image.Rect(0, squarePreviewSize-barHeight, squarePreviewSize, squarePreviewSize), image.NewUniform(previewAccent), image.Point{}, draw.Src)
+
+	date := previewDate(entry, true)
+	x := (squarePreviewSize - font.MeasureString(faces.squareDate, date).Ceil()) / 2
+	y := squarePreviewSize - 6 - faces.squareDate.Metrics().Descent.Ceil()
+	drawText(square, faces.squareDate, previewBackground, x, y, date)
+	return square
+}
+
+// readCoverImage decodes the JPEG or PNG cover
+func readCoverImage(filePath string) (image.Image, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cover, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", filePath, err)
+	}
+	return cover, nil
+}
+
+// writeJPEG writes the image with the quality used for the covers
+func writeJPEG(filePath string, img image.Image) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	err = jpeg.Encode(file, img, &jpeg.Options{Quality: previewQuality})
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// squarePreviewFileName is the file name of the square variant next to the card
+func squarePreviewFileName(previewFileName string) string {
+	return strings.TrimSuffix(previewFileName, path.Ext(previewFileName)) + "_250.jpg"
+}
+
+// previewLayout is part of the input hash, bump it when the rendering changes to render all previews again
+const previewLayout = 1
+
+// previewInputHash covers everything the preview of an entry shows. It is part of the file name, so a changed title,
+// date, chapter or cover gets a new card and social networks don't keep showing the one they cached.
+func previewInputHash(entry *CiREntry, coverDigest string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%s", previewLayout, id3Album, entry.Title, entry.PublicationDate, coverDigest)
+	for _, chapter := range entry.Chapters {
+		fmt.Fprintf(h, "\x00%s", chapter.Title)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// removeOldPreview deletes the card the entry referenced before and its square variant
+func removeOldPreview(logger *logrus.Logger, dir, reference string) {
+	if reference == "" {
+		return
+	}
+	fileName := path.Base(reference)
+	for _, name := range []string{fileName, squarePreviewFileName(fileName)} {
+		err := os.Remove(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			logger.Warnf("Failed to remove the old preview %s: %v", name, err)
+		}
+	}
+}
+
+// runPreviewCommand renders the social preview card and its square variant of every episode (or the one given with
+// -entry) into -preview-dir from the episode cover or -cover and references the card from content.yaml. Previews
+// whose inputs didn't change are kept, the ones they replace are removed.
+func runPreviewCommand(logger *logrus.Logger, config *Config) error {
+	faces, err := loadPreviewFaces()
+	if err != nil {
+		return fmt.Errorf("failed to load the fonts: %v", err)
+	}
+	err = os.MkdirAll(config.PreviewDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", config.PreviewDir, err)
+	}
+
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	var orderedEntries []EntryWithOrder
+	written := 0
+	// most episodes share the podcast cover
+	covers := map[string]image.Image{}
+	coverDigests := map[string]string{}
+	for _, entry := range entries {
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: entry})
+		if config.EntryUUID != "" && entry.UUID != config.EntryUUID {
+			continue
+		}
+
+		coverPath := entryCoverPath(entry, config)
+		coverDigest, exists := coverDigests[coverPath]
+		if !exists {
+			coverDigest, _, err = fileDigest(coverPath)
+			if err != nil {
+				return fmt.Errorf("failed to read the cover of %s: %v", entry.UUID, err)
+			}
+			coverDigests[coverPath] = coverDigest
+		}
+
+		fileName := entry.UUID + "-" + previewInputHash(entry, coverDigest) + ".jpg"
+		filePath := filepath.Join(config.PreviewDir, fileName)
+		reference := siteRelativePath(config.PreviewDir, fileName)
+		if _, err := os.Stat(filePath); err == nil && config.EntryUUID == "" {
+			if entry.Preview != reference {
+				entry.Preview = reference
+				written++
+			}
+			continue
+		}
+
+		cover, exists := covers[coverPath]
+		if !exists {
+			cover, err = readCoverImage(coverPath)
+			if err != nil {
+				return fmt.Errorf("failed to read the cover of %s: %v", entry.UUID, err)
+			}
+			covers[coverPath] = cover
+		}
+		err = writeJPEG(filePath, renderPreview(entry, cover, id3Album, faces))
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %v", filePath, err)
+		}
+		squarePath := filepath.Join(config.PreviewDir, squarePreviewFileName(fileName))
+		err = writeJPEG(squarePath, renderSquarePreview(entry, cover, faces))
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %v", squarePath, err)
+		}
+		if entry.Preview != reference {
+			removeOldPreview(logger, config.PreviewDir, entry.Preview)
+		}
+		entry.Preview = reference
+		written++
+		logger.Infof("Rendered %s", filePath)
+	}
+
+	logger.Infof("Updated the preview of %d entries", written)
+	if written == 0 {
+		return nil
+	}
+	return writeAllYAMLEntries(orderedEntries, config.ContentFilePath)
+}
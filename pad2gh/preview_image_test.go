@@ -0,0 +1,117 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/image/font"
+)
+
+func TestWrapText(t *testing.T) {
+	faces, err := loadPreviewFaces()
+	if err != nil {
+		t.Fatalf("loadPreviewFaces() failed: %v", err)
+	}
+
+	lines := wrapText(faces.title, "Was tun, bevor bzw. nachdem das eigene Smartphone geklaut wird?", 400)
+	if len(lines) < 2 {
+		t.Fatalf("expected several lines, got %q", lines)
+	}
+	for _, line := range lines {
+		if width := font.MeasureString(faces.title, line).Ceil(); width > 400 {
+			t.Errorf("line %q is %d pixels wide", line, width)
+		}
+	}
+
+	if text := fitText(faces.chapter, "Donaudampfschifffahrtsgesellschaftskapitänsmütze", 200); text == "" || []rune(text)[len([]rune(text))-1] != '…' {
+		t.Errorf("expected the word to be shortened with an ellipsis, got %q", text)
+	}
+}
+
+func TestPreviewCommand(t *testing.T) {
+	dir := t.TempDir()
+	coverFile := filepath.Join(dir, "cover.jpg")
+	previewDir := filepath.Join(dir, "previews")
+	contentFile := filepath.Join(dir, "content.yaml")
+
+	// a landscape cover, the center square is used
+	cover := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			cover.Set(x, y, color.RGBA{uint8(x / 2), uint8(y), 0x80, 0xff})
+		}
+	}
+	if err := writeJPEG(coverFile, cover); err != nil {
+		t.Fatalf("Failed to write cover: %v", err)
+	}
+	entry := testChapterEntry()
+	entry.PublicationDate = "2024-01-15T00:00:00+00:00"
+	if err := appendEntryToYAML(entry, contentFile); err != nil {
+		t.Fatalf("Failed to write content file: %v", err)
+	}
+
+	config := &Config{ContentFilePath: contentFile, CoverPath: coverFile, PreviewDir: previewDir}
+	if err := runPreviewCommand(logrus.New(), config); err != nil {
+		t.Fatalf("runPreviewCommand() failed: %v", err)
+	}
+
+	entries, _ := readYAMLEntries(contentFile)
+	reference := entries[0].Preview
+	if !strings.HasPrefix(reference, "previews/nt-2024-01-15-") || !strings.HasSuffix(reference, ".jpg") {
+		t.Fatalf("Expected the preview to be referenced, got %q", reference)
+	}
+	fileName := path.Base(reference)
+	sizes := map[string]image.Point{
+		fileName:                        {previewWidth, previewHeight},
+		squarePreviewFileName(fileName): {squarePreviewSize, squarePreviewSize},
+	}
+	for name, size := range sizes {
+		file, err := os.Open(filepath.Join(previewDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s: %v", name, err)
+		}
+		img, err := jpeg.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", name, err)
+		}
+		if img.Bounds().Size() != size {
+			t.Errorf("%s is %v, expected %v", name, img.Bounds().Size(), size)
+		}
+	}
+
+	// unchanged inputs keep the preview, a new title renders a new one and removes the old
+	if err := runPreviewCommand(logrus.New(), config); err != nil {
+		t.Fatalf("runPreviewCommand() failed: %v", err)
+	}
+	entries, _ = readYAMLEntries(contentFile)
+	if entries[0].Preview != reference {
+		t.Errorf("Expected the preview to be kept, got %q", entries[0].Preview)
+	}
+	entries[0].Title = "Was tun, wenn das Smartphone geklaut wird?"
+	if err := writeAllYAMLEntries([]EntryWithOrder{{Entry: entries[0]}}, contentFile); err != nil {
+		t.Fatalf("Failed to write content file: %v", err)
+	}
+	if err := runPreviewCommand(logrus.New(), config); err != nil {
+		t.Fatalf("runPreviewCommand() failed: %v", err)
+	}
+	entries, _ = readYAMLEntries(contentFile)
+	if entries[0].Preview == reference {
+		t.Errorf("Expected a new preview for the new title, got %q", entries[0].Preview)
+	}
+	if _, err := os.Stat(filepath.Join(previewDir, path.Base(entries[0].Preview))); err != nil {
+		t.Errorf("Expected the new preview: %v", err)
+	}
+	for name := range sizes {
+		if _, err := os.Stat(filepath.Join(previewDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected the old preview %s to be removed", name)
+		}
+	}
+}
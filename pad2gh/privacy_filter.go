@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// publishedSections end up in the summary and long_summary_md of the entry
+var publishedSections = []string{"summary", "shownotes", "long summary", "mukke"}
+
+var (
+	defaultPadHosts        = []string{"pad.ccc-p.org"}
+	defaultInternalMarkers = []string{"> intern:"}
+	defaultAllowedEmails   = []string{"radio@ccc-p.org"}
+
+	urlRegex         = regexp.MustCompile(`https?://[^\s)\]>]+`)
+	linkWithURLRegex = regexp.MustCompile(`\[([^\]]*)\]\((https?://[^)\s]+)\)`)
+)
+
+// PrivacyRules configure what is taken out of the pad before it is published
+type PrivacyRules struct {
+	PadHosts        []string `yaml:"pad_hosts"`        // hosts of the pads, links to them are private unless allowed
+	PublicPads      []string `yaml:"public_pads"`      // URL prefixes of pads which may be linked
+	InternalMarkers []string `yaml:"internal_markers"` // lines starting with one of these are dropped, like a quote
+	Redact          []string `yaml:"redact"`           // regular expressions of anything else to remove
+	AllowedEmails   []string `yaml:"allowed_emails"`   // addresses which may be published, like the contact of the podcast
+	redactRegexes   []*regexp.Regexp
+}
+
+// loadPrivacyRules reads the rules, the file is optional and extends the defaults
+func loadPrivacyRules(filePath string) (*PrivacyRules, error) {
+	rules := &PrivacyRules{}
+	content, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	if err == nil {
+		err = yaml.Unmarshal(content, rules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid privacy rules %s: %v", filePath, err)
+		}
+	} else {
+		logrus.Debugf("No privacy rules at %s, using the defaults", filePath)
+	}
+
+	rules.PadHosts = append(rules.PadHosts, defaultPadHosts...)
+	rules.InternalMarkers = append(rules.InternalMarkers, defaultInternalMarkers...)
+	rules.AllowedEmails = append(rules.AllowedEmails, defaultAllowedEmails...)
+	for _, pattern := range rules.Redact {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q in %s: %v", pattern, filePath, err)
+		}
+		rules.redactRegexes = append(rules.redactRegexes, re)
+	}
+	return rules, nil
+}
+
+// privatePad tells if the URL is a pad not on the list of public ones
+func (rules *PrivacyRules) privatePad(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	private := false
+	for _, host := range rules.PadHosts {
+		private = private || strings.EqualFold(u.Hostname(), host)
+	}
+	for _, prefix := range rules.PublicPads {
+		if strings.HasPrefix(link, prefix) {
+			return false
+		}
+	}
+	return private
+}
+
+// internal tells if the line starts with one of the internal markers, ignoring case and spaces like in ">intern:"
+func (rules *PrivacyRules) internal(line string) bool {
+	normalize := func(text string) string { return strings.ToLower(strings.ReplaceAll(text, " ", "")) }
+	line = normalize(line)
+	for _, marker := range rules.InternalMarkers {
+		if marker = normalize(marker); marker != "" && strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedEmail tells if the address may be published, ignoring case
+func (rules *PrivacyRules) allowedEmail(address string) bool {
+	for _, allowed := range rules.AllowedEmails {
+		if strings.EqualFold(address, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripHTMLComments removes the comments of the line, comments may go on over several lines
+func stripHTMLComments(line string, inComment bool) (string, bool, bool) {
+	var kept strings.Builder
+	removed := inComment
+	for line != "" {
+		if inComment {
+			end := strings.Index(line, "-->")
+			if end < 0 {
+				return kept.String(), removed, true
+			}
+			line = line[end+3:]
+			inComment = false
+			continue
+		}
+		start := strings.Index(line, "<!--")
+		if start < 0 {
+			kept.WriteString(line)
+			break
+		}
+		kept.WriteString(line[:start])
+		line = line[start+4:]
+		inComment = true
+		removed = true
+	}
+	return kept.String(), removed, inComment
+}
+
+// replaceOutsideURLs applies replace to the text between the URLs of the line, so digits and @ in links are kept
+func replaceOutsideURLs(line string, replace func(string) string) string {
+	var b strings.Builder
+	position := 0
+	for _, match := range urlRegex.FindAllStringIndex(line, -1) {
+		b.WriteString(replace(line[position:match[0]]))
+		b.WriteString(line[match[0]:match[1]])
+		position = match[1]
+	}
+	b.WriteString(replace(line[position:]))
+	return b.String()
+}
+
+// replaceEmailAddresses replaces the email addresses of the text, fediverse handles like @erika@chaos.social are public
+func replaceEmailAddresses(text string, replace func(address string) string) string {
+	var b strings.Builder
+	position := 0
+	for _, match := range emailRegex.FindAllStringIndex(text, -1) {
+		if match[0] > 0 && text[match[0]-1] == '@' {
+			continue
+		}
+		b.WriteString(text[position:match[0]])
+		b.WriteString(replace(text[match[0]:match[1]]))
+		position = match[1]
+	}
+	b.WriteString(text[position:])
+	return b.String()
+}
+
+// sanitizeLine removes links to private pads, email addresses which aren't allowed, phone numbers and the redact patterns from the line
+// and describes what was removed
+func (rules *PrivacyRules) sanitizeLine(line string) (string, []string) {
+	var removed []string
+	line = linkWithURLRegex.ReplaceAllStringFunc(line, func(match string) string {
+		parts := linkWithURLRegex.FindStringSubmatch(match)
+		if !rules.privatePad(parts[2]) {
+			return match
+		}
+		removed = append(removed, "a link to a non-public pad")
+		return parts[1]
+	})
+	line = urlRegex.ReplaceAllStringFunc(line, func(link string) string {
+		if !rules.privatePad(link) {
+			return link
+		}
+		removed = append(removed, "a link to a non-public pad")
+		return ""
+	})
+
+	line = replaceOutsideURLs(line, func(text string) string {
+		text = replaceEmailAddresses(text, func(address string) string {
+			if rules.allowedEmail(address) {
+				return address
+			}
+			removed = append(removed, "an email address")
+			return redactionMarker
+		})
+		text = phoneRegex.ReplaceAllStringFunc(text, func(match string) string {
+			if !isPhoneNumber(match) {
+				return match
+			}
+			removed = append(removed, "a phone number")
+			return redactionMarker
+		})
+		for _, re := range rules.redactRegexes {
+			text = re.ReplaceAllStringFunc(text, func(match string) string {
+				removed = append(removed, fmt.Sprintf("a match of %q", re.String()))
+				return redactionMarker
+			})
+		}
+		return text
+	})
+	return line, removed
+}
+
+// sanitize drops the internal lines and HTML comments of a section and sanitizes the rest. The diagnostics say
+// what was removed where, never the removed text itself, since they end up in the PR comments.
+func (rules *PrivacyRules) sanitize(section string, lines []string) ([]string, []string) {
+	var kept, diagnostics []string
+	inComment, inInternalQuote := false, false
+	for i, line := range lines {
+		where := fmt.Sprintf("%s line %d", section, i+1)
+
+		text, hadComment, stillInComment := stripHTMLComments(line, inComment)
+		inComment = stillInComment
+		if hadComment {
+			diagnostics = append(diagnostics, "privacy: removed an HTML comment in "+where)
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+		}
+		text = strings.TrimSpace(text)
+
+		// the quote of an internal marker goes on until the next line which isn't quoted
+		if inInternalQuote && strings.HasPrefix(text, ">") {
+			diagnostics = append(diagnostics, "privacy: dropped an internal line in "+where)
+			continue
+		}
+		inInternalQuote = false
+		if rules.internal(text) {
+			inInternalQuote = strings.HasPrefix(text, ">")
+			diagnostics = append(diagnostics, "privacy: dropped an internal line in "+where)
+			continue
+		}
+
+		text, removed := rules.sanitizeLine(text)
+		for _, what := range removed {
+			diagnostics = append(diagnostics, fmt.Sprintf("privacy: removed %s in %s", what, where))
+		}
+		if len(removed) > 0 && strings.TrimSpace(strings.Trim(text, "*-+ ")) == "" {
+			continue
+		}
+		kept = append(kept, text)
+	}
+	return kept, diagnostics
+}
+
+// sanitizePadSections runs the privacy filter with the rules in -privacy-rules on the sections which are published,
+// the entry isn't created if the rules can't be read
+func sanitizePadSections(contentBySection map[string][]string, config *Config) ([]string, error) {
+	rules, err := loadPrivacyRules(config.PrivacyRulesPath)
+	if err != nil {
+		return nil, err
+	}
+	var diagnostics []string
+	for _, section := range publishedSections {
+		lines, exists := contentBySection[section]
+		if !exists {
+			continue
+		}
+		kept, sectionDiagnostics := rules.sanitize(section, lines)
+		diagnostics = append(diagnostics, sectionDiagnostics...)
+		if strings.TrimSpace(strings.Join(kept, "")) == "" && len(sectionDiagnostics) > 0 {
+			// nothing public left, the entry is created as if the section was missing
+			delete(contentBySection, section)
+			continue
+		}
+		contentBySection[section] = kept
+	}
+	return diagnostics, nil
+}
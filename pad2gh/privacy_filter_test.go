@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitizePadSections(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "privacy-rules.yaml")
+	rules := "public_pads:\n  - https://pad.ccc-p.org/s/\nredact:\n  - '(?i)Raum \\d+'\n"
+	if err := os.WriteFile(rulesFile, []byte(rules), 0o644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+
+	contentBySection := map[string][]string{
+		"summary": {"> intern: noch nicht veröffentlichen", "> bis Freitag warten", "Smartphones und Zeichen"},
+		"shownotes": {
+			"* [Ladegeräte](https://example.org/zeichen/20240115123)",
+			"* Vorbereitung im [Orga-Pad](https://pad.ccc-p.org/orga-geheim) und https://pad.ccc-p.org/s/public-notes",
+			"* https://pad.ccc-p.org/xyz123",
+			"* Fragen an radio@example.org oder 0331 1234567, Treffen in Raum 42 am 15.01.2024",
+			"* Guesses an Radio@ccc-p.org",
+			"* Folgt @erika@chaos.social <!-- Telefon von",
+			"Erika: 0151 23456789 -->",
+			">Intern: Danke an Max",
+			"",
+		},
+		"kapitel": {"00:00 Intro <!-- not published here -->"},
+	}
+
+	diagnostics, err := sanitizePadSections(contentBySection, &Config{PrivacyRulesPath: rulesFile})
+	if err != nil {
+		t.Fatalf("sanitizePadSections() failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"summary": {"Smartphones und Zeichen"},
+		"shownotes": {
+			"* [Ladegeräte](https://example.org/zeichen/20240115123)",
+			"* Vorbereitung im Orga-Pad und https://pad.ccc-p.org/s/public-notes",
+			"* Fragen an […] oder […], Treffen in […] am 15.01.2024",
+			"* Guesses an Radio@ccc-p.org",
+			"* Folgt @erika@chaos.social",
+			"",
+		},
+		"kapitel": {"00:00 Intro <!-- not published here -->"},
+	}
+	if !reflect.DeepEqual(contentBySection, expected) {
+		t.Errorf("sanitized sections:\n%q\nexpected:\n%q", contentBySection, expected)
+	}
+
+	if len(diagnostics) != 10 {
+		t.Errorf("expected 10 diagnostics, got %d: %v", len(diagnostics), diagnostics)
+	}
+	for _, diagnostic := range diagnostics {
+		for _, private := range []string{"orga-geheim", "radio@", "1234567", "Max", "Raum 42"} {
+			if strings.Contains(diagnostic, private) {
+				t.Errorf("diagnostic %q reveals %q", diagnostic, private)
+			}
+		}
+	}
+}
+
+func TestSanitizeDropsEmptySections(t *testing.T) {
+	contentBySection := map[string][]string{"summary": {"<!-- TODO -->"}}
+	_, err := sanitizePadSections(contentBySection, &Config{PrivacyRulesPath: filepath.Join(t.TempDir(), "missing.yaml")})
+	if err != nil {
+		t.Fatalf("sanitizePadSections() failed: %v", err)
+	}
+	if _, exists := contentBySection["summary"]; exists {
+		t.Errorf("expected the summary to be dropped, got %q", contentBySection["summary"])
+	}
+}
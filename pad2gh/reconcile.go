@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OrphanYAMLEntry is an entry of content.yaml whose pad is not linked on the Radio page (anymore)
+type OrphanYAMLEntry struct {
+	Date string `json:"date"`
+	UUID string `json:"uuid"`
+}
+
+// MisnamedSoundFile is a sound file for the date of a pad that doesn't follow the canonical naming
+type MisnamedSoundFile struct {
+	Date     string `json:"date"`
+	PadURL   string `json:"padURL"`
+	FileName string `json:"fileName"`
+	Expected string `json:"expected"`
+}
+
+// Reconciliation compares pads, YAML entries and sound files in all three directions
+type Reconciliation struct {
+	OrphanSoundFiles     []string            `json:"orphanSoundFiles"`     // no pad for the date of the file
+	OrphanYAMLEntries    []OrphanYAMLEntry   `json:"orphanYAMLEntries"`    // no pad for the date of the entry
+	PadsWithoutSoundFile []string            `json:"padsWithoutSoundFile"` // pad URLs without a canonically named file
+	MisnamedSoundFiles   []MisnamedSoundFile `json:"misnamedSoundFiles"`
+}
+
+// reconcile compares the mapping with the YAML entries and the listing of the sound file directory
+func reconcile(mappings []PadMapping, existingEntries map[string]*CiREntry, soundFiles []string) Reconciliation {
+	result := Reconciliation{
+		OrphanSoundFiles:     []string{},
+		OrphanYAMLEntries:    []OrphanYAMLEntry{},
+		PadsWithoutSoundFile: []string{},
+		MisnamedSoundFiles:   []MisnamedSoundFile{},
+	}
+
+	padsByDate := map[string]PadMapping{}
+	for _, mapping := range mappings {
+		padsByDate[mapping.Date] = mapping
+	}
+
+	canonicalFiles := map[string]bool{}
+	for _, name := range soundFiles {
+		if !isAudioFile(name) {
+			continue
+		}
+		if date, ok := dateFromSoundFileName(name); ok {
+			canonicalFiles[date] = true
+			if _, hasPad := padsByDate[date]; !hasPad {
+				result.OrphanSoundFiles = append(result.OrphanSoundFiles, name)
+			}
+			continue
+		}
+
+		date, ok := looseDateFromFileName(name)
+		if !ok {
+			result.OrphanSoundFiles = append(result.OrphanSoundFiles, name)
+			continue
+		}
+		mapping, hasPad := padsByDate[date]
+		if !hasPad {
+			result.OrphanSoundFiles = append(result.OrphanSoundFiles, name)
+			continue
+		}
+		// only mp3 files are expected under the canonical name, other formats are renditions
+		if strings.EqualFold(path.Ext(name), ".mp3") {
+			result.MisnamedSoundFiles = append(result.MisnamedSoundFiles, MisnamedSoundFile{
+				Date:     date,
+				PadURL:   mapping.PadURL,
+				FileName: name,
+				Expected: mapping.SoundFileName,
+			})
+		}
+	}
+
+	for _, mapping := range mappings {
+		if !canonicalFiles[mapping.Date] {
+			result.PadsWithoutSoundFile = append(result.PadsWithoutSoundFile, mapping.PadURL)
+		}
+	}
+
+	for date, entry := range existingEntries {
+		if _, hasPad := padsByDate[date]; !hasPad {
+			result.OrphanYAMLEntries = append(result.OrphanYAMLEntries, OrphanYAMLEntry{Date: date, UUID: entry.UUID})
+		}
+	}
+	sort.Slice(result.OrphanYAMLEntries, func(i, j int) bool {
+		return result.OrphanYAMLEntries[i].Date < result.OrphanYAMLEntries[j].Date
+	})
+
+	return result
+}
+
+func writeReconciliationMarkdown(w io.Writer, result Reconciliation) error {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("## Sound files without pad (%d)\n\n", len(result.OrphanSoundFiles)))
+	for _, name := range result.OrphanSoundFiles {
+		b.WriteString(fmt.Sprintf("* %s\n", name))
+	}
+
+	b.WriteString(fmt.Sprintf("\n## YAML entries without pad (%d)\n\n", len(result.OrphanYAMLEntries)))
+	for _, entry := range result.OrphanYAMLEntries {
+		b.WriteString(fmt.Sprintf("* %s (%s)\n", entry.Date, entry.UUID))
+	}
+
+	b.WriteString(fmt.Sprintf("\n## Pads without sound file (%d)\n\n", len(result.PadsWithoutSoundFile)))
+	for _, padURL := range result.PadsWithoutSoundFile {
+		b.WriteString(fmt.Sprintf("* %s\n", padURL))
+	}
+
+	b.WriteString(fmt.Sprintf("\n## Misnamed sound files (%d)\n\n", len(result.MisnamedSoundFiles)))
+	for _, misnamed := range result.MisnamedSoundFiles {
+		b.WriteString(fmt.Sprintf("* %s should be named %s (%s)\n", misnamed.FileName, misnamed.Expected, misnamed.PadURL))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func runReconcileCommand(logger *logrus.Logger, config *Config) error {
+	err := checkFormat("reconcile", config.Format, "md", "json")
+	if err != nil {
+		return err
+	}
+	u, _ := url.JoinPath(config.PadBaseURL, "Radio")
+	padURLs, err := getAllPadLinks(u)
+	if err != nil {
+		return fmt.Errorf("failed to get pad URLs from %s: %v", u, err)
+	}
+
+	existingEntries, err := readExistingYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing YAML entries: %v", err)
+	}
+
+	mappings, err := createPadMapping(padURLs, existingEntries, config)
+	if err != nil {
+		return fmt.Errorf("failed to create mapping: %v", err)
+	}
+
+	soundFiles, err := listSoundFiles(config)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Reconciling %d pads, %d YAML entries and %d files", len(mappings), len(existingEntries), len(soundFiles))
+
+	result := reconcile(mappings, existingEntries, soundFiles)
+
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch config.Format {
+	case "", "md":
+		return writeReconciliationMarkdown(out, result)
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	return nil
+}
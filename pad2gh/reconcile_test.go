@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestReconcile(t *testing.T) {
+	mappings := []PadMapping{
+		{Date: "2024-01-15", PadURL: "https://pad.ccc-p.org/Radio_2024-01-15_test1", SoundFileName: "2024_01_15-chaos-im-radio.mp3"},
+		{Date: "2024-02-12", PadURL: "https://pad.ccc-p.org/Radio_2024-02-12_test2", SoundFileName: "2024_02_12-chaos-im-radio.mp3"},
+	}
+	existingEntries := map[string]*CiREntry{
+		"2024-01-15": {UUID: "nt-2024-01-15"},
+		"2019-04-08": {UUID: "62a017fa-5a4b-11e9-b100-f7faed535345"},
+	}
+	soundFiles := []string{
+		"2024_01_15-chaos-im-radio.mp3",
+		"2024-02-12-chaos-im-radio.mp3",
+		"2019_04_08-nerdtalk.mp3",
+		"index.html",
+	}
+
+	result := reconcile(mappings, existingEntries, soundFiles)
+
+	if len(result.OrphanSoundFiles) != 1 || result.OrphanSoundFiles[0] != "2019_04_08-nerdtalk.mp3" {
+		t.Errorf("Expected 2019_04_08-nerdtalk.mp3 as orphan sound file, got %v", result.OrphanSoundFiles)
+	}
+	if len(result.OrphanYAMLEntries) != 1 || result.OrphanYAMLEntries[0].Date != "2019-04-08" {
+		t.Errorf("Expected 2019-04-08 as orphan YAML entry, got %v", result.OrphanYAMLEntries)
+	}
+	if len(result.PadsWithoutSoundFile) != 1 || result.PadsWithoutSoundFile[0] != mappings[1].PadURL {
+		t.Errorf("Expected second pad without sound file, got %v", result.PadsWithoutSoundFile)
+	}
+	if len(result.MisnamedSoundFiles) != 1 || result.MisnamedSoundFiles[0].Expected != "2024_02_12-chaos-im-radio.mp3" {
+		t.Errorf("Expected misnamed file for second pad, got %v", result.MisnamedSoundFiles)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reportSections are the pad sections relevant for the publication
+var reportSections = []string{"summary", "shownotes", "mukke", "kapitel"}
+
+// EpisodeStatus is the readiness of one pad date as shown in the dashboard
+type EpisodeStatus struct {
+	Mapping         PadMapping
+	Tags            []string
+	FilledSections  []string
+	MissingSections []string
+	MusicCount      int
+	ChapterCount    int
+	Diagnostics     []string
+	Blockers        []string
+}
+
+// Published reports whether the episode is already in the YAML file
+func (s EpisodeStatus) Published() bool {
+	return s.Mapping.HasYAMLEntry
+}
+
+// SoundFileSizeMB returns the size of the sound file in MB for display
+func (s EpisodeStatus) SoundFileSizeMB() string {
+	if s.Mapping.SoundFileSize <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f MB", float64(s.Mapping.SoundFileSize)/1e6)
+}
+
+// sectionContent returns the non-empty lines of the first existing section
+func sectionContent(contentBySection map[string][]string, names ...string) []string {
+	for _, name := range names {
+		lines, exists := contentBySection[name]
+		if !exists {
+			continue
+		}
+		var content []string
+		for _, line := range lines {
+			if strings.TrimSpace(line) != "" {
+				content = append(content, line)
+			}
+		}
+		return content
+	}
+	return nil
+}
+
+// analyzePadSections fills the section related fields of the status without any further requests
+func (s *EpisodeStatus) analyzePadSections(contentBySection map[string][]string) {
+	s.Tags = contentBySection["tags"]
+
+	aliases := map[string][]string{
+		"summary":   {"summary"},
+		"shownotes": {"shownotes", "long summary"},
+		"mukke":     {"mukke"},
+		"kapitel":   {"kapitel", "chapters"},
+	}
+	for _, section := range reportSections {
+		if len(sectionContent(contentBySection, aliases[section]...)) > 0 {
+			s.FilledSections = append(s.FilledSections, section)
+		} else {
+			s.MissingSections = append(s.MissingSections, section)
+		}
+	}
+
+	for _, line := range sectionContent(contentBySection, "mukke") {
+		if _, link := findFirstLink(line); link != "" {
+			s.MusicCount++
+		}
+	}
+	for _, line := range sectionContent(contentBySection, "kapitel", "chapters") {
+		if len(strings.Split(line, " ")) >= 2 {
+			s.ChapterCount++
+		}
+	}
+}
+
+// collectEpisodeStatus fetches the pad of every mapping and works out what blocks its publication
+func collectEpisodeStatus(logger *logrus.Logger, mappings []PadMapping, config *Config) []EpisodeStatus {
+	var statuses []EpisodeStatus
+	for _, mapping := range mappings {
+		status := EpisodeStatus{Mapping: mapping}
+
+		contentBySection, padWarnings, err := getMarkdownContentBySection(mapping.PadURL)
+		if err != nil {
+			logger.Warnf("Failed to fetch pad %s: %v", mapping.PadURL, err)
+			status.Diagnostics = append(status.Diagnostics, fmt.Sprintf("pad not readable: %v", err))
+			status.Blockers = append(status.Blockers, "pad not readable")
+			statuses = append(statuses, status)
+			continue
+		}
+		status.Diagnostics = append(status.Diagnostics, padWarnings...)
+		status.analyzePadSections(contentBySection)
+
+		if !mapping.HasYAMLEntry {
+			// run the real pipeline on outstanding episodes to show the warnings the PR would get
+			entry := &CiREntry{padURL: mapping.PadURL}
+			err = populateEntryFromSections(entry, contentBySection, mapping.Date)
+			if err != nil {
+				status.Diagnostics = append(status.Diagnostics, err.Error())
+				status.Blockers = append(status.Blockers, "pad cannot be imported")
+			}
+			status.Diagnostics = append(status.Diagnostics, entry.processingWarnings...)
+
+			if !entry.tags["shownotes_complete"] {
+				status.Blockers = append(status.Blockers, "not tagged shownotes_complete")
+			}
+			if !mapping.hasSoundFile(config) {
+				status.Blockers = append(status.Blockers, "sound file "+mapping.SoundFileName+" missing")
+			}
+			for _, candidate := range mapping.SoundFileCandidates {
+				status.Diagnostics = append(status.Diagnostics, fmt.Sprintf("possible sound file %s (%s, confidence %.2f)", candidate.FileName, candidate.Reason, candidate.Confidence))
+			}
+			if config.StrictMode && len(entry.processingWarnings) > 0 {
+				status.Blockers = append(status.Blockers, "warnings in strict mode")
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	// newest episodes first, those are the ones being worked on
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Mapping.Date > statuses[j].Mapping.Date
+	})
+	return statuses
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="de">
+<head>
+<meta charset="utf-8">
+<title>Chaos im Radio – Episode Status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.5em; text-align: left; vertical-align: top; }
+tr.published { background: #e8f5e0; }
+tr.ready { background: #fff8d0; }
+tr.blocked { background: #fbe3e3; }
+.missing { color: #a00; }
+ul { margin: 0; padding-left: 1.2em; }
+</style>
+</head>
+<body>
+<h1>Chaos im Radio – Episode Status</h1>
+<p>Generated {{.Generated}}: {{.Published}} published, {{.Ready}} ready, {{.Blocked}} blocked.</p>
+<table>
+<tr><th>Date</th><th>Tags</th><th>Sections</th><th>Music</th><th>Chapters</th><th>Sound file</th><th>YAML</th><th>Blocked by / Diagnostics</th></tr>
+{{range .Statuses}}<tr class="{{if .Published}}published{{else if .Blockers}}blocked{{else}}ready{{end}}">
+<td><a href="{{.Mapping.PadURL}}">{{.Mapping.Date}}</a></td>
+<td>{{range .Tags}}<code>{{.}}</code> {{end}}</td>
+<td>{{range .FilledSections}}{{.}} {{end}}{{range .MissingSections}}<span class="missing">{{.}}</span> {{end}}</td>
+<td>{{.MusicCount}}</td>
+<td>{{.ChapterCount}}</td>
+<td>{{if .Mapping.HasSoundFileLocal}}local {{end}}{{if .Mapping.HasSoundFileOnline}}online {{end}}{{.SoundFileSizeMB}}</td>
+<td>{{if .Published}}yes{{else}}no{{end}}</td>
+<td><ul>{{range .Blockers}}<li><strong>{{.}}</strong></li>{{end}}{{range .Diagnostics}}<li>{{.}}</li>{{end}}</ul></td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// dashboardCounts returns the number of published, ready and blocked episodes
+func dashboardCounts(statuses []EpisodeStatus) (int, int, int) {
+	published, ready, blocked := 0, 0, 0
+	for _, status := range statuses {
+		switch {
+		case status.Published():
+			published++
+		case len(status.Blockers) > 0:
+			blocked++
+		default:
+			ready++
+		}
+	}
+	return published, ready, blocked
+}
+
+func writeDashboardHTML(w io.Writer, statuses []EpisodeStatus, generated time.Time) error {
+	published, ready, blocked := dashboardCounts(statuses)
+	return dashboardTemplate.Execute(w, map[string]interface{}{
+		"Generated": generated.Format("2006-01-02 15:04"),
+		"Published": published,
+		"Ready":     ready,
+		"Blocked":   blocked,
+		"Statuses":  statuses,
+	})
+}
+
+// markdownCell escapes the characters breaking a markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+func writeDashboardMarkdown(w io.Writer, statuses []EpisodeStatus, generated time.Time) error {
+	published, ready, blocked := dashboardCounts(statuses)
+
+	var b strings.Builder
+	b.WriteString("# Chaos im Radio – Episode Status\n\n")
+	b.WriteString(fmt.Sprintf("Generated %s: %d published, %d ready, %d blocked.\n\n", generated.Format("2006-01-02 15:04"), published, ready, blocked))
+	b.WriteString("| Date | Tags | Missing sections | Music | Chapters | Sound file | YAML | Blocked by / Diagnostics |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, status := range statuses {
+		var soundFile []string
+		if status.Mapping.HasSoundFileLocal {
+			soundFile = append(soundFile, "local")
+		}
+		if status.Mapping.HasSoundFileOnline {
+			soundFile = append(soundFile, "online")
+		}
+		if size := status.SoundFileSizeMB(); size != "" {
+			soundFile = append(soundFile, size)
+		}
+		yamlEntry := "no"
+		if status.Published() {
+			yamlEntry = "yes"
+		}
+		var notes []string
+		for _, blocker := range status.Blockers {
+			notes = append(notes, "**"+blocker+"**")
+		}
+		notes = append(notes, status.Diagnostics...)
+
+		b.WriteString(fmt.Sprintf("| [%s](%s) | %s | %s | %d | %d | %s | %s | %s |\n",
+			status.Mapping.Date, status.Mapping.PadURL,
+			markdownCell(strings.Join(status.Tags, " ")),
+			markdownCell(strings.Join(status.MissingSections, " ")),
+			status.MusicCount, status.ChapterCount,
+			strings.Join(soundFile, " "), yamlEntry,
+			markdownCell(strings.Join(notes, "<br>"))))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// checkFormat rejects a -format the command doesn't write, the commands check it first so a typo neither truncates
+// -out nor leaves the work half done
+func checkFormat(command, format string, formats ...string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s format %s, use %s", command, format, strings.Join(formats, ", "))
+}
+
+// createOutput opens the output file of a command, an empty path writes to stdout
+func createOutput(outputPath string) (io.WriteCloser, error) {
+	if outputPath == "" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(outputPath)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func runReportCommand(logger *logrus.Logger, config *Config) error {
+	err := checkFormat("report", config.Format, "html", "md")
+	if err != nil {
+		return err
+	}
+	u, _ := url.JoinPath(config.PadBaseURL, "Radio")
+	padURLs, err := getAllPadLinks(u)
+	if err != nil {
+		return fmt.Errorf("failed to get pad URLs from %s: %v", u, err)
+	}
+
+	existingEntries, err := readExistingYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing YAML entries: %v", err)
+	}
+
+	mappings, err := createPadMapping(padURLs, existingEntries, config)
+	if err != nil {
+		return fmt.Errorf("failed to create mapping: %v", err)
+	}
+
+	err = applySoundFileCandidates(logger, mappings, config)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Collecting status of %d pads...", len(mappings))
+	statuses := collectEpisodeStatus(logger, mappings, config)
+
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch config.Format {
+	case "", "html":
+		return writeDashboardHTML(out, statuses, time.Now())
+	case "md":
+		return writeDashboardMarkdown(out, statuses, time.Now())
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAnalyzePadSections(t *testing.T) {
+	contentBySection := getMockPadContent()
+	contentBySection["tags"] = []string{"cccp", "shownotes_complete"}
+
+	var status EpisodeStatus
+	status.analyzePadSections(contentBySection)
+
+	if status.MusicCount != 1 {
+		t.Errorf("Expected 1 music track, got %d", status.MusicCount)
+	}
+	if status.ChapterCount != 3 {
+		t.Errorf("Expected 3 chapters, got %d", status.ChapterCount)
+	}
+	if len(status.MissingSections) != 0 {
+		t.Errorf("Expected no missing sections, got %v", status.MissingSections)
+	}
+	if len(status.Tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", status.Tags)
+	}
+
+	delete(contentBySection, "chapters")
+	contentBySection["summary"] = []string{""}
+	status = EpisodeStatus{}
+	status.analyzePadSections(contentBySection)
+	if strings.Join(status.MissingSections, ",") != "summary,kapitel" {
+		t.Errorf("Expected summary and kapitel to be missing, got %v", status.MissingSections)
+	}
+}
+
+func TestWriteDashboard(t *testing.T) {
+	statuses := []EpisodeStatus{
+		{
+			Mapping:  PadMapping{Date: "2024-02-12", PadURL: "https://pad.ccc-p.org/Radio_2024-02-12_test2"},
+			Blockers: []string{"not tagged shownotes_complete"},
+		},
+		{
+			Mapping: PadMapping{Date: "2024-01-15", PadURL: "https://pad.ccc-p.org/Radio_2024-01-15_test1", HasYAMLEntry: true, HasSoundFileLocal: true, SoundFileSize: 52_000_000},
+		},
+	}
+
+	var md bytes.Buffer
+	err := writeDashboardMarkdown(&md, statuses, time.Now())
+	if err != nil {
+		t.Fatalf("writeDashboardMarkdown() failed: %v", err)
+	}
+	if !strings.Contains(md.String(), "1 published, 0 ready, 1 blocked") {
+		t.Errorf("Expected totals in markdown dashboard, got:\n%s", md.String())
+	}
+	if !strings.Contains(md.String(), "local 52.0 MB") {
+		t.Errorf("Expected sound file size in markdown dashboard, got:\n%s", md.String())
+	}
+
+	var html bytes.Buffer
+	err = writeDashboardHTML(&html, statuses, time.Now())
+	if err != nil {
+		t.Fatalf("writeDashboardHTML() failed: %v", err)
+	}
+	if !strings.Contains(html.String(), `<tr class="blocked">`) || !strings.Contains(html.String(), `<tr class="published">`) {
+		t.Errorf("Expected blocked and published rows in html dashboard")
+	}
+}
+
+func TestUnknownFormatKeepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "persons.md")
+	if err := os.WriteFile(outFile, []byte("last report"), 0o644); err != nil {
+		t.Fatalf("Failed to write output: %v", err)
+	}
+
+	config := &Config{ContentFilePath: filepath.Join(dir, "content.yaml"), OutputPath: outFile, Format: "yaml"}
+	err := runPersonsCommand(logrus.New(), config)
+	if err == nil || !strings.Contains(err.Error(), "use md, json") {
+		t.Errorf("Expected an error listing the formats, got %v", err)
+	}
+	if content, _ := os.ReadFile(outFile); string(content) != "last report" {
+		t.Errorf("Expected -out to be untouched, got %q", content)
+	}
+}
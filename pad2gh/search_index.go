@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// searchIndexVersion is increased whenever the site needs to read the index differently
+const searchIndexVersion = 1
+
+// germanStopWords are too common to be worth indexing
+var germanStopWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`aber alle als also am an auch auf aus bei bin bis bist da damit dann das
+		dass dem den denn der des die dies diese dieser dir doch dort du ein eine einem einen einer eines er es
+		etwa für hat hatte hier ich ihr im in ist ja jetzt kann man mal mit nach nicht noch nun nur ob oder schon
+		sehr sich sie sind so um und uns von vom vor war was weil wenn wer wie wir wird wo zu zum zur über
+		the and of to is in it`) {
+		germanStopWords[word] = true
+	}
+}
+
+var (
+	markdownLinkRegex = regexp.MustCompile(`\[([^\]]*)\]\([^)]+\)`)
+	htmlEntityRegex   = regexp.MustCompile(`&#?\w+;`)
+)
+
+// SearchIndex is a compact inverted index for a search on the static site, nothing on the site reads it yet.
+// A search has to stem the words of the query with the same Snowball German stemmer and look them up in Terms.
+type SearchIndex struct {
+	Version  int                `json:"version"`
+	Episodes []SearchEpisode    `json:"episodes"`
+	Docs     []SearchDoc        `json:"docs"`
+	Terms    map[string][][]int `json:"terms"` // stem: [document index, number of occurrences]
+}
+
+// SearchEpisode is an episode the documents point to
+type SearchEpisode struct {
+	UUID  string `json:"uuid"`
+	Title string `json:"title"`
+	Date  string `json:"date"`
+}
+
+// SearchDoc is an indexed part of an episode, chapters and transcript cues know their time in the episode
+type SearchDoc struct {
+	Episode int    `json:"e"`
+	Field   string `json:"f"` // title, summary, shownotes, chapter or transcript
+	Start   string `json:"t,omitempty"`
+	Text    string `json:"x,omitempty"` // snippet to show, only for chapters and transcript cues
+}
+
+// searchTerms splits the text into words and returns their stems, stop words and single letters are dropped
+func searchTerms(text string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) }) {
+		if len([]rune(word)) < 2 || germanStopWords[word] {
+			continue
+		}
+		terms = append(terms, germanStem(word))
+	}
+	return terms
+}
+
+// plainShownotes drops the link targets and HTML entities of long_summary_md, which aren't worth searching for
+func plainShownotes(markdown string) string {
+	text := markdownLinkRegex.ReplaceAllString(markdown, "$1")
+	return htmlEntityRegex.ReplaceAllString(text, " ")
+}
+
+// addDoc adds a document and its terms to the index
+func (index *SearchIndex) addDoc(doc SearchDoc, text string) {
+	counts := map[string]int{}
+	var order []string
+	for _, term := range searchTerms(text) {
+		if counts[term] == 0 {
+			order = append(order, term)
+		}
+		counts[term]++
+	}
+	if len(order) == 0 {
+		return
+	}
+	docIndex := len(index.Docs)
+	index.Docs = append(index.Docs, doc)
+	for _, term := range order {
+		index.Terms[term] = append(index.Terms[term], []int{docIndex, counts[term]})
+	}
+}
+
+// transcriptFilePath returns the WebVTT transcript of the entry in transcriptDir, or "" without one
+func transcriptFilePath(entry *CiREntry, transcriptDir string) string {
+	for _, transcript := range entry.Transcripts {
+		if transcript.Type == "text/vtt" {
+			return filepath.Join(transcriptDir, path.Base(transcript.Url))
+		}
+	}
+	return ""
+}
+
+// buildSearchIndex indexes the titles, summaries, shownotes, chapter titles and transcripts of the entries
+func buildSearchIndex(logger *logrus.Logger, entries []*CiREntry, transcriptDir string) *SearchIndex {
+	index := &SearchIndex{Version: searchIndexVersion, Episodes: []SearchEpisode{}, Docs: []SearchDoc{}, Terms: map[string][][]int{}}
+	for _, entry := range entries {
+		episode := len(index.Episodes)
+		date := ""
+		if len(entry.PublicationDate) >= 10 {
+			date = entry.PublicationDate[:10]
+		}
+		index.Episodes = append(index.Episodes, SearchEpisode{UUID: entry.UUID, Title: entry.Title, Date: date})
+
+		index.addDoc(SearchDoc{Episode: episode, Field: "title"}, entry.Title+" "+entry.Subtitle)
+		index.addDoc(SearchDoc{Episode: episode, Field: "summary"}, entry.Summary)
+		index.addDoc(SearchDoc{Episode: episode, Field: "shownotes"}, plainShownotes(entry.LongSummaryMD))
+		for _, chapter := range entry.Chapters {
+			index.addDoc(SearchDoc{Episode: episode, Field: "chapter", Start: chapter.Start, Text: chapter.Title}, chapter.Title)
+		}
+
+		filePath := transcriptFilePath(entry, transcriptDir)
+		if filePath == "" {
+			continue
+		}
+		cues, _, err := readWhisperOutput(filePath)
+		if err != nil {
+			logger.Warnf("Not indexing the transcript of %s: %v", entry.UUID, err)
+			continue
+		}
+		for _, cue := range cues {
+			text := strings.Join(strings.Fields(cue.Text), " ")
+			index.addDoc(SearchDoc{Episode: episode, Field: "transcript", Start: formatChapterStart(cue.Start), Text: text}, text)
+		}
+	}
+	return index
+}
+
+// runIndexCommand writes the search index of all entries as compact JSON to -out
+func runIndexCommand(logger *logrus.Logger, config *Config) error {
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	index := buildSearchIndex(logger, entries, config.TranscriptDir)
+	logger.Infof("Indexed %d documents of %d episodes with %d terms", len(index.Docs), len(index.Episodes), len(index.Terms))
+
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	// no indentation, the site downloads the whole index
+	return json.NewEncoder(out).Encode(index)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestGermanStem(t *testing.T) {
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{"zeichen", "zeich"},
+		{"zeichens", "zeich"},
+		{"häuser", "haus"},
+		{"häusern", "haus"},
+		{"möglichkeiten", "moglich"},
+		{"wohnung", "wohnung"},
+		{"smartphone", "smartphon"},
+		{"smartphones", "smartphon"},
+		{"aufeinanderfolgenden", "aufeinanderfolg"},
+		{"straße", "strass"},
+		{"ccc", "ccc"},
+		{"2024", "2024"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if stem := germanStem(tt.word); stem != tt.expected {
+				t.Errorf("germanStem(%q) = %q, expected %q", tt.word, stem, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildSearchIndex(t *testing.T) {
+	transcriptDir := filepath.Join(t.TempDir(), "transcripts")
+	if err := os.MkdirAll(transcriptDir, 0o755); err != nil {
+		t.Fatalf("Failed to create %s: %v", transcriptDir, err)
+	}
+	vtt := "WEBVTT\n\n00:00:00.000 --> 00:00:04.000\nHallo beim Chaos im Radio.\n\n00:09:32.500 --> 00:09:40.000\nJetzt ein Update zu den CE-Zeichen auf Ladegeräten.\n\n"
+	if err := os.WriteFile(filepath.Join(transcriptDir, "nt-2024-01-15.vtt"), []byte(vtt), 0o644); err != nil {
+		t.Fatalf("Failed to write transcript: %v", err)
+	}
+
+	entries := []*CiREntry{
+		{
+			UUID: "nt-2024-01-08", Title: "CiR am 08.01.2024", PublicationDate: "2024-01-08T00:00:00+00:00",
+			Summary:       "Jahresrückblick",
+			LongSummaryMD: "**Shownotes:**\n\n* [Häuser](https://example.org/zeichen)\n\n&#x1f3b6;&nbsp;[Song](https://example.org)",
+		},
+		{
+			UUID: "nt-2024-01-15", Title: "CiR am 15.01.2024", PublicationDate: "2024-01-15T00:00:00+00:00",
+			Summary:     "Smartphones",
+			Chapters:    []CiRChapter{{Start: "00:00:00", Title: "Intro"}, {Start: "00:09:32", Title: "Update CE-Zeichen"}},
+			Transcripts: []CiRTranscript{{Url: "transcripts/nt-2024-01-15.vtt", Type: "text/vtt", Language: "de"}},
+		},
+	}
+
+	index := buildSearchIndex(logrus.New(), entries, transcriptDir)
+	if len(index.Episodes) != 2 || index.Episodes[1].Date != "2024-01-15" {
+		t.Fatalf("unexpected episodes %+v", index.Episodes)
+	}
+
+	// "Zeichen" is in a chapter and a transcript cue, but not in the link target of the first entry
+	var found []SearchDoc
+	for _, posting := range index.Terms[germanStem("zeichen")] {
+		found = append(found, index.Docs[posting[0]])
+	}
+	expected := []SearchDoc{
+		{Episode: 1, Field: "chapter", Start: "00:09:32", Text: "Update CE-Zeichen"},
+		{Episode: 1, Field: "transcript", Start: "00:09:32.500", Text: "Jetzt ein Update zu den CE-Zeichen auf Ladegeräten."},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, found)
+	}
+	for i := range found {
+		if found[i] != expected[i] {
+			t.Errorf("document %d = %+v, expected %+v", i, found[i], expected[i])
+		}
+	}
+
+	if postings := index.Terms["haus"]; len(postings) != 1 || index.Docs[postings[0][0]].Field != "shownotes" {
+		t.Errorf("expected the shownotes of the first entry for haus, got %v", postings)
+	}
+	for _, term := range []string{"der", "x1f3b6", "nbsp", "https"} {
+		if _, exists := index.Terms[term]; exists {
+			t.Errorf("%q should not be indexed", term)
+		}
+	}
+}
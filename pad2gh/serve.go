@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxWebhookBodySize limits the request bodies accepted by the webhook endpoints
+const maxWebhookBodySize = 1 << 20
+
+const (
+	// finished jobs are listed under /jobs for a day, at most maxFinishedJobs of them
+	jobRetention    = 24 * time.Hour
+	maxFinishedJobs = 100
+	// a job keeps the sources of its last maxJobTriggers calls, TriggerCount counts all of them
+	maxJobTriggers = 20
+)
+
+var dateRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// webhookJob is the import of one episode date triggered by one or more webhook calls
+type webhookJob struct {
+	Date         string    `json:"date"`
+	PadURL       string    `json:"padURL,omitempty"`
+	Status       string    `json:"status"` // queued, running, done or failed
+	Outcome      string    `json:"outcome,omitempty"`
+	Triggers     []string  `json:"triggers"`
+	TriggerCount int       `json:"triggerCount"`
+	Requested    time.Time `json:"requested"`
+	Updated      time.Time `json:"updated"`
+	rerun        bool
+}
+
+// addTrigger records a webhook call of the job, only the last maxJobTriggers sources are kept
+func (job *webhookJob) addTrigger(trigger string) {
+	job.TriggerCount++
+	job.Triggers = append(job.Triggers, trigger)
+	if len(job.Triggers) > maxJobTriggers {
+		job.Triggers = append([]string(nil), job.Triggers[len(job.Triggers)-maxJobTriggers:]...)
+	}
+}
+
+// jobQueue dedupes webhook calls per date and hands the dates to a single worker
+type jobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*webhookJob
+	pending chan string
+}
+
+func newJobQueue(size int) *jobQueue {
+	return &jobQueue{
+		jobs:    map[string]*webhookJob{},
+		pending: make(chan string, size),
+	}
+}
+
+// enqueue adds a job for the date. Calls for a date that is already queued are merged into the
+// queued job, calls for a running job schedule exactly one follow-up run.
+func (q *jobQueue) enqueue(date, padURL, trigger string) (webhookJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	job, exists := q.jobs[date]
+	if exists && (job.Status == "queued" || job.Status == "running") {
+		job.addTrigger(trigger)
+		job.Updated = now
+		if padURL != "" {
+			job.PadURL = padURL
+		}
+		if job.Status == "running" {
+			job.rerun = true
+		}
+		return *job, nil
+	}
+
+	job = &webhookJob{
+		Date:      date,
+		PadURL:    padURL,
+		Status:    "queued",
+		Requested: now,
+		Updated:   now,
+	}
+	job.addTrigger(trigger)
+	select {
+	case q.pending <- date:
+	default:
+		return webhookJob{}, fmt.Errorf("job queue is full")
+	}
+	q.jobs[date] = job
+	q.prune(now)
+	return *job, nil
+}
+
+// prune forgets the finished jobs older than jobRetention and the oldest beyond maxFinishedJobs, queued and running
+// jobs are bounded by the size of the queue. The caller holds the lock.
+func (q *jobQueue) prune(now time.Time) {
+	var finished []*webhookJob
+	for date, job := range q.jobs {
+		if job.Status != "done" && job.Status != "failed" {
+			continue
+		}
+		if now.Sub(job.Updated) > jobRetention {
+			delete(q.jobs, date)
+			continue
+		}
+		finished = append(finished, job)
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Updated.After(finished[j].Updated)
+	})
+	for _, job := range finished[maxFinishedJobs:] {
+		delete(q.jobs, job.Date)
+	}
+}
+
+// start marks the job of the date as running and returns the pad URL known for it
+func (q *jobQueue) start(date string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.jobs[date]
+	job.Status = "running"
+	job.Updated = time.Now()
+	return job.PadURL
+}
+
+// finish records the result of a job and requeues it if webhook calls arrived while it was running. If the queue is
+// full the follow-up run is dropped, noted in the outcome and returned as error.
+func (q *jobQueue) finish(date, outcome string, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	defer q.prune(now)
+	job := q.jobs[date]
+	job.Updated = now
+	if err != nil {
+		job.Status = "failed"
+		job.Outcome = err.Error()
+	} else {
+		job.Status = "done"
+		job.Outcome = outcome
+	}
+
+	if job.rerun {
+		job.rerun = false
+		select {
+		case q.pending <- date:
+			job.Status = "queued"
+		default:
+			job.Outcome += "; the follow-up run was dropped, the job queue is full"
+			return fmt.Errorf("job queue is full, dropped the follow-up run for %s", date)
+		}
+	}
+	return nil
+}
+
+// snapshot returns a copy of all jobs, the newest first
+func (q *jobQueue) snapshot() []webhookJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]webhookJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Updated.After(jobs[j].Updated)
+	})
+	return jobs
+}
+
+func (q *jobQueue) get(date string) (webhookJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, exists := q.jobs[date]
+	if !exists {
+		return webhookJob{}, false
+	}
+	return *job, true
+}
+
+// verifyWebhookSignature checks the request against the shared secret. Senders able to sign the body
+// use a "sha256=<hex hmac>" header (X-Hub-Signature-256 for GitHub, X-Signature-256 for everyone else),
+// senders that can only set static headers (e.g. Nextcloud) use "Authorization: Bearer <secret>".
+func verifyWebhookSignature(secret string, r *http.Request, body []byte) bool {
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = r.Header.Get("X-Signature-256")
+	}
+	if signature != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+
+	return verifyBearerToken(secret, r)
+}
+
+// verifyBearerToken checks "Authorization: Bearer <secret>"
+func verifyBearerToken(secret string, r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// requireBearerToken protects the job status, which shows pad URLs and errors, with the webhook secret
+func requireBearerToken(secret string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !verifyBearerToken(secret, r) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// nextcloudPayload covers the file events of Nextcloud webhook listeners and flows posting only the path
+type nextcloudPayload struct {
+	Path  string `json:"path"`
+	Event struct {
+		Node struct {
+			Path string `json:"path"`
+		} `json:"node"`
+	} `json:"event"`
+}
+
+// padPayload is the generic pad-changed ping, one of the fields is enough
+type padPayload struct {
+	PadURL string `json:"padURL"`
+	Date   string `json:"date"`
+}
+
+// githubDispatchPayload is the repository_dispatch event, the client payload is a padPayload
+type githubDispatchPayload struct {
+	Action        string     `json:"action"`
+	ClientPayload padPayload `json:"client_payload"`
+}
+
+// parseWebhook extracts the episode date and, if known, the pad URL from a webhook call
+func parseWebhook(source string, r *http.Request, body []byte, padBaseURL string) (string, string, error) {
+	switch source {
+	case "nextcloud":
+		var payload nextcloudPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return "", "", fmt.Errorf("invalid nextcloud payload: %v", err)
+		}
+		filePath := payload.Event.Node.Path
+		if filePath == "" {
+			filePath = payload.Path
+		}
+		date, ok := dateFromSoundFileName(filePath)
+		if !ok {
+			return "", "", errIgnoredWebhook
+		}
+		return date, "", nil
+	case "github":
+		if event := r.Header.Get("X-GitHub-Event"); event != "repository_dispatch" {
+			return "", "", errIgnoredWebhook
+		}
+		var payload githubDispatchPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return "", "", fmt.Errorf("invalid repository_dispatch payload: %v", err)
+		}
+		return datePadURLFromPayload(payload.ClientPayload, padBaseURL)
+	case "pad":
+		var payload padPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return "", "", fmt.Errorf("invalid pad payload: %v", err)
+		}
+		return datePadURLFromPayload(payload, padBaseURL)
+	}
+	return "", "", fmt.Errorf("unknown webhook source %s", source)
+}
+
+// errIgnoredWebhook marks valid webhook calls that don't concern an episode, e.g. other uploads
+var errIgnoredWebhook = errors.New("webhook does not concern an episode")
+
+func datePadURLFromPayload(payload padPayload, padBaseURL string) (string, string, error) {
+	if payload.PadURL != "" {
+		if !strings.HasPrefix(payload.PadURL, padBaseURL) {
+			return "", "", fmt.Errorf("pad url must start with %s", padBaseURL)
+		}
+		date, err := extractDateFromPadURL(payload.PadURL)
+		if err != nil {
+			return "", "", err
+		}
+		return date, payload.PadURL, nil
+	}
+	if !dateRegex.MatchString(payload.Date) {
+		return "", "", fmt.Errorf("payload must contain a padURL or a date in the format YYYY-MM-DD")
+	}
+	return payload.Date, "", nil
+}
+
+// findPadURLForDate looks up the pad of a date on the Radio page
+func findPadURLForDate(config *Config, date string) (string, error) {
+	u, _ := url.JoinPath(config.PadBaseURL, "Radio")
+	padURLs, err := getAllPadLinks(u)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pad URLs from %s: %v", u, err)
+	}
+	for _, padURL := range padURLs {
+		if padDate, err := extractDateFromPadURL(padURL); err == nil && padDate == date {
+			return padURL, nil
+		}
+	}
+	return "", fmt.Errorf("no pad for %s found on %s", date, u)
+}
+
+// runImportJob runs the single-entry pipeline for one date
+func runImportJob(logger *logrus.Logger, config *Config, state *watchState, date, padURL string) (string, error) {
+	var err error
+	if padURL == "" {
+		padURL, err = findPadURLForDate(config, date)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	existingEntries, err := readExistingYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read existing YAML entries: %v", err)
+	}
+	mappings, err := createPadMapping([]string{padURL}, existingEntries, config)
+	if err != nil {
+		return "", err
+	}
+	if len(mappings) == 0 {
+		return "", fmt.Errorf("no date found in pad URL: %s", padURL)
+	}
+	return importIfReady(logger, mappings[0], config, state)
+}
+
+func runServeMode(logger *logrus.Logger, config *Config) error {
+	secret := config.WebhookSecret
+	if secret == "" {
+		secret = os.Getenv("PAD2GH_WEBHOOK_SECRET")
+	}
+	if secret == "" {
+		return fmt.Errorf("a webhook secret is required, use -webhook-secret or PAD2GH_WEBHOOK_SECRET")
+	}
+
+	state, err := loadWatchState(config.StateFilePath)
+	if err != nil {
+		return err
+	}
+
+	queue := newJobQueue(64)
+	mux := http.NewServeMux()
+	for _, source := range []string{"nextcloud", "pad", "github"} {
+		mux.HandleFunc("/hooks/"+source, webhookHandler(logger, queue, source, secret, config.PadBaseURL))
+	}
+	mux.HandleFunc("/jobs", requireBearerToken(secret, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, queue.snapshot())
+	}))
+	mux.HandleFunc("/jobs/", requireBearerToken(secret, func(w http.ResponseWriter, r *http.Request) {
+		job, exists := queue.get(strings.TrimPrefix(r.URL.Path, "/jobs/"))
+		if !exists {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such job"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}))
+
+	server := &http.Server{Addr: config.ListenAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// a single worker keeps imports sequential, so the state and content file are never written concurrently
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case date := <-queue.pending:
+				padURL := queue.start(date)
+				outcome, err := runImportJob(logger, config, state, date, padURL)
+				if err != nil {
+					logger.Errorf("Job for %s failed: %v", date, err)
+				} else {
+					logger.Infof("Job for %s: %s", date, outcome)
+				}
+				if err := queue.finish(date, outcome, err); err != nil {
+					logger.Warnf("%v, call the webhook again", err)
+				}
+			}
+		}
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Infof("Listening for webhooks on %s", config.ListenAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		stop()
+		<-workerDone
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down webhook server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	<-workerDone
+	return err
+}
+
+func webhookHandler(logger *logrus.Logger, queue *jobQueue, source, secret, padBaseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "only POST is supported"})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if !verifyWebhookSignature(secret, r, body) {
+			logger.Warnf("Rejected %s webhook from %s: invalid signature", source, r.RemoteAddr)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid signature"})
+			return
+		}
+
+		date, padURL, err := parseWebhook(source, r, body, padBaseURL)
+		if errors.Is(err, errIgnoredWebhook) {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		job, err := queue.enqueue(date, padURL, source)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		logger.Infof("Queued job for %s triggered by %s", date, source)
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"date":"2024-01-15"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	validSignature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected bool
+	}{
+		{name: "Valid GitHub signature", header: "X-Hub-Signature-256", value: validSignature, expected: true},
+		{name: "Valid generic signature", header: "X-Signature-256", value: validSignature, expected: true},
+		{name: "Wrong signature", header: "X-Signature-256", value: "sha256=00ff", expected: false},
+		{name: "Valid bearer token", header: "Authorization", value: "Bearer s3cret", expected: true},
+		{name: "Wrong bearer token", header: "Authorization", value: "Bearer guess", expected: false},
+		{name: "No signature", header: "", value: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/hooks/pad", strings.NewReader(string(body)))
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			if result := verifyWebhookSignature("s3cret", r, body); result != tt.expected {
+				t.Errorf("verifyWebhookSignature() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	padBaseURL := "https://pad.ccc-p.org/"
+
+	tests := []struct {
+		name       string
+		source     string
+		event      string
+		body       string
+		wantDate   string
+		wantPadURL string
+		wantErr    bool
+	}{
+		{
+			name:     "Nextcloud upload",
+			source:   "nextcloud",
+			body:     `{"event":{"node":{"path":"/radio/files/Upload/2024_01_15-chaos-im-radio.mp3"}}}`,
+			wantDate: "2024-01-15",
+		},
+		{
+			name:    "Nextcloud upload of other file",
+			source:  "nextcloud",
+			body:    `{"path":"/radio/files/Upload/notes.txt"}`,
+			wantErr: true,
+		},
+		{
+			name:       "Pad changed",
+			source:     "pad",
+			body:       `{"padURL":"https://pad.ccc-p.org/Radio_2024-02-12_test2"}`,
+			wantDate:   "2024-02-12",
+			wantPadURL: "https://pad.ccc-p.org/Radio_2024-02-12_test2",
+		},
+		{
+			name:    "Foreign pad",
+			source:  "pad",
+			body:    `{"padURL":"https://example.com/Radio_2024-02-12_test2"}`,
+			wantErr: true,
+		},
+		{
+			name:     "GitHub repository_dispatch",
+			source:   "github",
+			event:    "repository_dispatch",
+			body:     `{"action":"import","client_payload":{"date":"2024-03-11"}}`,
+			wantDate: "2024-03-11",
+		},
+		{
+			name:    "GitHub push event",
+			source:  "github",
+			event:   "push",
+			body:    `{}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/hooks/"+tt.source, strings.NewReader(tt.body))
+			r.Header.Set("X-GitHub-Event", tt.event)
+			date, padURL, err := parseWebhook(tt.source, r, []byte(tt.body), padBaseURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if date != tt.wantDate || padURL != tt.wantPadURL {
+				t.Errorf("parseWebhook() = %v, %v, want %v, %v", date, padURL, tt.wantDate, tt.wantPadURL)
+			}
+		})
+	}
+}
+
+func TestJobQueueDedupe(t *testing.T) {
+	queue := newJobQueue(8)
+
+	queue.enqueue("2024-01-15", "", "nextcloud") //nolint:errcheck
+	job, err := queue.enqueue("2024-01-15", "https://pad.ccc-p.org/Radio_2024-01-15_test1", "pad")
+	if err != nil {
+		t.Fatalf("enqueue() failed: %v", err)
+	}
+	if len(queue.pending) != 1 {
+		t.Errorf("Expected a burst to be queued once, got %d pending", len(queue.pending))
+	}
+	if len(job.Triggers) != 2 || job.PadURL == "" {
+		t.Errorf("Expected merged job with pad URL and two triggers, got %+v", job)
+	}
+
+	date := <-queue.pending
+	queue.start(date)
+	queue.enqueue("2024-01-15", "", "github") //nolint:errcheck
+	if len(queue.pending) != 0 {
+		t.Errorf("Expected no new job while running, got %d pending", len(queue.pending))
+	}
+
+	if err := queue.finish(date, "imported", nil); err != nil {
+		t.Fatalf("finish() failed: %v", err)
+	}
+	if len(queue.pending) != 1 {
+		t.Errorf("Expected one follow-up run after calls during the run, got %d pending", len(queue.pending))
+	}
+	if job, _ := queue.get(date); job.Status != "queued" {
+		t.Errorf("Expected follow-up job to be queued, got %s", job.Status)
+	}
+}
+
+func TestJobQueueLifetime(t *testing.T) {
+	queue := newJobQueue(1)
+
+	for i := 0; i < maxJobTriggers+5; i++ {
+		queue.enqueue("2024-01-15", "", "nextcloud") //nolint:errcheck
+	}
+	job, _ := queue.get("2024-01-15")
+	if len(job.Triggers) != maxJobTriggers || job.TriggerCount != maxJobTriggers+5 {
+		t.Errorf("Expected the last %d of %d triggers, got %d of %d", maxJobTriggers, maxJobTriggers+5, len(job.Triggers), job.TriggerCount)
+	}
+
+	// a call during the run can't be queued again while another date fills the queue
+	date := <-queue.pending
+	queue.start(date)
+	queue.enqueue(date, "", "pad")            //nolint:errcheck
+	queue.enqueue("2024-01-22", "", "github") //nolint:errcheck
+	if err := queue.finish(date, "imported", nil); err == nil {
+		t.Errorf("Expected the dropped follow-up run to be reported")
+	}
+	if job, _ := queue.get(date); job.Status != "done" || !strings.Contains(job.Outcome, "dropped") {
+		t.Errorf("Expected the dropped follow-up run in the outcome, got %s: %s", job.Status, job.Outcome)
+	}
+
+	// finished jobs are forgotten after jobRetention, at most maxFinishedJobs are kept
+	queue = newJobQueue(maxFinishedJobs + 10)
+	now := time.Now()
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		queue.jobs[date] = &webhookJob{Date: date, Status: "done", Updated: now.Add(-time.Duration(i) * time.Minute)}
+	}
+	queue.jobs["2023-01-02"] = &webhookJob{Date: "2023-01-02", Status: "failed", Updated: now.Add(-jobRetention - time.Minute)}
+	queue.jobs["2023-01-09"] = &webhookJob{Date: "2023-01-09", Status: "queued", Updated: now.Add(-jobRetention - time.Minute)}
+	queue.prune(now)
+	if len(queue.jobs) != maxFinishedJobs+1 {
+		t.Errorf("Expected %d finished jobs and the queued one, got %d", maxFinishedJobs, len(queue.jobs))
+	}
+	if _, exists := queue.get("2023-01-09"); !exists {
+		t.Errorf("Expected the queued job to be kept")
+	}
+	if _, exists := queue.get(now.Format("2006-01-02")); !exists {
+		t.Errorf("Expected the newest finished job to be kept")
+	}
+}
+
+func TestJobsRequireToken(t *testing.T) {
+	handler := requireBearerToken("s3cret", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []webhookJob{})
+	})
+	for token, expected := range map[string]int{"": http.StatusUnauthorized, "guess": http.StatusUnauthorized, "s3cret": http.StatusOK} {
+		r := httptest.NewRequest("GET", "/jobs", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != expected {
+			t.Errorf("GET /jobs with token %q = %d, expected %d", token, w.Code, expected)
+		}
+	}
+}
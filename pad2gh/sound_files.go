@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// audioExtensions are the file extensions treated as episode audio
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".opus": true,
+	".ogg":  true,
+	".m4a":  true,
+}
+
+// isAudioFile reports whether the file name has an audio extension, ignoring the case
+func isAudioFile(name string) bool {
+	return audioExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// looseDateRegex finds a date in a file name regardless of the separators used
+var looseDateRegex = regexp.MustCompile(`(\d{4})[-_.]?(\d{2})[-_.]?(\d{2})`)
+
+// looseDateFromFileName extracts a date in the format YYYY-MM-DD from any file name containing one
+func looseDateFromFileName(name string) (string, bool) {
+	matches := looseDateRegex.FindStringSubmatch(path.Base(name))
+	if len(matches) < 4 {
+		return "", false
+	}
+	return fmt.Sprintf("%s-%s-%s", matches[1], matches[2], matches[3]), true
+}
+
+// listSoundFiles enumerates the file names in the sound file directory: the local -sound-dir,
+// or with -file-online the WebDAV collection (-webdav) or HTTP autoindex at -file-base-url
+func listSoundFiles(config *Config) ([]string, error) {
+	var names []string
+	var err error
+	switch {
+	case config.FileOnline && config.WebDAV:
+		names, err = listSoundFilesWebDAV(config.FileBaseURL)
+	case config.FileOnline:
+		names, err = listSoundFilesAutoindex(config.FileBaseURL)
+	case config.SoundDir != "":
+		names, err = listSoundFilesLocally(config.SoundDir)
+	default:
+		return nil, fmt.Errorf("either -sound-dir or -file-online is needed to list sound files")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func listSoundFilesLocally(soundFileDir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(soundFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sound file directory: %v", err)
+	}
+	var names []string
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			names = append(names, dirEntry.Name())
+		}
+	}
+	return names, nil
+}
+
+var hrefRegex = regexp.MustCompile(`href="([^"?#]+)"`)
+
+// listSoundFilesAutoindex parses the directory listing generated by nginx/apache autoindex
+func listSoundFilesAutoindex(fileBaseURL string) ([]string, error) {
+	resp, err := http.Get(fileBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status code %d", fileBaseURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseAutoindex(string(body)), nil
+}
+
+func parseAutoindex(body string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range hrefRegex.FindAllStringSubmatch(body, -1) {
+		href := match[1]
+		// skip parent and sub directories as well as absolute links
+		if strings.HasSuffix(href, "/") || strings.Contains(href, "://") {
+			continue
+		}
+		name, err := url.PathUnescape(path.Base(href))
+		if err != nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href string `xml:"href"`
+	} `xml:"response"`
+}
+
+// listSoundFilesWebDAV lists a WebDAV collection with PROPFIND, credentials are taken
+// from $PAD2GH_WEBDAV_USER and $PAD2GH_WEBDAV_PASSWORD
+func listSoundFilesWebDAV(fileBaseURL string) ([]string, error) {
+	req, err := http.NewRequest("PROPFIND", fileBaseURL, strings.NewReader(`<?xml version="1.0"?><d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/></d:prop></d:propfind>`))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	if user := os.Getenv("PAD2GH_WEBDAV_USER"); user != "" {
+		req.SetBasicAuth(user, os.Getenv("PAD2GH_WEBDAV_PASSWORD"))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("PROPFIND %s returned status code %d", fileBaseURL, resp.StatusCode)
+	}
+
+	var multistatus davMultistatus
+	err = xml.NewDecoder(resp.Body).Decode(&multistatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PROPFIND response: %v", err)
+	}
+
+	var names []string
+	for _, response := range multistatus.Responses {
+		// the collection itself and sub collections end with a slash
+		if strings.HasSuffix(response.Href, "/") {
+			continue
+		}
+		name, err := url.PathUnescape(path.Base(response.Href))
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestParseAutoindex(t *testing.T) {
+	body := `<html><body><h1>Index of /files/</h1><hr><pre><a href="../">../</a>
+<a href="old/">old/</a>
+<a href="2024_01_15-chaos-im-radio.mp3">2024_01_15-chaos-im-radio.mp3</a>       15-Jan-2024 20:00    52M
+<a href="Sendung%20Mai.mp3">Sendung Mai.mp3</a>       15-May-2024 20:00    50M
+<a href="?C=N;O=D">Name</a>
+</pre><hr></body></html>`
+
+	names := parseAutoindex(body)
+	if len(names) != 2 || names[0] != "2024_01_15-chaos-im-radio.mp3" || names[1] != "Sendung Mai.mp3" {
+		t.Errorf("parseAutoindex() = %v", names)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+)
+
+// germanStem reduces a lower case German word to its stem with the Snowball German stemmer, so that
+// "Zeichen" and "Zeichens" or "Ladegerät" and "Ladegeräten" don't need to be searched for separately
+func germanStem(word string) string {
+	word = strings.ReplaceAll(word, "ß", "ss")
+	w := []rune(word)
+
+	// u and y between vowels are consonants
+	for i := 1; i < len(w)-1; i++ {
+		if isGermanVowel(w[i-1]) && isGermanVowel(w[i+1]) {
+			switch w[i] {
+			case 'u':
+				w[i] = 'U'
+			case 'y':
+				w[i] = 'Y'
+			}
+		}
+	}
+
+	r1 := germanRegion(w, 0)
+	r2 := germanRegion(w, r1)
+	// the part before R1 has at least three letters
+	if r1 < 3 {
+		r1 = 3
+	}
+
+	// step 1, the longest of the suffixes is only removed in R1
+	switch suffix := longestSuffix(w, "ern", "em", "er", "en", "es", "e", "s"); {
+	case suffix == "" || len(w)-len([]rune(suffix)) < r1:
+	case suffix == "s":
+		if len(w) > 1 && strings.ContainsRune("bdfghklmnrt", w[len(w)-2]) {
+			w = w[:len(w)-1]
+		}
+	case suffix == "en" || suffix == "es" || suffix == "e":
+		w = trimSuffix(w, suffix)
+		if hasSuffix(w, "niss") {
+			w = w[:len(w)-1]
+		}
+	default:
+		w = trimSuffix(w, suffix)
+	}
+
+	// step 2
+	switch suffix := longestSuffix(w, "est", "en", "er", "st"); {
+	case suffix == "" || len(w)-len([]rune(suffix)) < r1:
+	case suffix == "st":
+		// preceded by a valid st-ending, itself preceded by at least three letters
+		if len(w) > 5 && strings.ContainsRune("bdfghklmnt", w[len(w)-3]) {
+			w = w[:len(w)-2]
+		}
+	default:
+		w = trimSuffix(w, suffix)
+	}
+
+	// step 3, derivational suffixes only in R2
+	switch suffix := longestSuffix(w, "isch", "lich", "heit", "keit", "end", "ung", "ig", "ik"); {
+	case suffix == "" || len(w)-len([]rune(suffix)) < r2:
+	case suffix == "end" || suffix == "ung":
+		w = trimSuffix(w, suffix)
+		if hasSuffixIn(w, r2, "ig") && !hasSuffix(w[:len(w)-2], "e") {
+			w = w[:len(w)-2]
+		}
+	case suffix == "isch" || suffix == "ig" || suffix == "ik":
+		if !hasSuffix(trimSuffix(w, suffix), "e") {
+			w = trimSuffix(w, suffix)
+		}
+	case suffix == "lich" || suffix == "heit":
+		w = trimSuffix(w, suffix)
+		if hasSuffixIn(w, r1, "er") || hasSuffixIn(w, r1, "en") {
+			w = w[:len(w)-2]
+		}
+	case suffix == "keit":
+		w = trimSuffix(w, suffix)
+		if hasSuffixIn(w, r2, "lich") {
+			w = w[:len(w)-4]
+		} else if hasSuffixIn(w, r2, "ig") {
+			w = w[:len(w)-2]
+		}
+	}
+
+	stem := strings.NewReplacer("U", "u", "Y", "y", "ä", "a", "ö", "o", "ü", "u").Replace(string(w))
+	if stem == "" {
+		return word
+	}
+	return stem
+}
+
+func isGermanVowel(r rune) bool {
+	return strings.ContainsRune("aeiouyäöü", r)
+}
+
+// germanRegion returns the start of the region after the first non-vowel following a vowel, starting at from
+func germanRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isGermanVowel(w[i]) && isGermanVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func hasSuffix(w []rune, suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+// hasSuffixIn tells whether w ends with suffix and the suffix starts at or after the region start
+func hasSuffixIn(w []rune, region int, suffix string) bool {
+	return hasSuffix(w, suffix) && len(w)-len([]rune(suffix)) >= region
+}
+
+// longestSuffix returns the first of the suffixes, ordered longest first, that w ends with
+func longestSuffix(w []rune, suffixes ...string) string {
+	for _, suffix := range suffixes {
+		if hasSuffix(w, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+func trimSuffix(w []rune, suffix string) []rune {
+	return w[:len(w)-len([]rune(suffix))]
+}
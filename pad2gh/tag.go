@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Metadata embedded into every episode, matching the ffmpeg call in the main README
+const (
+	id3Album     = "Chaos im Radio"
+	id3Artist    = "Chaostreff Potsdam"
+	id3Genre     = "Podcast"
+	id3CoverDesc = "Cover (front)"
+)
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// buildID3Tag creates the tag for an entry, duration is used as end of the last chapter
+func buildID3Tag(entry *CiREntry, cover []byte, duration time.Duration, version byte) (*ID3Tag, error) {
+	date, err := parseEntryDate(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &ID3Tag{
+		Version: version,
+		Text: map[string]string{
+			"TIT2": entry.Title,
+			"TALB": id3Album,
+			"TPE1": id3Artist,
+			"TPUB": id3Artist,
+			"TCON": id3Genre,
+		},
+	}
+	if version >= 4 {
+		tag.Text["TDRC"] = date.Format("2006-01-02")
+	} else {
+		// ID3v2.3 has no TDRC, the date is split into year and day/month
+		tag.Text["TYER"] = date.Format("2006")
+		tag.Text["TDAT"] = date.Format("0201")
+	}
+
+	if len(cover) > 0 {
+		mimeType := http.DetectContentType(cover)
+		if mimeType != "image/jpeg" && mimeType != "image/png" {
+			return nil, fmt.Errorf("the cover is %s, not JPEG or PNG", mimeType)
+		}
+		tag.Pictures = []ID3Picture{{MimeType: mimeType, PictureType: 3, Description: id3CoverDesc, Data: cover}}
+	}
+
+	for i, chapter := range entry.Chapters {
+		start, err := parseChapterStart(chapter.Start)
+		if err != nil {
+			return nil, err
+		}
+		end := duration
+		if i+1 < len(entry.Chapters) {
+			end, err = parseChapterStart(entry.Chapters[i+1].Start)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("chapter %q ends before it starts", chapter.Title)
+		}
+		tag.Chapters = append(tag.Chapters, ID3Chapter{
+			ElementID: fmt.Sprintf("chp%d", i),
+			Start:     start,
+			End:       end,
+			Title:     chapter.Title,
+			URL:       chapter.Href,
+		})
+	}
+	if len(tag.Chapters) > 0 {
+		tag.TOCTitle = entry.Title
+	}
+	return tag, nil
+}
+
+// id3DateFrames are the date frames of both versions, buildID3Tag writes the ones of its version
+var id3DateFrames = map[string]bool{"TYER": true, "TDAT": true, "TIME": true, "TRDA": true, "TORY": true, "TDRC": true, "TDOR": true, "TDRL": true}
+
+// keepExistingFrames carries the frames of the tag already in the file over to the new tag: the text frames
+// buildID3Tag doesn't set, the other pictures and all other frames. Frames pad2gh doesn't interpret can't be converted
+// between ID3 versions, so they are dropped when the version changes and their IDs are returned.
+func keepExistingFrames(tag, existing *ID3Tag) []string {
+	for id, text := range existing.Text {
+		if _, set := tag.Text[id]; !set && !id3DateFrames[id] {
+			tag.Text[id] = text
+		}
+	}
+	for _, picture := range existing.Pictures {
+		if picture.PictureType != 3 || len(tag.Pictures) == 0 {
+			tag.Pictures = append(tag.Pictures, picture)
+		}
+	}
+	var dropped []string
+	for _, frame := range existing.Frames {
+		if existing.Version != tag.Version {
+			dropped = append(dropped, frame.ID)
+			continue
+		}
+		tag.Frames = append(tag.Frames, frame)
+	}
+	return dropped
+}
+
+// updateTaggedAudio stores size and digest of the retagged file in its audio entry, so the enclosure length of the
+// feed and verify-media match the file again. It returns false if the file isn't one of the entry.
+func updateTaggedAudio(entry *CiREntry, soundFilePath string) (bool, error) {
+	for i := range entry.Audio {
+		audio := &entry.Audio[i]
+		if soundFileNameFromAudioURL(audio.Url) != filepath.Base(soundFilePath) {
+			continue
+		}
+		digest, size, err := fileDigest(soundFilePath)
+		if err != nil {
+			return false, err
+		}
+		audio.Size = size
+		audio.SHA256 = digest
+		return true, nil
+	}
+	return false, nil
+}
+
+// compareID3Tags lists the differences between the expected tag and the tag read from a file
+func compareID3Tags(expected, actual *ID3Tag) []string {
+	var differences []string
+	for _, id := range sortedKeys(expected.Text) {
+		if actual.Text[id] != expected.Text[id] {
+			differences = append(differences, fmt.Sprintf("%s is %q, expected %q", id, actual.Text[id], expected.Text[id]))
+		}
+	}
+	if len(expected.Pictures) > 0 && len(actual.Pictures) == 0 {
+		differences = append(differences, "cover missing")
+	}
+	if len(actual.Chapters) != len(expected.Chapters) {
+		differences = append(differences, fmt.Sprintf("%d chapters, expected %d", len(actual.Chapters), len(expected.Chapters)))
+		return differences
+	}
+	for i, chapter := range expected.Chapters {
+		got := actual.Chapters[i]
+		if got.Start != chapter.Start || got.Title != chapter.Title || got.URL != chapter.URL {
+			differences = append(differences, fmt.Sprintf("chapter %d is %s %q, expected %s %q", i+1, formatDuration(got.Start), got.Title, formatDuration(chapter.Start), chapter.Title))
+		}
+	}
+	return differences
+}
+
+// findEntryByUUID reads the YAML file and returns the entry with the given UUID
+func findEntryByUUID(contentFilePath, uuid string) (*CiREntry, error) {
+	if uuid == "" {
+		return nil, fmt.Errorf("-entry <uuid> is required")
+	}
+	entries, err := readYAMLEntries(contentFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read existing entries: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.UUID == uuid {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("no entry %s in %s", uuid, contentFilePath)
+}
+
+// entrySoundFilePath returns the file given on the command line or the entry's MP3 in -sound-dir
+func entrySoundFilePath(entry *CiREntry, config *Config) (string, error) {
+	if len(config.Args) > 0 {
+		return config.Args[0], nil
+	}
+	audio := entryMP3(entry)
+	if config.SoundDir == "" || audio == nil {
+		return "", fmt.Errorf("specify the sound file or -sound-dir")
+	}
+	return filepath.Join(config.SoundDir, soundFileNameFromAudioURL(audio.Url)), nil
+}
+
+func runTagCommand(logger *logrus.Logger, config *Config) error {
+	entry, err := findEntryByUUID(config.ContentFilePath, config.EntryUUID)
+	if err != nil {
+		return err
+	}
+	soundFilePath, err := entrySoundFilePath(entry, config)
+	if err != nil {
+		return err
+	}
+	if config.ID3Version != 3 && config.ID3Version != 4 {
+		return fmt.Errorf("-id3-version must be 3 or 4")
+	}
+
+	var cover []byte
+	if coverPath := entryCoverPath(entry, config); coverPath != "" {
+		cover, err = os.ReadFile(coverPath)
+		if err != nil {
+			return fmt.Errorf("failed to read cover: %v", err)
+		}
+	}
+
+	probe, err := probeLocalAudio(soundFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to probe %s: %v", soundFilePath, err)
+	}
+
+	tag, err := buildID3Tag(entry, cover, probe.Duration, byte(config.ID3Version))
+	if err != nil {
+		return err
+	}
+	if existing, err := readID3Tag(soundFilePath); err == nil {
+		if dropped := keepExistingFrames(tag, existing); len(dropped) > 0 {
+			logger.Warnf("Dropping the frames %v of the ID3v2.%d tag, they can't be converted to ID3v2.%d", dropped, existing.Version, tag.Version)
+		}
+	}
+	err = writeID3Tag(soundFilePath, tag)
+	if err != nil {
+		return fmt.Errorf("failed to write tag to %s: %v", soundFilePath, err)
+	}
+
+	written, err := readID3Tag(soundFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to re-read tag of %s: %v", soundFilePath, err)
+	}
+	if differences := compareID3Tags(tag, written); len(differences) > 0 {
+		return fmt.Errorf("tag of %s differs after writing: %v", soundFilePath, differences)
+	}
+
+	logger.Infof("Tagged %s with %d chapters as ID3v2.%d", soundFilePath, len(tag.Chapters), tag.Version)
+
+	updated, err := updateTaggedAudio(entry, soundFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %v", soundFilePath, err)
+	}
+	if !updated {
+		logger.Warnf("%s is not a file of %s, update size and digest once it is published", filepath.Base(soundFilePath), entry.UUID)
+		return nil
+	}
+	return replaceYAMLEntry(entry, config.ContentFilePath)
+}
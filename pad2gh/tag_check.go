@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TagCheckResult lists the differences between the embedded tags of a sound file and its YAML entry
+type TagCheckResult struct {
+	UUID      string   `json:"uuid"`
+	SoundFile string   `json:"soundFile"`
+	Problems  []string `json:"problems"`
+}
+
+// checkEntryTag compares title, date, chapters and cover of a tag with the YAML entry
+func checkEntryTag(entry *CiREntry, tag *ID3Tag) []string {
+	var problems []string
+
+	if tag.Text["TIT2"] != entry.Title {
+		problems = append(problems, fmt.Sprintf("title is %q, expected %q", tag.Text["TIT2"], entry.Title))
+	}
+
+	if date, err := parseEntryDate(entry); err == nil {
+		// older files only carry the year, so only the parts that are present are compared
+		if tdrc := tag.Text["TDRC"]; tdrc != "" {
+			expected := date.Format("2006-01-02")
+			if !strings.HasPrefix(expected, tdrc) && !strings.HasPrefix(tdrc, expected) {
+				problems = append(problems, fmt.Sprintf("date is %s, expected %s", tdrc, expected))
+			}
+		} else if tyer := tag.Text["TYER"]; tyer != "" {
+			if tyer != date.Format("2006") {
+				problems = append(problems, fmt.Sprintf("year is %s, expected %s", tyer, date.Format("2006")))
+			}
+			if tdat := tag.Text["TDAT"]; tdat != "" && tdat != date.Format("0201") {
+				problems = append(problems, fmt.Sprintf("day/month is %s, expected %s", tdat, date.Format("0201")))
+			}
+		} else {
+			problems = append(problems, "no date")
+		}
+	}
+
+	if len(tag.Pictures) == 0 {
+		problems = append(problems, "no cover")
+	}
+
+	if len(tag.Chapters) != len(entry.Chapters) {
+		problems = append(problems, fmt.Sprintf("%d chapters, expected %d", len(tag.Chapters), len(entry.Chapters)))
+		return problems
+	}
+	for i, chapter := range entry.Chapters {
+		start, err := parseChapterStart(chapter.Start)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		got := tag.Chapters[i]
+		if diff := got.Start - start; diff < -time.Millisecond || diff > time.Millisecond {
+			problems = append(problems, fmt.Sprintf("chapter %d starts at %s, expected %s", i+1, formatDuration(got.Start), formatDuration(start)))
+		}
+		if got.Title != chapter.Title {
+			problems = append(problems, fmt.Sprintf("chapter %d is titled %q, expected %q", i+1, got.Title, chapter.Title))
+		}
+	}
+	return problems
+}
+
+// readSoundFileTag reads the tag from -sound-dir if the file exists there, otherwise online with -file-online
+func readSoundFileTag(fileName string, config *Config) (*ID3Tag, error) {
+	if config.SoundDir != "" {
+		localPath := filepath.Join(config.SoundDir, fileName)
+		if _, err := os.Stat(localPath); err == nil {
+			return readID3Tag(localPath)
+		}
+	}
+	if config.FileOnline {
+		fileURL, err := url.JoinPath(config.FileBaseURL, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct file URL: %v", err)
+		}
+		return readID3TagAt(httpRangeReader{url: fileURL})
+	}
+	return nil, fmt.Errorf("sound file %s not found", fileName)
+}
+
+func writeTagCheckMarkdown(w io.Writer, results []TagCheckResult) error {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("## Sound files with mismatching tags (%d)\n", len(results)))
+	for _, result := range results {
+		b.WriteString(fmt.Sprintf("\n### %s (%s)\n\n", result.UUID, result.SoundFile))
+		for _, problem := range result.Problems {
+			b.WriteString(fmt.Sprintf("* %s\n", problem))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// runVerifyTagsCommand compares the embedded tags of all (or one with -entry) episodes with content.yaml
+func runVerifyTagsCommand(logger *logrus.Logger, config *Config) error {
+	err := checkFormat("verify-tags", config.Format, "md", "json")
+	if err != nil {
+		return err
+	}
+	if config.SoundDir == "" && !config.FileOnline {
+		return fmt.Errorf("verify-tags needs -sound-dir or -file-online")
+	}
+
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	results := []TagCheckResult{}
+	checked := 0
+	for _, entry := range entries {
+		if config.EntryUUID != "" && entry.UUID != config.EntryUUID {
+			continue
+		}
+		audio := entryMP3(entry)
+		if audio == nil {
+			continue
+		}
+		fileName := soundFileNameFromAudioURL(audio.Url)
+		tag, err := readSoundFileTag(fileName, config)
+		checked++
+		if err != nil {
+			results = append(results, TagCheckResult{UUID: entry.UUID, SoundFile: fileName, Problems: []string{err.Error()}})
+			continue
+		}
+		if problems := checkEntryTag(entry, tag); len(problems) > 0 {
+			results = append(results, TagCheckResult{UUID: entry.UUID, SoundFile: fileName, Problems: problems})
+		}
+	}
+	logger.Infof("Checked %d sound files, %d with mismatching tags", checked, len(results))
+
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if config.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(results)
+	} else {
+		err = writeTagCheckMarkdown(out, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(results) > 0 {
+		return fmt.Errorf("%d sound files have tags not matching %s", len(results), config.ContentFilePath)
+	}
+	return nil
+}
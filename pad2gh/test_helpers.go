@@ -47,7 +47,7 @@ func createMockEntry(padURL, date string) *CiREntry {
 		PublicationDate: fmt.Sprintf("%s-%s-%sT00:00:00+02:00", year, month, day),
 		Audio: []CiRaudio{{
 			Url:      fmt.Sprintf("$media_base_url/%s_%s_%s-chaos-im-radio.mp3", year, month, day),
-			MimeType: "audio/mp3",
+			MimeType: "audio/mpeg",
 		}},
 		Chapters: []CiRChapter{
 			{Start: "00:00:00", Title: "Mock Introduction"},
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// the keywords of a shownote topic are looked for this long after the first one is said
+	topicWindow = 90 * time.Second
+	// a pause in the speech this long is one of the tracks of the mukke section
+	minMusicGap = 60 * time.Second
+	// longer shownote lines are shortened for the chapter title
+	maxTopicTitleLength = 80
+)
+
+// shownoteTopics returns the shownote lines of the pad as plain text, the music list is left out
+func shownoteTopics(entry *CiREntry) []string {
+	var topics []string
+	for _, line := range strings.Split(entry.LongSummaryMD, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "**Musik:**" {
+			break
+		}
+		if line == "" || line == "**Shownotes:**" {
+			continue
+		}
+		line = strings.TrimLeft(line, "*-+ ")
+		topic := strings.Join(strings.Fields(plainShownotes(line)), " ")
+		if topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// topicTitle shortens a shownote line to a chapter title
+func topicTitle(topic string) string {
+	runes := []rune(topic)
+	if len(runes) <= maxTopicTitleLength {
+		return topic
+	}
+	return strings.TrimSpace(string(runes[:maxTopicTitleLength-1])) + "…"
+}
+
+// speechCues drops the cues whisper writes over music and silence
+func speechCues(cues []TranscriptCue, rules *TranscriptRules) []TranscriptCue {
+	var speech []TranscriptCue
+	lastKey := ""
+	for _, cue := range cues {
+		key := textKey(cue.Text)
+		if key == "" || key == "musik" || key == lastKey || rules.hallucinated(key) {
+			continue
+		}
+		lastKey = key
+		speech = append(speech, cue)
+	}
+	return speech
+}
+
+// transcriptSegments turns the pauses in the speech into music segments, like segmentSoundFile does from the audio
+func transcriptSegments(cues []TranscriptCue, duration time.Duration) []AudioSegment {
+	var segments []AudioSegment
+	add := func(start, end time.Duration, music bool) {
+		if n := len(segments); n > 0 && segments[n-1].Music == music {
+			segments[n-1].End = end
+			return
+		}
+		segments = append(segments, AudioSegment{Start: start, End: end, Music: music})
+	}
+
+	position := time.Duration(0)
+	for _, cue := range cues {
+		start := position
+		if cue.Start-position >= minMusicGap {
+			add(position, cue.Start, true)
+			start = cue.Start
+		}
+		add(start, cue.End, false)
+		if cue.End > position {
+			position = cue.End
+		}
+	}
+	if duration-position >= minMusicGap {
+		add(position, duration, true)
+	}
+	return segments
+}
+
+// alignTopics finds the cue where each topic starts. The topics are discussed in the order of the shownotes, so the
+// starts increase, and every start is a cue mentioning one of the keywords of the topic, followed by more of them.
+// Rare keywords count more than words said all the time. Topics without any match get -1.
+func alignTopics(cues []TranscriptCue, topics []string) []int {
+	cueTerms := make([]map[string]bool, len(cues))
+	documentFrequency := map[string]int{}
+	for j, cue := range cues {
+		cueTerms[j] = map[string]bool{}
+		for _, term := range searchTerms(cue.Text) {
+			if !cueTerms[j][term] {
+				cueTerms[j][term] = true
+				documentFrequency[term]++
+			}
+		}
+	}
+	weight := func(term string) float64 {
+		return math.Log(1 + float64(len(cues))/float64(documentFrequency[term]))
+	}
+
+	// score[i][j] is the weight of the keywords of topic i said in the window starting at cue j
+	score := make([][]float64, len(topics))
+	for i, topic := range topics {
+		score[i] = make([]float64, len(cues))
+		keywords := map[string]bool{}
+		for _, term := range searchTerms(topic) {
+			keywords[term] = true
+		}
+		for j := range cues {
+			mentioned := false
+			for term := range cueTerms[j] {
+				mentioned = mentioned || keywords[term]
+			}
+			if !mentioned {
+				continue
+			}
+			found := map[string]bool{}
+			for k := j; k < len(cues) && cues[k].Start < cues[j].Start+topicWindow; k++ {
+				for term := range cueTerms[k] {
+					if keywords[term] && !found[term] {
+						found[term] = true
+						score[i][j] += weight(term)
+					}
+				}
+			}
+		}
+	}
+
+	// best[p] is the best total with the last placed topic at cue p-1, p = 0 if none is placed yet
+	best := make([]float64, len(cues)+1)
+	for p := 1; p < len(best); p++ {
+		best[p] = math.Inf(-1)
+	}
+	from := make([][]int, len(topics))
+	placed := make([][]bool, len(topics))
+	for i := range topics {
+		current := append([]float64(nil), best...)
+		from[i] = make([]int, len(best))
+		placed[i] = make([]bool, len(best))
+		for p := range from[i] {
+			// skipping the topic keeps the position
+			from[i][p] = p
+		}
+		bestBefore, bestBeforeAt := best[0], 0
+		for j := range cues {
+			if score[i][j] > 0 && bestBefore+score[i][j] > current[j+1] {
+				current[j+1] = bestBefore + score[i][j]
+				from[i][j+1] = bestBeforeAt
+				placed[i][j+1] = true
+			}
+			if best[j+1] > bestBefore {
+				bestBefore, bestBeforeAt = best[j+1], j+1
+			}
+		}
+		best = current
+	}
+
+	starts := make([]int, len(topics))
+	p := 0
+	for q := range best {
+		if best[q] > best[p] {
+			p = q
+		}
+	}
+	for i := len(topics) - 1; i >= 0; i-- {
+		starts[i] = -1
+		if placed[i][p] {
+			starts[i] = p - 1
+		}
+		p = from[i][p]
+	}
+	return starts
+}
+
+// suggestTranscriptChapters proposes a chapter for every shownote topic where it is talked about and places the
+// tracks of the mukke section in the pauses of the speech
+func suggestTranscriptChapters(cues []TranscriptCue, duration time.Duration, topics []string, tracks []MusicTrack) ([]CiRChapter, []string) {
+	music, notes := suggestChapters(transcriptSegments(cues, duration), tracks)
+
+	chapters := []CiRChapter{{Start: "00:00:00", Title: "Begrüßung"}}
+	for _, chapter := range music {
+		if strings.HasPrefix(chapter.Title, "Musik") {
+			chapters = append(chapters, chapter)
+		}
+	}
+	for i, start := range alignTopics(cues, topics) {
+		if start < 0 {
+			notes = append(notes, fmt.Sprintf("%q is not mentioned in the transcript", topicTitle(topics[i])))
+			continue
+		}
+		chapters = append(chapters, CiRChapter{Start: formatChapterStart(cues[start].Start.Round(time.Second)), Title: topicTitle(topics[i])})
+	}
+
+	// the times have the same format, so they sort as text
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].Start < chapters[j].Start })
+	result := []CiRChapter{}
+	for _, chapter := range chapters {
+		if n := len(result); n > 0 && result[n-1].Start == chapter.Start {
+			// a topic or track right at the start replaces the greeting
+			if result[n-1].Title == "Begrüßung" {
+				result[n-1] = chapter
+			}
+			continue
+		}
+		result = append(result, chapter)
+	}
+	return result, notes
+}
+
+// suggestEntryChaptersFromTranscript aligns the shownotes with the whisper output in -whisper-dir, it returns
+// false if there is no transcript or nothing to align
+func suggestEntryChaptersFromTranscript(entry *CiREntry, config *Config) bool {
+	topics := shownoteTopics(entry)
+	if config.WhisperDir == "" || len(topics) == 0 {
+		return false
+	}
+	source := findWhisperOutput(config.WhisperDir, entry)
+	if source == "" {
+		return false
+	}
+	rules, err := loadTranscriptRules(config.TranscriptRulesPath)
+	if err != nil {
+		entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("could not suggest chapters from the transcript: %v", err))
+		return false
+	}
+	cues, _, err := readWhisperOutput(source)
+	if err != nil {
+		entry.processingWarnings = append(entry.processingWarnings, fmt.Sprintf("could not suggest chapters from the transcript: %v", err))
+		return false
+	}
+	cues = speechCues(cues, rules)
+	if len(cues) == 0 {
+		return false
+	}
+
+	var duration time.Duration
+	if audio := entryMP3(entry); audio != nil && audio.Duration != "" {
+		duration, _ = parseChapterStart(audio.Duration)
+	}
+	chapters, notes := suggestTranscriptChapters(cues, duration, topics, musicTracks(entry))
+	entry.suggestedChapters = chapters
+	for _, note := range notes {
+		entry.processingWarnings = append(entry.processingWarnings, "chapter suggestion: "+note)
+	}
+	return true
+}
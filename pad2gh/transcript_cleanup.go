@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// redactionMarker replaces everything redacted from a transcript
+	redactionMarker = "[…]"
+
+	// merged cues end at a sentence, but don't get longer than this
+	maxCueDuration = 10 * time.Second
+	maxCueLength   = 200
+	// a longer pause, e.g. for music, always starts a new cue
+	maxCueGap = 2 * time.Second
+
+	// a phrase of up to maxRepeatedPhraseWords words said minRepeats times in a row is a hallucination
+	maxRepeatedPhraseWords = 6
+	minRepeats             = 3
+)
+
+// defaultGlossary are the domain terms whisper gets wrong, with the ways it writes them
+var defaultGlossary = map[string][]string{
+	"Chaostreff":            {"Chaos Treff", "Chaos-Treff", "Kaostreff", "Kaos Treff"},
+	"CCC":                   {"C C C", "C.C.C."},
+	"CCC-P":                 {"CCC P", "CCCP", "C C C P"},
+	"Chaos im Radio":        {"Kaos im Radio", "Chaos am Radio"},
+	"Freies Radio Potsdam":  {"freies Radio Potsdam", "Freiesradio Potsdam"},
+	"Chaos Computer Club":   {"Chaos-Computer-Club", "Kaos Computer Club"},
+	"Podcasting 2.0":        {"Podcasting 2 0", "Podcasting zwei null"},
+	"Open Health HACKademy": {"Open Health Hackademy", "Open Health Akademie"},
+}
+
+// defaultHallucinations are the lines whisper writes over music and silence, from the subtitles it was trained on
+var defaultHallucinations = []string{
+	"Untertitel im Auftrag des ZDF",
+	"Untertitelung im Auftrag des ZDF",
+	"Untertitelung des ZDF",
+	"Untertitel der Amara.org-Community",
+	"Untertitel von Stephanie Geiges",
+	"Vielen Dank fürs Zuschauen",
+	"Copyright WDR",
+}
+
+var (
+	emailRegex = regexp.MustCompile(`[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)*\.\p{L}{2,}`)
+	phoneRegex = regexp.MustCompile(`(?:\+\d{1,3}|\b0)[\d /-]{5,}\d`)
+)
+
+// TranscriptRules are the corrections applied to every transcript, read from -transcript-rules
+type TranscriptRules struct {
+	Redact   []string            `yaml:"redact"`   // names, phone numbers and addresses said on air which must not be published
+	Glossary map[string][]string `yaml:"glossary"` // domain term: the ways whisper writes it
+	Drop     []string            `yaml:"drop"`     // lines whisper hallucinates, matched at the start of a cue
+
+	redactRegex   *regexp.Regexp
+	glossaryRegex *regexp.Regexp
+	glossaryTerms map[string]string
+	dropKeys      []string
+}
+
+// loadTranscriptRules reads the rules file and adds the default glossary and hallucinations, the file is optional
+func loadTranscriptRules(filePath string) (*TranscriptRules, error) {
+	rules := &TranscriptRules{}
+	content, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	if err == nil {
+		err = yaml.Unmarshal(content, rules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transcript rules %s: %v", filePath, err)
+		}
+	} else {
+		logrus.Debugf("No transcript rules at %s, using the defaults", filePath)
+	}
+
+	if rules.Glossary == nil {
+		rules.Glossary = map[string][]string{}
+	}
+	for term, variants := range defaultGlossary {
+		rules.Glossary[term] = append(rules.Glossary[term], variants...)
+	}
+	rules.Drop = append(rules.Drop, defaultHallucinations...)
+	rules.compile()
+	return rules, nil
+}
+
+// compile builds the matchers, the rules can also be set up by hand in the tests
+func (rules *TranscriptRules) compile() {
+	rules.redactRegex = phraseRegex(rules.Redact)
+
+	rules.glossaryTerms = map[string]string{}
+	var phrases []string
+	for term, variants := range rules.Glossary {
+		// the term itself gets its spelling fixed, e.g. "ccc"
+		for _, phrase := range append([]string{term}, variants...) {
+			rules.glossaryTerms[phraseKey(phrase)] = term
+			phrases = append(phrases, phrase)
+		}
+	}
+	rules.glossaryRegex = phraseRegex(phrases)
+
+	rules.dropKeys = nil
+	for _, line := range rules.Drop {
+		rules.dropKeys = append(rules.dropKeys, textKey(line))
+	}
+}
+
+// phraseRegex matches any of the phrases ignoring case and spacing, the longest first
+func phraseRegex(phrases []string) *regexp.Regexp {
+	var patterns []string
+	for _, phrase := range phrases {
+		words := strings.Fields(phrase)
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		patterns = append(patterns, strings.Join(words, `\s+`))
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	return regexp.MustCompile(`(?i)(?:` + strings.Join(patterns, "|") + `)`)
+}
+
+// phraseKey is the form used to look up a matched phrase
+func phraseKey(phrase string) string {
+	return strings.ToLower(strings.Join(strings.Fields(phrase), " "))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// replaceWords replaces the matches of re which are whole words, regexp's \b doesn't know umlauts
+func replaceWords(text string, re *regexp.Regexp, replace func(match string) string) string {
+	if re == nil {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:match[0]])
+		after, _ := utf8.DecodeRuneInString(text[match[1]:])
+		if match[0] > 0 && isWordRune(before) || match[1] < len(text) && isWordRune(after) {
+			continue
+		}
+		b.WriteString(text[last:match[0]])
+		b.WriteString(replace(text[match[0]:match[1]]))
+		last = match[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// textKey compares cue texts without case and punctuation
+func textKey(text string) string {
+	return strings.ToLower(strings.Join(strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) }), " "))
+}
+
+// collapseRepeatedPhrases keeps the last of a phrase whisper repeats over and over, like "ja ja ja ja ja.", which
+// has the punctuation
+func collapseRepeatedPhrases(text string) string {
+	words := strings.Fields(text)
+	var result []string
+	for i := 0; i < len(words); {
+		skipped := false
+		for n := 1; n <= maxRepeatedPhraseWords && i+n*minRepeats <= len(words); n++ {
+			phrase := textKey(strings.Join(words[i:i+n], " "))
+			repeats := 1
+			for i+(repeats+1)*n <= len(words) && textKey(strings.Join(words[i+repeats*n:i+(repeats+1)*n], " ")) == phrase {
+				repeats++
+			}
+			if repeats >= minRepeats && phrase != "" {
+				i += repeats * n
+				result = append(result, words[i-n:i]...)
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			result = append(result, words[i])
+			i++
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// hallucinated tells whether the cue is one of the lines whisper makes up
+func (rules *TranscriptRules) hallucinated(key string) bool {
+	for _, dropKey := range rules.dropKeys {
+		if dropKey != "" && strings.HasPrefix(key, dropKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces the phrases of the redaction list, email addresses and phone numbers
+func (rules *TranscriptRules) redact(text string) string {
+	text = replaceWords(text, rules.redactRegex, func(string) string { return redactionMarker })
+	text = emailRegex.ReplaceAllString(text, redactionMarker)
+	return phoneRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if !isPhoneNumber(match) {
+			return match
+		}
+		return redactionMarker
+	})
+}
+
+// isPhoneNumber tells phone numbers from the years and amounts phoneRegex also matches, which have fewer digits
+func isPhoneNumber(match string) bool {
+	digits := 0
+	for _, r := range match {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	return digits >= 7
+}
+
+// applyGlossary writes the domain terms the way we do
+func (rules *TranscriptRules) applyGlossary(text string) string {
+	return replaceWords(text, rules.glossaryRegex, func(match string) string {
+		return rules.glossaryTerms[phraseKey(match)]
+	})
+}
+
+// endsSentence tells whether a cue text ends a sentence
+func endsSentence(text string) bool {
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "!") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "…")
+}
+
+// mergeCues joins the fragments whisper writes into sentence-level cues, keeping the first start and the last end
+func mergeCues(cues []TranscriptCue) []TranscriptCue {
+	var merged []TranscriptCue
+	for _, cue := range cues {
+		if len(merged) > 0 {
+			current := &merged[len(merged)-1]
+			if !endsSentence(current.Text) && cue.Start-current.End <= maxCueGap &&
+				cue.End-current.Start <= maxCueDuration && len(current.Text)+len(cue.Text) < maxCueLength {
+				current.Text += " " + cue.Text
+				current.End = cue.End
+				continue
+			}
+		}
+		merged = append(merged, cue)
+	}
+	return merged
+}
+
+// cleanTranscript removes hallucinations, merges fragments into sentences, fixes the domain terms and redacts
+// private data, the times of the cues stay as whisper heard them
+func cleanTranscript(cues []TranscriptCue, rules *TranscriptRules) []TranscriptCue {
+	var kept []TranscriptCue
+	lastKey := ""
+	for _, cue := range cues {
+		cue.Text = collapseRepeatedPhrases(cue.Text)
+		key := textKey(cue.Text)
+		// whisper repeats the last line when it doesn't hear speech
+		if key == "" || key == lastKey || rules.hallucinated(key) {
+			continue
+		}
+		lastKey = key
+		kept = append(kept, cue)
+	}
+
+	cleaned := mergeCues(kept)
+	for i := range cleaned {
+		cleaned[i].Text = rules.redact(rules.applyGlossary(cleaned[i].Text))
+	}
+	return normalizeCues(cleaned)
+}
+
+// runCleanTranscriptsCommand cleans the transcripts of every entry (or the one given with -entry) in
+// -transcript-dir again, e.g. after adding names to the redaction list
+func runCleanTranscriptsCommand(logger *logrus.Logger, config *Config) error {
+	rules, err := loadTranscriptRules(config.TranscriptRulesPath)
+	if err != nil {
+		return err
+	}
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	cleaned := 0
+	for _, entry := range entries {
+		if config.EntryUUID != "" && entry.UUID != config.EntryUUID {
+			continue
+		}
+		if len(entry.Transcripts) == 0 {
+			continue
+		}
+
+		// the WebVTT file has everything the other formats have
+		filePath := filepath.Join(config.TranscriptDir, entry.UUID+".vtt")
+		cues, _, err := readWhisperOutput(filePath)
+		if err != nil {
+			logger.Warnf("Skipping the transcript of %s: %v", entry.UUID, err)
+			continue
+		}
+		result := cleanTranscript(cues, rules)
+		_, err = writeTranscriptFiles(config.TranscriptDir, entry.UUID, result, entry.Transcripts[0].Language)
+		if err != nil {
+			return err
+		}
+		cleaned++
+		logger.Infof("%s: %d cues cleaned up to %d", entry.UUID, len(cues), len(result))
+	}
+
+	logger.Infof("Cleaned the transcripts of %d entries", cleaned)
+	return nil
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// transcriptLanguage is the language transcribe-folder.sh tells whisper to expect
+const transcriptLanguage = "de"
+
+// whisperExtensions are the whisper outputs in the order they are tried, the JSON has exact offsets
+var whisperExtensions = []string{".json", ".srt", ".vtt", ".csv"}
+
+// transcriptFormats are the normalized files written for every episode with their Podcasting 2.0 type
+var transcriptFormats = []struct {
+	extension string
+	mimeType  string
+}{
+	{".vtt", "text/vtt"},
+	{".srt", "application/x-subrip"},
+	{".json", "application/json"},
+}
+
+// TranscriptCue is one timed line of a transcript
+type TranscriptCue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// whisperJSON is the part of the whisper.cpp -oj output we need
+type whisperJSON struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"`
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+// podcastTranscript is the JSON transcript format of the Podcasting 2.0 namespace
+type podcastTranscript struct {
+	Version  string                     `json:"version"`
+	Segments []podcastTranscriptSegment `json:"segments"`
+}
+
+type podcastTranscriptSegment struct {
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime"`
+	Body      string  `json:"body"`
+}
+
+// parseTimedText reads the cues of an SRT or WebVTT file, the cue numbers and the WEBVTT header are skipped
+func parseTimedText(r io.Reader) ([]TranscriptCue, error) {
+	var cues []TranscriptCue
+	var cue *TranscriptCue
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" {
+			cue = nil
+			continue
+		}
+		// only the line after a blank line or a cue number has the times, the text may contain arrows
+		if start, end, found := strings.Cut(line, "-->"); found && cue == nil {
+			startTime, err := parseChapterStart(strings.ReplaceAll(start, ",", "."))
+			if err != nil {
+				return nil, err
+			}
+			// WebVTT cue settings may follow the end time
+			fields := strings.Fields(end)
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("cue without end time: %q", line)
+			}
+			endTime, err := parseChapterStart(strings.ReplaceAll(fields[0], ",", "."))
+			if err != nil {
+				return nil, err
+			}
+			cues = append(cues, TranscriptCue{Start: startTime, End: endTime})
+			cue = &cues[len(cues)-1]
+			continue
+		}
+		if cue == nil {
+			continue
+		}
+		if cue.Text != "" {
+			cue.Text += "\n"
+		}
+		cue.Text += line
+	}
+	return cues, scanner.Err()
+}
+
+// parseWhisperCSV reads the whisper.cpp -ocsv output with the start and end in milliseconds
+func parseWhisperCSV(r io.Reader) ([]TranscriptCue, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var cues []TranscriptCue
+	for i, record := range records {
+		if len(record) < 3 {
+			return nil, fmt.Errorf("row %d: expected start, end and text", i+1)
+		}
+		start, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			if i == 0 {
+				// header
+				continue
+			}
+			return nil, fmt.Errorf("row %d: invalid start %q", i+1, record[0])
+		}
+		end, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid end %q", i+1, record[1])
+		}
+		cues = append(cues, TranscriptCue{Start: time.Duration(start) * time.Millisecond, End: time.Duration(end) * time.Millisecond, Text: record[2]})
+	}
+	return cues, nil
+}
+
+// readWhisperOutput reads the cues of a whisper output file and the language whisper detected, if it tells
+func readWhisperOutput(filePath string) ([]TranscriptCue, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	var cues []TranscriptCue
+	language := ""
+	switch filepath.Ext(filePath) {
+	case ".json":
+		var output whisperJSON
+		err = json.NewDecoder(file).Decode(&output)
+		for _, segment := range output.Transcription {
+			cues = append(cues, TranscriptCue{
+				Start: time.Duration(segment.Offsets.From) * time.Millisecond,
+				End:   time.Duration(segment.Offsets.To) * time.Millisecond,
+				Text:  segment.Text,
+			})
+		}
+		language = output.Result.Language
+	case ".srt", ".vtt":
+		cues, err = parseTimedText(file)
+	case ".csv":
+		cues, err = parseWhisperCSV(file)
+	default:
+		err = fmt.Errorf("unknown transcript format")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	return normalizeCues(cues), language, nil
+}
+
+// normalizeCues trims the text whisper starts with a space, drops empty cues and keeps the end after the start
+func normalizeCues(cues []TranscriptCue) []TranscriptCue {
+	normalized := []TranscriptCue{}
+	for _, cue := range cues {
+		cue.Text = strings.TrimSpace(cue.Text)
+		if cue.Text == "" {
+			continue
+		}
+		if cue.End < cue.Start {
+			cue.End = cue.Start
+		}
+		normalized = append(normalized, cue)
+	}
+	return normalized
+}
+
+// formatSRTTime formats a cue time like 00:01:02,345
+func formatSRTTime(d time.Duration) string {
+	return strings.Replace(formatDuration(d), ".", ",", 1)
+}
+
+// writeTranscript writes the cues as SRT, WebVTT or Podcasting 2.0 JSON, chosen by the file extension
+func writeTranscript(w io.Writer, cues []TranscriptCue, extension string) error {
+	var b strings.Builder
+	switch extension {
+	case ".srt":
+		for i, cue := range cues {
+			b.WriteString(fmt.Sprintf("%d\n%s --> %s\n%s\n\n", i+1, formatSRTTime(cue.Start), formatSRTTime(cue.End), cue.Text))
+		}
+	case ".vtt":
+		b.WriteString("WEBVTT\n\n")
+		for _, cue := range cues {
+			// a line with only "-->" would start a new cue
+			text := strings.ReplaceAll(cue.Text, "-->", "->")
+			b.WriteString(fmt.Sprintf("%s --> %s\n%s\n\n", formatDuration(cue.Start), formatDuration(cue.End), text))
+		}
+	case ".json":
+		transcript := podcastTranscript{Version: "1.0.0", Segments: []podcastTranscriptSegment{}}
+		for _, cue := range cues {
+			transcript.Segments = append(transcript.Segments, podcastTranscriptSegment{
+				StartTime: chapterSeconds(cue.Start),
+				EndTime:   chapterSeconds(cue.End),
+				Body:      cue.Text,
+			})
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(transcript)
+	default:
+		return fmt.Errorf("unknown transcript format %s", extension)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTranscriptFiles writes the cues in all transcriptFormats to dir and returns the references for content.yaml
+func writeTranscriptFiles(dir, uuid string, cues []TranscriptCue, language string) ([]CiRTranscript, error) {
+	var transcripts []CiRTranscript
+	for _, format := range transcriptFormats {
+		fileName := uuid + format.extension
+		filePath := filepath.Join(dir, fileName)
+		file, err := os.Create(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", filePath, err)
+		}
+		err = writeTranscript(file, cues, format.extension)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", filePath, err)
+		}
+		transcripts = append(transcripts, CiRTranscript{
+			Url:      siteRelativePath(dir, fileName),
+			Type:     format.mimeType,
+			Language: language,
+		})
+	}
+	return transcripts, nil
+}
+
+// findWhisperOutput returns the best whisper output in whisperDir named like one of the sound files of the entry
+func findWhisperOutput(whisperDir string, entry *CiREntry) string {
+	for _, audio := range entry.Audio {
+		soundFileName := soundFileNameFromAudioURL(audio.Url)
+		baseName := strings.TrimSuffix(soundFileName, path.Ext(soundFileName))
+		for _, extension := range whisperExtensions {
+			filePath := filepath.Join(whisperDir, baseName+extension)
+			if _, err := os.Stat(filePath); err == nil {
+				return filePath
+			}
+		}
+	}
+	return ""
+}
+
+// MissingTranscript is an episode without a transcript
+type MissingTranscript struct {
+	UUID          string `json:"uuid"`
+	SoundFileName string `json:"soundFile"`
+}
+
+func writeMissingTranscriptsMarkdown(w io.Writer, missing []MissingTranscript) error {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("## Episodes without a transcript (%d)\n\n", len(missing)))
+	if len(missing) > 0 {
+		b.WriteString("| Entry | Sound file |\n")
+		b.WriteString("| --- | --- |\n")
+	}
+	for _, m := range missing {
+		b.WriteString(fmt.Sprintf("| %s | %s |\n", m.UUID, m.SoundFileName))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// runTranscriptsCommand cleans up and normalizes the whisper output in the directory given as argument into
+// -transcript-dir, references the transcripts from content.yaml and reports the episodes still without one
+func runTranscriptsCommand(logger *logrus.Logger, config *Config) error {
+	if len(config.Args) != 1 {
+		return fmt.Errorf("usage: pad2gh transcripts <whisper output dir>")
+	}
+	whisperDir := config.Args[0]
+	err := checkFormat("transcripts", config.Format, "md", "json")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(config.TranscriptDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", config.TranscriptDir, err)
+	}
+
+	rules, err := loadTranscriptRules(config.TranscriptRulesPath)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	var orderedEntries []EntryWithOrder
+	missing := []MissingTranscript{}
+	written := 0
+	for _, entry := range entries {
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: entry})
+		if config.EntryUUID != "" && entry.UUID != config.EntryUUID {
+			continue
+		}
+		// transcripts already referenced are kept, -entry writes them again
+		if len(entry.Transcripts) > 0 && config.EntryUUID == "" {
+			continue
+		}
+
+		source := findWhisperOutput(whisperDir, entry)
+		if source == "" {
+			soundFileName := ""
+			if len(entry.Audio) > 0 {
+				soundFileName = soundFileNameFromAudioURL(entry.Audio[0].Url)
+			}
+			missing = append(missing, MissingTranscript{UUID: entry.UUID, SoundFileName: soundFileName})
+			continue
+		}
+		cues, language, err := readWhisperOutput(source)
+		if err != nil {
+			logger.Warnf("Skipping the transcript of %s: %v", entry.UUID, err)
+			continue
+		}
+		if len(cues) == 0 {
+			logger.Warnf("Skipping the transcript of %s: %s has no text", entry.UUID, source)
+			continue
+		}
+		if language == "" || language == "auto" {
+			language = transcriptLanguage
+		}
+
+		cues = cleanTranscript(cues, rules)
+		transcripts, err := writeTranscriptFiles(config.TranscriptDir, entry.UUID, cues, language)
+		if err != nil {
+			return err
+		}
+		entry.Transcripts = transcripts
+		written++
+		logger.Infof("%s: %d cues from %s", entry.UUID, len(cues), filepath.Base(source))
+	}
+
+	logger.Infof("Added transcripts to %d entries, %d episodes have none", written, len(missing))
+	if written > 0 {
+		err = writeAllYAMLEntries(orderedEntries, config.ContentFilePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := createOutput(config.OutputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if config.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(missing)
+	}
+	return writeMissingTranscriptsMarkdown(out, missing)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestReadWhisperOutput(t *testing.T) {
+	expected := []TranscriptCue{
+		{Start: 0, End: 4500 * time.Millisecond, Text: "Hallo und willkommen beim Chaos im Radio."},
+		{Start: 4500 * time.Millisecond, End: 62*time.Second + 30*time.Millisecond, Text: "Heute geht es um Smartphones."},
+	}
+	files := map[string]string{
+		"episode.json": `{"result": {"language": "de"}, "transcription": [
+			{"timestamps": {"from": "00:00:00,000", "to": "00:00:04,500"}, "offsets": {"from": 0, "to": 4500}, "text": " Hallo und willkommen beim Chaos im Radio."},
+			{"timestamps": {"from": "00:00:04,500", "to": "00:00:07,000"}, "offsets": {"from": 4500, "to": 7000}, "text": " "},
+			{"timestamps": {"from": "00:00:04,500", "to": "00:01:02,030"}, "offsets": {"from": 4500, "to": 62030}, "text": " Heute geht es um Smartphones."}]}`,
+		"episode.srt": "1\n00:00:00,000 --> 00:00:04,500\n Hallo und willkommen beim Chaos im Radio.\n\n2\n00:00:04,500 --> 00:01:02,030\n Heute geht es um Smartphones.\n\n",
+		"episode.vtt": "WEBVTT\n\n00:00:00.000 --> 00:00:04.500\n Hallo und willkommen beim Chaos im Radio.\n\n00:00:04.500 --> 00:01:02.030 align:start\n Heute geht es um Smartphones.\n",
+		"episode.csv": "start,end,text\n0,4500,\" Hallo und willkommen beim Chaos im Radio.\"\n4500,62030,\" Heute geht es um Smartphones.\"\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			filePath := filepath.Join(dir, name)
+			if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+			cues, _, err := readWhisperOutput(filePath)
+			if err != nil {
+				t.Fatalf("readWhisperOutput() failed: %v", err)
+			}
+			if len(cues) != len(expected) {
+				t.Fatalf("expected %+v, got %+v", expected, cues)
+			}
+			for i := range cues {
+				if cues[i] != expected[i] {
+					t.Errorf("cue %d = %+v, expected %+v", i, cues[i], expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTranscriptsCommand(t *testing.T) {
+	whisperDir := t.TempDir()
+	transcriptDir := filepath.Join(t.TempDir(), "transcripts")
+	contentFile := filepath.Join(t.TempDir(), "content.yaml")
+	outputFile := filepath.Join(t.TempDir(), "missing.json")
+
+	entries := []*CiREntry{
+		{UUID: "nt-2024-01-15", PublicationDate: "2024-01-15T00:00:00+00:00", Audio: []CiRaudio{
+			{Url: "$media_base_url/2024_01_15-chaos-im-radio.opus", MimeType: "audio/ogg"},
+			{Url: "$media_base_url/2024_01_15-chaos-im-radio.mp3", MimeType: "audio/mpeg"},
+		}},
+		{UUID: "nt-2024-01-22", PublicationDate: "2024-01-22T00:00:00+00:00", Audio: []CiRaudio{{Url: "$media_base_url/2024_01_22-chaos-im-radio.mp3", MimeType: "audio/mpeg"}}},
+	}
+	if err := appendMultipleEntriesToYAML(entries, contentFile); err != nil {
+		t.Fatalf("Failed to write content file: %v", err)
+	}
+	srt := "1\n00:00:00,000 --> 00:00:04,500\n Hallo --> Welt\n\n"
+	if err := os.WriteFile(filepath.Join(whisperDir, "2024_01_15-chaos-im-radio.srt"), []byte(srt), 0o644); err != nil {
+		t.Fatalf("Failed to write whisper output: %v", err)
+	}
+
+	config := &Config{ContentFilePath: contentFile, TranscriptDir: transcriptDir, OutputPath: outputFile, Format: "json", Args: []string{whisperDir}}
+	if err := runTranscriptsCommand(logrus.New(), config); err != nil {
+		t.Fatalf("runTranscriptsCommand() failed: %v", err)
+	}
+
+	updated, err := readYAMLEntries(contentFile)
+	if err != nil {
+		t.Fatalf("Failed to read content file: %v", err)
+	}
+	expected := []CiRTranscript{
+		{Url: "transcripts/nt-2024-01-15.vtt", Type: "text/vtt", Language: "de"},
+		{Url: "transcripts/nt-2024-01-15.srt", Type: "application/x-subrip", Language: "de"},
+		{Url: "transcripts/nt-2024-01-15.json", Type: "application/json", Language: "de"},
+	}
+	if len(updated[0].Transcripts) != len(expected) || len(updated[1].Transcripts) != 0 {
+		t.Fatalf("Expected transcripts only for the first entry, got %+v and %+v", updated[0].Transcripts, updated[1].Transcripts)
+	}
+	for i, transcript := range updated[0].Transcripts {
+		if transcript != expected[i] {
+			t.Errorf("transcript %d = %+v, expected %+v", i, transcript, expected[i])
+		}
+	}
+
+	vtt, _ := os.ReadFile(filepath.Join(transcriptDir, "nt-2024-01-15.vtt"))
+	if string(vtt) != "WEBVTT\n\n00:00:00.000 --> 00:00:04.500\nHallo -> Welt\n\n" {
+		t.Errorf("unexpected WebVTT transcript:\n%s", vtt)
+	}
+	var transcript podcastTranscript
+	data, _ := os.ReadFile(filepath.Join(transcriptDir, "nt-2024-01-15.json"))
+	if err := json.Unmarshal(data, &transcript); err != nil || len(transcript.Segments) != 1 || transcript.Segments[0].EndTime != 4.5 {
+		t.Errorf("unexpected JSON transcript: %s", data)
+	}
+
+	var missing []MissingTranscript
+	data, _ = os.ReadFile(outputFile)
+	if err := json.Unmarshal(data, &missing); err != nil || len(missing) != 1 || missing[0].UUID != "nt-2024-01-22" {
+		t.Errorf("Expected nt-2024-01-22 to be reported without a transcript, got %s", data)
+	}
+	if !strings.Contains(string(data), "2024_01_22-chaos-im-radio.mp3") {
+		t.Errorf("Expected the sound file in the report, got %s", data)
+	}
+}
+
+func TestCleanTranscript(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "transcript-rules.yaml")
+	rules := "redact:\n  - Erika Mustermann\n  - Jörg\nglossary:\n  Mastodon: [Maston]\ndrop:\n  - Das war's für heute\n"
+	if err := os.WriteFile(rulesFile, []byte(rules), 0o644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	transcriptRules, err := loadTranscriptRules(rulesFile)
+	if err != nil {
+		t.Fatalf("loadTranscriptRules() failed: %v", err)
+	}
+
+	s := time.Second
+	cues := []TranscriptCue{
+		{Start: 0, End: 2 * s, Text: "Hallo und willkommen"},
+		{Start: 2 * s, End: 4 * s, Text: "beim Kaos im Radio vom ccc p."},
+		{Start: 5 * s, End: 7 * s, Text: "Heute mit Erika   Mustermann und Jörg,"},
+		{Start: 7 * s, End: 9 * s, Text: "nicht Jörgen, ja ja ja ja ja ja."},
+		{Start: 9 * s, End: 10 * s, Text: "Schreibt an radio@ccc-p.org oder ruft an: 0331 123 45 67."},
+		{Start: 20 * s, End: 50 * s, Text: "Untertitel im Auftrag des ZDF, 2021"},
+		{Start: 50 * s, End: 60 * s, Text: "Untertitel im Auftrag des ZDF, 2021"},
+		{Start: 60 * s, End: 62 * s, Text: "Wir sind jetzt auf Maston"},
+		{Start: 62 * s, End: 63 * s, Text: "und beim Chaos Treff 2024."},
+		{Start: 63 * s, End: 64 * s, Text: "und beim Chaos Treff 2024."},
+		{Start: 70 * s, End: 71 * s, Text: "Das war's für heute, tschüss"},
+	}
+	expected := []TranscriptCue{
+		{Start: 0, End: 4 * s, Text: "Hallo und willkommen beim Chaos im Radio vom CCC-P."},
+		{Start: 5 * s, End: 9 * s, Text: "Heute mit […] und […], nicht Jörgen, ja."},
+		{Start: 9 * s, End: 10 * s, Text: "Schreibt an […] oder ruft an: […]."},
+		{Start: 60 * s, End: 63 * s, Text: "Wir sind jetzt auf Mastodon und beim Chaostreff 2024."},
+	}
+
+	cleaned := cleanTranscript(cues, transcriptRules)
+	if len(cleaned) != len(expected) {
+		t.Fatalf("expected %d cues, got %+v", len(expected), cleaned)
+	}
+	for i := range cleaned {
+		if cleaned[i] != expected[i] {
+			t.Errorf("cue %d = %+v, expected %+v", i, cleaned[i], expected[i])
+		}
+	}
+}
+
+func TestSuggestEntryChaptersFromTranscript(t *testing.T) {
+	whisperDir := t.TempDir()
+	entry := &CiREntry{
+		UUID: "nt-2024-01-15",
+		Audio: []CiRaudio{{
+			Url: "$media_base_url/2024_01_15-chaos-im-radio.mp3", MimeType: "audio/mpeg", Duration: "00:30:00.000",
+		}},
+		LongSummaryMD: "**Shownotes:**\n\n" +
+			"* Update [CE-Zeichen](https://example.org/ce) auf Ladegeräten\n" +
+			"* Was tun, wenn das Smartphone geklaut wird?\n" +
+			"* Open Health Hackademy\n" +
+			"* Quantencomputer\n" +
+			"\n\n**Musik:**\n" +
+			"\n&#x1f3b6;&nbsp;[Black Bones - Captain Blood](https://freemusicarchive.org/music/Black_Bones)" +
+			"\n&#x1f3b6;&nbsp;[Roman Meleshin - Delusion](https://meleshin.bandcamp.com/track/delusion)",
+	}
+
+	// speech with the topics in order, music from 05:00 to 09:00 and after 26:00
+	lines := []struct {
+		from, to int
+		text     string
+	}{
+		{0, 4, "Hallo und willkommen beim Chaos im Radio."},
+		{5, 9, "Heute haben wir ein paar Themen."},
+		{60, 64, "Als erstes ein Update zum CE-Zeichen."},
+		{65, 70, "Das Zeichen findet man auf Ladegeräten."},
+		{120, 125, "Und jetzt Musik."},
+		{300, 330, "Untertitel im Auftrag des ZDF, 2021"},
+		{540, 545, "Da sind wir wieder."},
+		{600, 605, "Was tun, wenn das Smartphone geklaut wird?"},
+		{606, 610, "Ein geklautes Smartphone ist ärgerlich."},
+		{650, 655, "Man sollte vorher eine Sicherung machen."},
+		{700, 705, "Und die Sperrnummer kennen."},
+		{750, 755, "Die Polizei hilft selten."},
+		{800, 805, "Aber eine Anzeige lohnt sich."},
+		{850, 855, "Wegen der Versicherung."},
+		{900, 905, "Dann war ich bei der Open Health Hackademy."},
+		{950, 955, "Da ging es um offene Gesundheitsdaten."},
+		{1000, 1005, "Und um Datenschutz."},
+		{1050, 1055, "Daran haben wir zwei Tage gearbeitet."},
+		{1100, 1105, "Mit vielen Leuten aus Potsdam."},
+		{1150, 1155, "Das Ergebnis gibt es online."},
+		{1200, 1205, "Nächstes Jahr gibt es eine neue Runde."},
+		{1250, 1255, "Wir werden berichten."},
+		{1300, 1305, "Ansonsten ist nicht viel passiert."},
+		{1350, 1355, "Außer dem Wetter."},
+		{1400, 1405, "Es war sehr warm."},
+		{1450, 1455, "Genug geredet."},
+		{1500, 1560, "Das war es für heute, tschüss."},
+	}
+	var segments []string
+	for _, line := range lines {
+		segments = append(segments, fmt.Sprintf(`{"offsets": {"from": %d, "to": %d}, "text": " %s"}`, line.from*1000, line.to*1000, line.text))
+	}
+	whisper := `{"result": {"language": "de"}, "transcription": [` + strings.Join(segments, ",") + `]}`
+	if err := os.WriteFile(filepath.Join(whisperDir, "2024_01_15-chaos-im-radio.json"), []byte(whisper), 0o644); err != nil {
+		t.Fatalf("Failed to write whisper output: %v", err)
+	}
+
+	config := &Config{WhisperDir: whisperDir, TranscriptRulesPath: filepath.Join(whisperDir, "missing.yaml")}
+	suggestEntryChapters(entry, config)
+
+	expected := []CiRChapter{
+		{Start: "00:00:00", Title: "Begrüßung"},
+		{Start: "00:01:00", Title: "Update CE-Zeichen auf Ladegeräten"},
+		{Start: "00:02:05", Title: "Musik: Black Bones - Captain Blood", Href: "https://freemusicarchive.org/music/Black_Bones"},
+		{Start: "00:10:00", Title: "Was tun, wenn das Smartphone geklaut wird?"},
+		{Start: "00:15:00", Title: "Open Health Hackademy"},
+		{Start: "00:26:00", Title: "Musik: Roman Meleshin - Delusion", Href: "https://meleshin.bandcamp.com/track/delusion"},
+	}
+	if len(entry.suggestedChapters) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, entry.suggestedChapters)
+	}
+	for i := range expected {
+		if entry.suggestedChapters[i] != expected[i] {
+			t.Errorf("chapter %d = %+v, expected %+v", i, entry.suggestedChapters[i], expected[i])
+		}
+	}
+	if len(entry.processingWarnings) != 1 || !strings.Contains(entry.processingWarnings[0], `"Quantencomputer" is not mentioned`) {
+		t.Errorf("expected a note about the missing topic, got %v", entry.processingWarnings)
+	}
+
+	block := chaptersPadBlock(entry.suggestedChapters)
+	if !strings.HasPrefix(block, "## Kapitel\n\n00:00:00 Begrüßung\n") || !strings.Contains(block, "\n00:26:00 https://meleshin.bandcamp.com/track/delusion\n") {
+		t.Errorf("unexpected pad block:\n%s", block)
+	}
+}
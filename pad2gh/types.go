@@ -2,8 +2,11 @@ package main
 
 // CiRaudio is the audio information for the podcast
 type CiRaudio struct {
-	Url      string `yaml:"url"`      // format: https://cdn.ccc-p.org/episodes/2021-01-01-episode.mp3
-	MimeType string `yaml:"mimeType"` // format: audio/mpeg
+	Url      string `yaml:"url"`                // format: https://cdn.ccc-p.org/episodes/2021-01-01-episode.mp3
+	MimeType string `yaml:"mimeType"`           // format: audio/mpeg
+	Size     int64  `yaml:"size,omitempty"`     // in bytes
+	Duration string `yaml:"duration,omitempty"` // format: 00:00:00.000
+	SHA256   string `yaml:"sha256,omitempty"`   // hex digest of the published file
 }
 
 // CiRChapter is the chapter information for the podcast
@@ -13,28 +16,63 @@ type CiRChapter struct {
 	Href  string `yaml:"href,omitempty"` // optional link for the chapter
 }
 
+// CiRTranscript is a transcript of the episode, like the podcast:transcript tag of Podcasting 2.0
+type CiRTranscript struct {
+	Url      string `yaml:"url"`                // format: transcripts/nt-2024-01-15.vtt
+	Type     string `yaml:"type"`               // format: text/vtt
+	Language string `yaml:"language,omitempty"` // format: de
+}
+
+// CiRPerson is someone taking part in the episode, like the podcast:person tag of Podcasting 2.0
+type CiRPerson struct {
+	Name  string `yaml:"name"`
+	Role  string `yaml:"role"`            // role of the Podcast Taxonomy, format: host, guest, audio engineer
+	Group string `yaml:"group,omitempty"` // taxonomy group of the role, cast if empty
+	Href  string `yaml:"href,omitempty"`  // homepage or profile
+	Img   string `yaml:"img,omitempty"`   // avatar
+}
+
 // CiREntry is the podcast episode information
 type CiREntry struct {
-	UUID               string       `yaml:"uuid"`
-	Title              string       `yaml:"title"`
-	Subtitle           string       `yaml:"subtitle"`
-	Summary            string       `yaml:"summary"`
-	PublicationDate    string       `yaml:"publicationDate"`
-	Audio              []CiRaudio   `yaml:"audio"`
-	Chapters           []CiRChapter `yaml:"chapters,omitempty"`
-	LongSummaryMD      string       `yaml:"long_summary_md,omitempty"`
+	UUID               string          `yaml:"uuid"`
+	Title              string          `yaml:"title"`
+	Subtitle           string          `yaml:"subtitle"`
+	Summary            string          `yaml:"summary"`
+	PublicationDate    string          `yaml:"publicationDate"`
+	Audio              []CiRaudio      `yaml:"audio"`
+	Chapters           []CiRChapter    `yaml:"chapters,omitempty"`
+	LongSummary        string          `yaml:"long_summary,omitempty"` // HTML of the entries from before the pads
+	LongSummaryMD      string          `yaml:"long_summary_md,omitempty"`
+	Waveform           string          `yaml:"waveform,omitempty"` // peaks JSON relative to the site, format: waveforms/nt-2024-01-15.json
+	Transcripts        []CiRTranscript `yaml:"transcripts,omitempty"`
+	Preview            string          `yaml:"preview,omitempty"` // social preview card relative to the site, format: previews/nt-2024-01-15-<hash>.jpg
+	Image              string          `yaml:"image,omitempty"`   // episode cover relative to the site, format: covers/nt-2024-01-15.jpg
+	Persons            []CiRPerson     `yaml:"persons,omitempty"`
 	padURL             string
+	rawYAML            string // text of the entry in content.yaml
+	readYAML           string // the entry encoded as it was read, to tell if it was changed since
+	coverURL           string // image of the cover section or the front matter, downloaded by addEntryCover
 	processingWarnings []string
 	tags               map[string]bool
+	suggestedChapters  []CiRChapter // proposed from the audio, only shown in the PR comments
+}
+
+// PadFrontMatter are the fields pad2gh reads from the YAML front matter of a pad
+type PadFrontMatter struct {
+	Image   string      `yaml:"image"`   // URL of the episode cover, like the cover section
+	Persons []CiRPerson `yaml:"persons"` // like the Moderation and Mitwirkende sections, the role defaults to host
 }
 
 // PadMapping represents the mapping between pads, YAML entries and sound files
 type PadMapping struct {
-	PadURL             string
-	Date               string
-	HasYAMLEntry       bool
-	YAMLEntry          *CiREntry
-	HasSoundFileLocal  bool
-	HasSoundFileOnline bool
-	SoundFileName      string
+	PadURL              string
+	Date                string
+	HasYAMLEntry        bool
+	YAMLEntry           *CiREntry
+	HasSoundFileLocal   bool
+	HasSoundFileOnline  bool
+	SoundFileName       string
+	SoundFileSize       int64 // in bytes, -1 if the server didn't tell
+	SoundFileCandidates []SoundFileCandidate
+	SkipReasons         []string // why bulk mode created no entry, set by collectNewEntries
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// watchRecord remembers when and from which pad an episode was imported
+type watchRecord struct {
+	PadURL     string    `json:"padURL"`
+	ImportedAt time.Time `json:"importedAt"`
+}
+
+// watchState is persisted between runs of the watch mode so every episode is imported only once
+type watchState struct {
+	Imported map[string]watchRecord `json:"imported"` // keyed by date YYYY-MM-DD
+}
+
+// loadWatchState reads the state file, an absent file results in an empty state
+func loadWatchState(stateFilePath string) (*watchState, error) {
+	state := &watchState{Imported: map[string]watchRecord{}}
+
+	content, err := os.ReadFile(stateFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", stateFilePath, err)
+	}
+	if state.Imported == nil {
+		state.Imported = map[string]watchRecord{}
+	}
+	return state, nil
+}
+
+// save writes the state via a temporary file so an interrupted write never leaves a broken state behind
+func (s *watchState) save(stateFilePath string) error {
+	return writeJSONAtomically(stateFilePath, s)
+}
+
+// writeJSONAtomically replaces the file via a temp file, so a crash never leaves half a state behind
+func writeJSONAtomically(filePath string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", filepath.Base(filePath), err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), ".pad2gh-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(filePath), err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(filePath), err)
+	}
+
+	err = os.Rename(tmpFile.Name(), filePath)
+	if err != nil {
+		return fmt.Errorf("failed to rename temp file to %s: %v", filePath, err)
+	}
+	return nil
+}
+
+// isCandidate reports whether a mapping should be checked for import: not imported yet and the sound file is available
+func (s *watchState) isCandidate(mapping PadMapping, config *Config) bool {
+	if _, done := s.Imported[mapping.Date]; done {
+		return false
+	}
+	return len(skipReasons(mapping, config)) == 0
+}
+
+func runWatchMode(logger *logrus.Logger, config *Config) error {
+	state, err := loadWatchState(config.StateFilePath)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Watching for new episodes every %s, %d episodes already imported", config.WatchInterval, len(state.Imported))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(config.WatchInterval)
+	defer ticker.Stop()
+
+	for {
+		err := pollOnce(ctx, logger, config, state)
+		if err != nil {
+			// a failing poll (pad offline, network issues) must not end the watch mode
+			logger.Errorf("Poll failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Shutting down watch mode")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollOnce runs the mapping once and imports every episode that became ready since the last poll
+func pollOnce(ctx context.Context, logger *logrus.Logger, config *Config, state *watchState) error {
+	u, _ := url.JoinPath(config.PadBaseURL, "Radio")
+	padURLs, err := getAllPadLinks(u)
+	if err != nil {
+		return fmt.Errorf("failed to get pad URLs from %s: %v", u, err)
+	}
+
+	existingEntries, err := readExistingYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing YAML entries: %v", err)
+	}
+
+	mappings, err := createPadMapping(padURLs, existingEntries, config)
+	if err != nil {
+		return fmt.Errorf("failed to create mapping: %v", err)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].Date < mappings[j].Date
+	})
+
+	for _, mapping := range mappings {
+		// finish the current import, but don't start another one when shutting down
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !state.isCandidate(mapping, config) {
+			continue
+		}
+
+		// the sound file and cover are only processed once the pad is ready
+		entry, err := readPadEntry(mapping.PadURL, config)
+		if err != nil {
+			logger.Warnf("Skipping %s: %v", mapping.PadURL, err)
+			continue
+		}
+		if !entry.tags["shownotes_complete"] {
+			logger.Debugf("Pad %s is not tagged shownotes_complete yet", mapping.PadURL)
+			continue
+		}
+		err = addEntryMedia(entry, config)
+		if err != nil {
+			logger.Warnf("Skipping %s: %v", mapping.PadURL, err)
+			continue
+		}
+		if config.StrictMode && len(entry.processingWarnings) > 0 {
+			logger.Warnf("Skipping %s due to warnings in strict mode: %v", mapping.PadURL, entry.processingWarnings)
+			continue
+		}
+
+		err = importWatchedEntry(logger, entry, mapping, config, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importIfReady imports the episode of the mapping for a webhook job like pollOnce does and returns a short
+// description of the outcome
+func importIfReady(logger *logrus.Logger, mapping PadMapping, config *Config, state *watchState) (string, error) {
+	if !state.isCandidate(mapping, config) {
+		if !mapping.HasYAMLEntry && !mapping.hasSoundFile(config) {
+			return "sound file " + mapping.SoundFileName + " missing", nil
+		}
+		return "already imported", nil
+	}
+
+	entry, err := readPadEntry(mapping.PadURL, config)
+	if err != nil {
+		return "", err
+	}
+	if !entry.tags["shownotes_complete"] {
+		return "pad is not tagged shownotes_complete yet", nil
+	}
+	err = addEntryMedia(entry, config)
+	if err != nil {
+		return "", err
+	}
+	if config.StrictMode && len(entry.processingWarnings) > 0 {
+		return "warnings in strict mode: " + strings.Join(entry.processingWarnings, "; "), nil
+	}
+
+	err = importWatchedEntry(logger, entry, mapping, config, state)
+	if err != nil {
+		return "", err
+	}
+	return "imported", nil
+}
+
+// importWatchedEntry writes the entry, records it in the state and runs the on-import command
+func importWatchedEntry(logger *logrus.Logger, entry *CiREntry, mapping PadMapping, config *Config, state *watchState) error {
+	logger.Infof("Importing episode %s from %s", mapping.Date, mapping.PadURL)
+
+	// appended like in bulk mode, the rest of content.yaml stays untouched
+	err := appendEntryToYAML(entry, config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to append entry for %s: %v", mapping.Date, err)
+	}
+
+	if config.CommentsFilePath != "" {
+		err = writeCommentsFile([]*CiREntry{entry}, config.CommentsFilePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	state.Imported[mapping.Date] = watchRecord{PadURL: mapping.PadURL, ImportedAt: time.Now()}
+	err = state.save(config.StateFilePath)
+	if err != nil {
+		return err
+	}
+
+	if config.OnImportCommand == "" {
+		return nil
+	}
+
+	// the import is recorded already, a failing hook is reported but not retried to keep imports exactly once
+	cmd := exec.Command("sh", "-c", config.OnImportCommand)
+	cmd.Env = append(os.Environ(), "ENTRY_DATE="+mapping.Date, "PAD_URL="+mapping.PadURL)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		logger.Errorf("On-import command failed for %s: %v", mapping.Date, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWatchStateRoundTrip(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := loadWatchState(stateFile)
+	if err != nil {
+		t.Fatalf("loadWatchState() on missing file failed: %v", err)
+	}
+	if len(state.Imported) != 0 {
+		t.Errorf("Expected empty state, got %d entries", len(state.Imported))
+	}
+
+	state.Imported["2024-01-15"] = watchRecord{PadURL: "https://pad.ccc-p.org/Radio_2024-01-15_test1", ImportedAt: time.Now()}
+	err = state.save(stateFile)
+	if err != nil {
+		t.Fatalf("save() failed: %v", err)
+	}
+
+	reloaded, err := loadWatchState(stateFile)
+	if err != nil {
+		t.Fatalf("loadWatchState() failed: %v", err)
+	}
+	if reloaded.Imported["2024-01-15"].PadURL != "https://pad.ccc-p.org/Radio_2024-01-15_test1" {
+		t.Errorf("Expected imported record to survive a restart, got %+v", reloaded.Imported)
+	}
+}
+
+func TestWatchStateIsCandidate(t *testing.T) {
+	state := &watchState{Imported: map[string]watchRecord{"2024-01-15": {}}}
+	config := &Config{FileOnline: true}
+
+	tests := []struct {
+		name     string
+		mapping  PadMapping
+		expected bool
+	}{
+		{
+			name:     "Ready episode",
+			mapping:  PadMapping{Date: "2024-02-12", HasSoundFileOnline: true},
+			expected: true,
+		},
+		{
+			name:     "Already imported",
+			mapping:  PadMapping{Date: "2024-01-15", HasSoundFileOnline: true},
+			expected: false,
+		},
+		{
+			name:     "Already in YAML",
+			mapping:  PadMapping{Date: "2024-02-12", HasSoundFileOnline: true, HasYAMLEntry: true},
+			expected: false,
+		},
+		{
+			name:     "Sound file missing",
+			mapping:  PadMapping{Date: "2024-02-12", HasSoundFileLocal: true},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := state.isCandidate(tt.mapping, config); result != tt.expected {
+				t.Errorf("isCandidate() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestImportIfReadySkipsMediaOfUnfinishedPads(t *testing.T) {
+	coverRequests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cover.png" {
+			coverRequests++
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("# CiR\n\n## Summary\nTest\n\n## Cover\n" + server.URL + "/cover.png\n\n###### tags: `radio` `no_music`\n"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	config := &Config{
+		FileOnline:       true,
+		CoverDir:         filepath.Join(dir, "covers"),
+		PrivacyRulesPath: filepath.Join(dir, "privacy-rules.yaml"),
+		ContentFilePath:  filepath.Join(dir, "content.yaml"),
+	}
+	state := &watchState{Imported: map[string]watchRecord{}}
+	mapping := PadMapping{Date: "2024-02-12", PadURL: server.URL + "/Radio_2024-02-12_test", HasSoundFileOnline: true}
+
+	outcome, err := importIfReady(logrus.New(), mapping, config, state)
+	if err != nil || outcome != "pad is not tagged shownotes_complete yet" {
+		t.Fatalf("importIfReady() = %q, %v", outcome, err)
+	}
+	if coverRequests != 0 {
+		t.Errorf("expected the cover of an unfinished pad not to be downloaded, got %d requests", coverRequests)
+	}
+	if _, err := os.Stat(config.CoverDir); !os.IsNotExist(err) {
+		t.Errorf("expected no cover directory, got %v", err)
+	}
+}
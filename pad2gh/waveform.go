@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// waveformPixelsPerSecond is the resolution of the peaks, an hour is 36000 min/max pairs
+const waveformPixelsPerSecond = 10
+
+// Waveform is the JSON format (version 2) of the audiowaveform tool, readable by peaks.js and wavesurfer
+type Waveform struct {
+	Version         int   `json:"version"`
+	Channels        int   `json:"channels"`
+	SampleRate      int   `json:"sample_rate"`
+	SamplesPerPixel int   `json:"samples_per_pixel"`
+	Bits            int   `json:"bits"`
+	Length          int   `json:"length"`
+	Data            []int `json:"data"` // min and max of every pixel
+}
+
+// waveformBuilder collects the minimum and maximum of the mono mix for every pixel
+type waveformBuilder struct {
+	waveform Waveform
+	min, max float64
+	fill     int
+	samples  int64
+}
+
+func newWaveformBuilder(sampleRate int) *waveformBuilder {
+	return &waveformBuilder{
+		waveform: Waveform{
+			Version:         2,
+			Channels:        1,
+			SampleRate:      sampleRate,
+			SamplesPerPixel: sampleRate / waveformPixelsPerSecond,
+			Bits:            8,
+			Data:            []int{},
+		},
+		min: math.Inf(1),
+		max: math.Inf(-1),
+	}
+}
+
+func (b *waveformBuilder) add(left, right []float64) {
+	for i := range left {
+		x := (left[i] + right[i]) / 2
+		b.min = math.Min(b.min, x)
+		b.max = math.Max(b.max, x)
+		b.fill++
+		b.samples++
+		if b.fill == b.waveform.SamplesPerPixel {
+			b.finishPixel()
+		}
+	}
+}
+
+// to8Bit scales a sample to the signed 8 bit range of the waveform data
+func to8Bit(x float64) int {
+	return int(math.Max(-128, math.Min(127, math.Round(x*128))))
+}
+
+func (b *waveformBuilder) finishPixel() {
+	b.waveform.Data = append(b.waveform.Data, to8Bit(b.min), to8Bit(b.max))
+	b.waveform.Length++
+	b.min, b.max, b.fill = math.Inf(1), math.Inf(-1), 0
+}
+
+// result returns the waveform and the exact duration of the decoded audio
+func (b *waveformBuilder) result() (Waveform, time.Duration) {
+	if b.fill > 0 {
+		b.finishPixel()
+	}
+	return b.waveform, time.Duration(b.samples * int64(time.Second) / int64(b.waveform.SampleRate))
+}
+
+// waveformForSoundFile decodes the sound file and computes its peaks
+func waveformForSoundFile(fileName string, config *Config) (Waveform, time.Duration, error) {
+	file, err := openSoundFile(fileName, config)
+	if err != nil {
+		return Waveform{}, 0, err
+	}
+	defer file.Close()
+
+	var builder *waveformBuilder
+	err = decodeAudio(file, func(sampleRate int, _ bool, left, right []float64) {
+		if builder == nil {
+			builder = newWaveformBuilder(sampleRate)
+		}
+		builder.add(left, right)
+	})
+	if err != nil {
+		return Waveform{}, 0, err
+	}
+	if builder == nil {
+		return Waveform{}, 0, fmt.Errorf("no audio frames in %s", fileName)
+	}
+	waveform, duration := builder.result()
+	return waveform, duration, nil
+}
+
+// writeWaveform writes the peaks as compact JSON
+func writeWaveform(filePath string, waveform Waveform) error {
+	data, err := json.Marshal(waveform)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0o644)
+}
+
+// runWaveformCommand writes the peaks of every episode (or the one given with -entry) into -waveform-dir
+// and references them from content.yaml, existing waveform files are kept
+func runWaveformCommand(logger *logrus.Logger, config *Config) error {
+	if config.SoundDir == "" && !config.FileOnline {
+		return fmt.Errorf("waveform needs -sound-dir or -file-online")
+	}
+	err := os.MkdirAll(config.WaveformDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", config.WaveformDir, err)
+	}
+
+	entries, err := readYAMLEntries(config.ContentFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing entries: %v", err)
+	}
+
+	var orderedEntries []EntryWithOrder
+	written := 0
+	for _, entry := range entries {
+		orderedEntries = append(orderedEntries, EntryWithOrder{Entry: entry})
+		if config.EntryUUID != "" && entry.UUID != config.EntryUUID {
+			continue
+		}
+		audio := entryMP3(entry)
+		if audio == nil {
+			continue
+		}
+
+		fileName := entry.UUID + ".json"
+		filePath := filepath.Join(config.WaveformDir, fileName)
+		reference := siteRelativePath(config.WaveformDir, fileName)
+		if _, err := os.Stat(filePath); err == nil && config.EntryUUID == "" {
+			if entry.Waveform != reference {
+				entry.Waveform = reference
+				written++
+			}
+			continue
+		}
+
+		soundFileName := soundFileNameFromAudioURL(audio.Url)
+		waveform, duration, err := waveformForSoundFile(soundFileName, config)
+		if err != nil {
+			logger.Warnf("Could not compute the waveform of %s: %v", entry.UUID, err)
+			continue
+		}
+		err = writeWaveform(filePath, waveform)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %v", filePath, err)
+		}
+		entry.Waveform = reference
+		if audio.Duration == "" {
+			// decoding gives the exact duration for free
+			audio.Duration = formatDuration(duration)
+		}
+		written++
+		logger.Infof("%s: %d peaks, %s", filePath, waveform.Length, formatDuration(duration))
+	}
+
+	logger.Infof("Updated the waveform of %d entries", written)
+	if written == 0 {
+		return nil
+	}
+	return writeAllYAMLEntries(orderedEntries, config.ContentFilePath)
+}
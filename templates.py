@@ -57,10 +57,12 @@ $content
 
 
 entry = string.Template(r"""
-<div id="$entrydivid" class="yaspp-entry">
+<div id="$entrydivid" class="yaspp-entry" data-waveform="$waveform">
 <a href="#$uuid" style="text-decoration: none;"><h2 id="$uuid">$title</h2></a>
 
 <p>$summary</p>
+$hosted_by
+$share_link
 
 <div id="player_$entrydivid"></div>
 <div id="shownotes_$entrydivid" class="yaspp-shownotes">
@@ -79,3 +81,34 @@ subscribe_button = string.Template(r"""
 	</script>
 	<script class="podlove-subscribe-button" src="https://cdn.podlove.org/subscribe-button/javascripts/app.js" data-language="en" data-size="medium" data-json-data="podcastData" data-color="$datacolor" data-format="cover" data-style="filled"></script><noscript><a href="$feed_url">Subscribe to feed</a></noscript>
 """)
+
+
+hosted_by = string.Template(r"""<p class="yaspp-persons">Moderation: $hosts</p>""")
+
+share_link = string.Template(r"""<p class="yaspp-share"><a href="$href">Link zum Teilen</a></p>""")
+
+# a page per episode with the Open Graph tags for the preview, visitors are sent on to the episode
+share_page = string.Template(r"""<!DOCTYPE html>
+<html lang="de">
+
+<head>
+<meta charset="utf-8">
+<title>$title – $podcast_title</title>
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="$podcast_title">
+<meta property="og:title" content="$title">
+<meta property="og:description" content="$summary">
+<meta property="og:url" content="$url">
+<meta property="og:image" content="$image">
+<meta property="og:image:width" content="1200">
+<meta property="og:image:height" content="630">
+<meta name="twitter:card" content="summary_large_image">
+<link rel="canonical" href="$url">
+<meta http-equiv="refresh" content="0; url=$url">
+</head>
+
+<body>
+<a href="$url">$title</a>
+</body>
+
+</html>""")
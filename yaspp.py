@@ -62,6 +62,19 @@ def generate_html_entry(entryid: int, entry: dict):
 	clean_entry.pop("uuid", None)
 	clean_entry.pop("long_summary", None)
 	clean_entry.pop("long_summary_md", None)
+	waveform = clean_entry.pop("waveform", "")
+	# podcast:transcript metadata, the player expects its own transcript format
+	clean_entry.pop("transcripts", None)
+	persons = clean_entry.pop("persons", [])
+	if persons:
+		clean_entry["contributors"] = [player_contributor(person) for person in persons]
+	share_link = ""
+	if image := clean_entry.pop("image", None):
+		clean_entry["poster"] = "%s/%s_250.jpg" % (config.website, os.path.splitext(image)[0])
+	if preview := clean_entry.pop("preview", None):
+		# the square variant rendered next to the card, like cover_250.jpg
+		clean_entry["poster"] = "%s/%s_250.jpg" % (config.website, os.path.splitext(preview)[0])
+		share_link = templates.share_link.substitute(href=share_page_path(entry))
 
 	clean_entry["theme"] = config.podlove_player_theme
 
@@ -80,9 +93,49 @@ def generate_html_entry(entryid: int, entry: dict):
 			title=entry["title"],
 			summary=entry["summary"],
 			long_summary=long_summary,
+			waveform=waveform,
+			hosted_by=generate_hosted_by(persons),
+			share_link=share_link,
 		) + podlove_player
 
 
+def player_contributor(person: dict):
+	contributor = {"name": person["name"], "role": {"title": person["role"]}}
+	if "img" in person:
+		contributor["avatar"] = person["img"]
+	return contributor
+
+
+def generate_hosted_by(persons):
+	import html
+	hosts = []
+	for person in persons:
+		if person["role"] not in ("host", "co-host"):
+			continue
+		name = html.escape(person["name"])
+		if "href" in person:
+			name = '<a href="%s">%s</a>' % (html.escape(person["href"]), name)
+		hosts.append(name)
+	if not hosts:
+		return ""
+	return templates.hosted_by.substitute(hosts=", ".join(hosts))
+
+
+def share_page_path(entry: dict):
+	return "episodes/%s.html" % entry["uuid"]
+
+
+def generate_share_page(entry: dict):
+	import html
+	return templates.share_page.substitute(
+			podcast_title=html.escape(config.podcast_title),
+			title=html.escape(entry["title"]),
+			summary=html.escape(strip_html_tags(entry.get("summary", "")).strip()),
+			url="%s/#%s" % (config.website, entry["uuid"]),
+			image="%s/%s" % (config.website, entry["preview"]),
+		)
+
+
 def generate_html(content, rev=True):
 	op = reversed if rev else lambda x: x
 	content_list = "\n".join(generate_html_entry(i, e)
@@ -98,8 +151,46 @@ def generate_html(content, rev=True):
 
 #-------------------------------------------------------------------------------
 
+def parse_duration(duration):
+	import datetime
+	hours, minutes, seconds = duration.split(":")
+	return datetime.timedelta(hours=int(hours), minutes=int(minutes), seconds=float(seconds))
+
+
+PODCAST_NAMESPACE = "https://podcastindex.org/namespace/1.0"
+
+
+class EpisodeWithPersons(podgen.Episode):
+	"""An episode with the podcast:person tags of Podcasting 2.0, podgen doesn't know them"""
+
+	def __init__(self, persons=(), **kwargs):
+		super().__init__(**kwargs)
+		self.persons = persons
+
+	def rss_entry(self):
+		from lxml import etree
+		item = super().rss_entry()
+		for person in self.persons:
+			tag = etree.SubElement(item, "{%s}person" % PODCAST_NAMESPACE)
+			tag.text = person["name"]
+			tag.set("role", person["role"])
+			for attribute in ("group", "href", "img"):
+				if attribute in person:
+					tag.set(attribute, person[attribute])
+		return item
 
-def generate_feed(content, audio_idx=0):
+
+class PodcastWithPersons(podgen.Podcast):
+	"""Declares the podcast namespace on the channel for the podcast:person tags of the episodes"""
+
+	def _create_rss(self):
+		from lxml import etree
+		rss = super()._create_rss()
+		etree.cleanup_namespaces(rss, top_nsmap={"podcast": PODCAST_NAMESPACE})
+		return rss
+
+
+def generate_feed(content, mime_types=("audio/mpeg", "audio/mp3")):
 	def create_long_summary(entry):
 		if long_summary_md := entry.get("long_summary_md"):
 			import markdown
@@ -110,8 +201,23 @@ def generate_feed(content, audio_idx=0):
 		return entry.get("summary"), long_summary
 
 
+	def feed_audio(entry):
+		# the player gets all renditions in preference order, the feed the one every client can play
+		for audio in entry["audio"]:
+			if audio.get("mimeType") in mime_types:
+				return audio
+		return entry["audio"][0]
+
+
 	def load_media(entry):
-		audio = entry["audio"][audio_idx]
+		audio = feed_audio(entry)
+		if "size" in audio:
+			# probed by pad2gh, no need to ask the server or have the file around
+			media = podgen.Media(audio["url"], audio["size"])
+			if duration := audio.get("duration"):
+				media.duration = parse_duration(duration)
+			return media
+
 		media = podgen.Media.create_from_server_response(audio["url"])
 		try:
 			if "filename" in audio:
@@ -121,7 +227,7 @@ def generate_feed(content, audio_idx=0):
 		return media
 
 
-	p = podgen.Podcast(
+	p = PodcastWithPersons(
 			name=config.podcast_title,
 			description=config.hello_text,
 			website=config.website,
@@ -133,13 +239,16 @@ def generate_feed(content, audio_idx=0):
 			explicit=False
 		)
 
-	p.episodes = [podgen.Episode(
+	p.episodes = [EpisodeWithPersons(
+			persons=entry.get("persons", []),
 			id=entry["uuid"],
 			title=entry["title"],
 			summary=entry["summary"],
 			long_summary=("<p>%s</p>\n%s" % create_long_summary(entry)),
 			publication_date=entry["publicationDate"],
 			media=load_media(entry),
+			# episodes without their own cover show the one of the podcast
+			image=("%s/%s" % (config.website, entry["image"])) if "image" in entry else None,
 		) for entry in content]
 	return str(p)
 
@@ -170,6 +279,13 @@ def main():
 	with open(os.path.join(args.output_dir, "feed.xml"), "w") as outfile:
 		outfile.writelines(generate_feed(content))
 
+	for entry in content:
+		if "preview" not in entry:
+			continue
+		os.makedirs(os.path.join(args.output_dir, "episodes"), exist_ok=True)
+		with open(os.path.join(args.output_dir, share_page_path(entry)), "w") as outfile:
+			outfile.write(generate_share_page(entry))
+
 
 if __name__ == "__main__":
 	main()